@@ -0,0 +1,90 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sync"
+)
+
+// ErrKeyUsageLimit is returned by KeyUsageLimit.SealChecked once the
+// configured per-key limit has been reached. The key must be rotated.
+var ErrKeyUsageLimit = errors.New("cng: AEAD key usage limit reached")
+
+// KeyUsageLimit wraps an AEAD and enforces a maximum number of Seal
+// operations and a maximum number of plaintext bytes sealed with its key,
+// such as the invocation limits NIST SP 800-38D and RFC 9001 set for GCM.
+//
+// It is safe for concurrent use if the wrapped AEAD is.
+type KeyUsageLimit struct {
+	aead        cipher.AEAD
+	maxMessages uint64
+	maxBytes    uint64
+
+	mu       sync.Mutex
+	messages uint64
+	bytes    uint64
+}
+
+// NewKeyUsageLimit returns a KeyUsageLimit that allows at most maxMessages
+// Seal operations and at most maxBytes bytes of plaintext in total.
+// A zero limit means no limit.
+func NewKeyUsageLimit(aead cipher.AEAD, maxMessages, maxBytes uint64) *KeyUsageLimit {
+	return &KeyUsageLimit{aead: aead, maxMessages: maxMessages, maxBytes: maxBytes}
+}
+
+func (l *KeyUsageLimit) NonceSize() int { return l.aead.NonceSize() }
+func (l *KeyUsageLimit) Overhead() int  { return l.aead.Overhead() }
+
+// reserve accounts for one Seal of n bytes, failing without
+// recording anything if that would exceed a limit.
+func (l *KeyUsageLimit) reserve(n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxMessages != 0 && l.messages >= l.maxMessages {
+		return ErrKeyUsageLimit
+	}
+	if l.maxBytes != 0 && (uint64(n) > l.maxBytes || l.bytes > l.maxBytes-uint64(n)) {
+		return ErrKeyUsageLimit
+	}
+	l.messages++
+	l.bytes += uint64(n)
+	return nil
+}
+
+// SealChecked is like Seal but returns ErrKeyUsageLimit instead of
+// panicking when the limit has been reached.
+func (l *KeyUsageLimit) SealChecked(dst, nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if err := l.reserve(len(plaintext)); err != nil {
+		return nil, err
+	}
+	return l.aead.Seal(dst, nonce, plaintext, additionalData), nil
+}
+
+// Seal implements cipher.AEAD. It panics if the limit has been reached,
+// as cipher.AEAD offers no way to return an error.
+func (l *KeyUsageLimit) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	out, err := l.SealChecked(dst, nonce, plaintext, additionalData)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// Open implements cipher.AEAD. Opening does not count towards the limit.
+func (l *KeyUsageLimit) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return l.aead.Open(dst, nonce, ciphertext, additionalData)
+}
+
+// Usage returns the number of Seal operations and plaintext bytes
+// accounted so far.
+func (l *KeyUsageLimit) Usage() (messages, bytes uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.messages, l.bytes
+}
@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"testing"
+)
+
+func newLimitTestGCM(t *testing.T) *aesGCM {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g.(*aesGCM)
+}
+
+func TestKeyUsageLimitMessages(t *testing.T) {
+	const n = 3
+	l := NewKeyUsageLimit(newLimitTestGCM(t), n, 0)
+	nonce := make([]byte, gcmStandardNonceSize)
+	for i := 0; i < n; i++ {
+		nonce[0] = byte(i)
+		ct, err := l.SealChecked(nil, nonce, []byte("hello"), nil)
+		if err != nil {
+			t.Fatalf("Seal %d: %v", i, err)
+		}
+		if _, err := l.Open(nil, nonce, ct, nil); err != nil {
+			t.Fatalf("Open %d: %v", i, err)
+		}
+	}
+	nonce[0] = n
+	if _, err := l.SealChecked(nil, nonce, []byte("hello"), nil); err != ErrKeyUsageLimit {
+		t.Errorf("Seal %d: got %v, want ErrKeyUsageLimit", n, err)
+	}
+	assertPanic(t, func() { l.Seal(nil, nonce, []byte("hello"), nil) })
+	if msgs, b := l.Usage(); msgs != n || b != n*5 {
+		t.Errorf("Usage() = %d, %d; want %d, %d", msgs, b, n, n*5)
+	}
+}
+
+func TestKeyUsageLimitBytes(t *testing.T) {
+	l := NewKeyUsageLimit(newLimitTestGCM(t), 0, 10)
+	nonce := make([]byte, gcmStandardNonceSize)
+	if _, err := l.SealChecked(nil, nonce, make([]byte, 6), nil); err != nil {
+		t.Fatal(err)
+	}
+	nonce[0] = 1
+	if _, err := l.SealChecked(nil, nonce, make([]byte, 5), nil); err != ErrKeyUsageLimit {
+		t.Errorf("got %v, want ErrKeyUsageLimit", err)
+	}
+	// A rejected Seal is not accounted, so a smaller one still fits.
+	if _, err := l.SealChecked(nil, nonce, make([]byte, 4), nil); err != nil {
+		t.Error(err)
+	}
+}
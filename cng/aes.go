@@ -9,7 +9,9 @@ package cng
 import (
 	"crypto/cipher"
 	"errors"
+	"math"
 	"runtime"
+	"sync"
 	"unsafe"
 
 	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
@@ -40,6 +42,13 @@ func (c *aesCipher) finalize() {
 
 func (c *aesCipher) BlockSize() int { return aesBlockSize }
 
+// Handle returns the underlying BCRYPT_KEY_HANDLE, which uses the ECB chaining mode,
+// for interoperability with other CNG APIs.
+//
+// The handle is owned by c: the caller must not destroy it, and must keep c
+// reachable, for example using runtime.KeepAlive, while using the handle.
+func (c *aesCipher) Handle() uintptr { return uintptr(c.kh) }
+
 func (c *aesCipher) Encrypt(dst, src []byte) {
 	if subtle.InexactOverlap(dst, src) {
 		panic("crypto/cipher: invalid buffer overlap")
@@ -91,11 +100,40 @@ func (c *aesCipher) NewCBCDecrypter(iv []byte) cipher.BlockMode {
 	return newCBC(false, bcrypt.AES_ALGORITHM, c.key, iv)
 }
 
+// NewCTR returns a cipher.Stream which encrypts or decrypts in counter
+// mode, starting with the counter block iv, which must be one block long.
+// The counter is incremented as a 128-bit big-endian integer, as
+// crypto/cipher.NewCTR does; cipher.NewCTR uses this method when given c.
+//
+// CNG has no counter chaining mode, so the key stream is generated by
+// encrypting counter blocks in ECB mode, many blocks per call. The unused
+// part of the last block is kept for the next call, so splitting the input
+// across calls produces the same output as a single call.
+func (c *aesCipher) NewCTR(iv []byte) cipher.Stream {
+	if len(iv) != aesBlockSize {
+		panic("cipher.NewCTR: IV length must equal block size")
+	}
+	s := &aesCTR{block: c}
+	copy(s.ctr[:], iv)
+	return s
+}
+
 type noGCM struct {
 	cipher.Block
 }
 
+// NewGCM returns an AES-GCM AEAD with the given nonce and tag sizes.
+// The standard sizes, a 12-byte nonce and a 16-byte tag, are handled
+// entirely by CNG. With the standard nonce size, CNG also supports tags
+// of 12 to 16 bytes; see GCMTagLengths. Shorter tags, such as the 8-byte
+// tags of SRTP (RFC 7714), are rejected. Any other positive nonce size
+// is supported with the standard tag size through the standard library,
+// which derives the counter block with GHASH. A non-standard nonce and
+// tag can't be combined, and the nonce must not be empty.
 func (c *aesCipher) NewGCM(nonceSize, tagSize int) (cipher.AEAD, error) {
+	if nonceSize <= 0 {
+		return nil, errors.New("crypto/aes: invalid GCM nonce size " + itoa(nonceSize) + ", the nonce must not be empty")
+	}
 	if nonceSize != gcmStandardNonceSize && tagSize != gcmTagSize {
 		return nil, errors.New("crypto/aes: GCM tag and nonce sizes can't be non-standard at the same time")
 	}
@@ -104,11 +142,74 @@ func (c *aesCipher) NewGCM(nonceSize, tagSize int) (cipher.AEAD, error) {
 		return cipher.NewGCMWithNonceSize(&noGCM{c}, nonceSize)
 	}
 	if tagSize != gcmTagSize {
-		return cipher.NewGCMWithTagSize(&noGCM{c}, tagSize)
+		// Shorter tags are handled by CNG down to the minimum length
+		// it supports, which is 12 bytes.
+		if err := checkGCMTagSize(tagSize); err != nil {
+			return nil, err
+		}
+		g, err := newGCM(c.key, false)
+		if err != nil {
+			return nil, err
+		}
+		g.tagSize = tagSize
+		return g, nil
 	}
 	return newGCM(c.key, false)
 }
 
+// checkGCMTagSize validates tagSize against the tag lengths supported
+// by the CNG GCM provider, so that unsupported sizes are rejected when
+// the AEAD is constructed rather than when it is first used.
+// If the provider can't be queried, the standard library bounds are used.
+func checkGCMTagSize(tagSize int) error {
+	min, max, increment, err := GCMTagLengths()
+	if err != nil {
+		min, max, increment = gcmMinimumTagSize, gcmTagSize, 1
+	}
+	if tagSize < int(min) || tagSize > int(max) || increment == 0 || (tagSize-int(min))%int(increment) != 0 {
+		return errors.New("crypto/aes: unsupported GCM tag size " + itoa(tagSize) + ", CNG supports " + itoa(int(min)) + " to " + itoa(int(max)) + " bytes in steps of " + itoa(int(increment)))
+	}
+	return nil
+}
+
+// NewGCMFromKey returns an AES-GCM AEAD with the standard nonce and tag
+// sizes for the given 16, 24 or 32-byte key. It is equivalent to
+// NewAESCipher followed by NewGCM(12, 16), without the intermediate
+// ECB key handle.
+func NewGCMFromKey(key []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("crypto/aes: invalid key size " + itoa(len(key)))
+	}
+	return newGCM(key, false)
+}
+
+// NewAES256GCM is like NewGCMFromKey but only accepts 32-byte keys,
+// for deployments whose policy forbids AES-128 and AES-192.
+func NewAES256GCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("crypto/aes: AES-256 requires a 32-byte key, got " + itoa(len(key)) + " bytes")
+	}
+	return newGCM(key, false)
+}
+
+// GCMHashSubkey returns the GHASH subkey H that AES-GCM derives from key,
+// which is the encryption of the all-zero block, E_K(0^128), as defined
+// in NIST SP 800-38D, Section 6.4.
+//
+// H must be kept as secret as key: it is only meant for protocols that
+// deliberately reuse it, as knowing H allows forging GCM tags.
+func GCMHashSubkey(key []byte) ([16]byte, error) {
+	var h [16]byte
+	c, err := NewAESCipher(key)
+	if err != nil {
+		return h, err
+	}
+	c.Encrypt(h[:], h[:])
+	return h, nil
+}
+
 // NewGCMTLS returns a GCM cipher specific to TLS
 // and should not be used for non-TLS purposes.
 func NewGCMTLS(c cipher.Block) (cipher.AEAD, error) {
@@ -154,6 +255,15 @@ func (x *cbcCipher) finalize() {
 
 func (x *cbcCipher) BlockSize() int { return x.blockSize }
 
+// Handle returns the underlying BCRYPT_KEY_HANDLE, which uses the CBC chaining mode.
+// The IV is not stored in the handle but passed on each call.
+// See aesCipher.Handle for the ownership rules.
+func (x *cbcCipher) Handle() uintptr { return uintptr(x.kh) }
+
+// CryptBlocks encrypts or decrypts src into dst. The whole buffer
+// is passed to CNG in a single call, unless it is larger than what
+// a single call can handle. CNG updates x.iv with the last ciphertext
+// block, so the chain carries over to the next call.
 func (x *cbcCipher) CryptBlocks(dst, src []byte) {
 	if subtle.InexactOverlap(dst, src) {
 		panic("crypto/cipher: invalid buffer overlap")
@@ -164,25 +274,29 @@ func (x *cbcCipher) CryptBlocks(dst, src []byte) {
 	if len(dst) < len(src) {
 		panic("crypto/cipher: output smaller than input")
 	}
-	if len(src) == 0 {
-		return
-	}
-	var ret uint32
-	var err error
-	if x.encrypt {
-		err = bcrypt.Encrypt(x.kh, src, nil, x.iv[:x.blockSize], dst, &ret, 0)
-	} else {
-		err = bcrypt.Decrypt(x.kh, src, nil, x.iv[:x.blockSize], dst, &ret, 0)
-	}
-	if err != nil {
-		panic(err)
-	}
-	if int(ret) != len(src) {
-		panic("crypto/aes: plaintext not fully encrypted")
+	for len(src) > 0 {
+		n := len32(src)
+		n -= n % x.blockSize
+		var ret uint32
+		var err error
+		if x.encrypt {
+			err = bcrypt.Encrypt(x.kh, src[:n], nil, x.iv[:x.blockSize], dst[:n], &ret, 0)
+		} else {
+			err = bcrypt.Decrypt(x.kh, src[:n], nil, x.iv[:x.blockSize], dst[:n], &ret, 0)
+		}
+		if err != nil {
+			panic(err)
+		}
+		if int(ret) != n {
+			panic("crypto/aes: plaintext not fully encrypted")
+		}
+		src, dst = src[n:], dst[n:]
 	}
 	runtime.KeepAlive(x)
 }
 
+// SetIV sets the chaining IV used by the next CryptBlocks call.
+// It panics if iv is not exactly one block long.
 func (x *cbcCipher) SetIV(iv []byte) {
 	if len(iv) != x.blockSize {
 		panic("cipher: incorrect length IV")
@@ -190,16 +304,46 @@ func (x *cbcCipher) SetIV(iv []byte) {
 	copy(x.iv[:], iv)
 }
 
+// IV returns a copy of the current chaining IV, which is the last
+// ciphertext block processed so far. Passing it to SetIV on a fresh
+// BlockMode with the same key resumes the chain where it stopped.
+func (x *cbcCipher) IV() []byte {
+	return append([]byte(nil), x.iv[:x.blockSize]...)
+}
+
 const (
 	gcmTagSize           = 16
+	gcmMinimumTagSize    = 12
 	gcmStandardNonceSize = 12
 	gcmTlsAddSize        = 13
 	gcmTlsFixedNonceSize = 4
 )
 
+// GCMTagLengths returns the authentication tag lengths, in bytes,
+// supported by the CNG AES-GCM provider. Supported lengths range from
+// min to max, inclusive, in steps of increment.
+func GCMTagLengths() (min, max, increment uint32, err error) {
+	h, err := loadCipher(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_GCM)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	lengths, err := getAuthTagLengths(bcrypt.HANDLE(h.handle))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return lengths.MinLength, lengths.MaxLength, lengths.Increment, nil
+}
+
+// aesGCM is safe for concurrent use by multiple goroutines,
+// as required by the cipher.AEAD contract.
 type aesGCM struct {
-	kh           bcrypt.KEY_HANDLE
-	tls          bool
+	kh      bcrypt.KEY_HANDLE
+	tls     bool
+	tagSize int
+
+	// mu serializes the operations on kh, as CNG key handles
+	// don't support concurrent use, and protects minNextNonce.
+	mu           sync.Mutex
 	minNextNonce uint64
 }
 
@@ -212,57 +356,110 @@ func newGCM(key []byte, tls bool) (*aesGCM, error) {
 	if err != nil {
 		return nil, err
 	}
-	g := &aesGCM{kh: kh, tls: tls}
+	g := &aesGCM{kh: kh, tls: tls, tagSize: gcmTagSize}
 	runtime.SetFinalizer(g, (*aesGCM).finalize)
 	return g, nil
 }
 
+// Handle returns the underlying BCRYPT_KEY_HANDLE, which uses the GCM chaining mode.
+// Using the handle bypasses the serialization done by Seal and Open,
+// so the caller must not use it concurrently with them.
+// See aesCipher.Handle for the ownership rules.
+func (g *aesGCM) Handle() uintptr { return uintptr(g.kh) }
+
 func (g *aesGCM) NonceSize() int {
 	return gcmStandardNonceSize
 }
 
 func (g *aesGCM) Overhead() int {
-	return gcmTagSize
+	return g.tagSize
 }
 
+// checkTLSCounter reports why Seal would reject the explicit nonce
+// counter of a TLS AEAD. g.mu must be held.
+func (g *aesGCM) checkTLSCounter(counter uint64) error {
+	// BoringCrypto enforces strictly monotonically increasing explicit nonces
+	// and to fail after 2^64 - 1 keys as per FIPS 140-2 IG A.5,
+	// but BCrypt does not perform this check, so it is implemented here.
+	const maxUint64 = 1<<64 - 1
+	if counter == maxUint64 {
+		return errors.New("cipher: nonce counter must be less than 2^64 - 1")
+	}
+	if counter < g.minNextNonce {
+		return errors.New("cipher: nonce counter must be strictly monotonically increasing")
+	}
+	return nil
+}
+
+// TLSNonceCounter returns the smallest explicit nonce counter the next
+// Seal of a TLS AEAD, as returned by NewGCMTLS, will accept: one more than
+// the counter of the last sealed record, or 0 before the first one.
+// ok is false if the AEAD doesn't enforce TLS nonces.
+// It doesn't modify the AEAD, so it can be used for diagnostics.
+func (g *aesGCM) TLSNonceCounter() (next uint64, ok bool) {
+	if !g.tls {
+		return 0, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.minNextNonce, true
+}
+
+// CheckTLSNonce returns the reason why Seal would reject nonce on a TLS
+// AEAD, as returned by NewGCMTLS, or nil if it would be accepted.
+// Like TLSNonceCounter, it doesn't modify the AEAD.
+func (g *aesGCM) CheckTLSNonce(nonce []byte) error {
+	if !g.tls {
+		return errors.New("cng: AEAD doesn't enforce TLS nonces")
+	}
+	if len(nonce) != gcmStandardNonceSize {
+		return errors.New("cipher: incorrect nonce length given to GCM")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.checkTLSCounter(bigUint64(nonce[gcmTlsFixedNonceSize:]))
+}
+
+// Seal encrypts and authenticates plaintext and appends the result to dst.
+// If dst has room for len(plaintext)+Overhead() more bytes, for example
+// dst = make([]byte, 0, len(plaintext)+g.Overhead()), the result is
+// written in place and Seal doesn't allocate.
 func (g *aesGCM) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 	if len(nonce) != gcmStandardNonceSize {
 		panic("cipher: incorrect nonce length given to GCM")
 	}
-	if uint64(len(plaintext)) > ((1<<32)-2)*aesBlockSize || len(plaintext)+gcmTagSize < len(plaintext) {
-		panic("cipher: message too large for GCM")
+	if err := checkGCMLengths(uint64(len(plaintext)), uint64(len(additionalData))); err != nil {
+		panic(err.Error())
 	}
-	if len(dst)+len(plaintext)+gcmTagSize < len(dst) {
+	if len(dst)+len(plaintext)+g.tagSize < len(dst) {
 		panic("cipher: message too large for buffer")
 	}
+	if hook := auditHook(); hook != nil {
+		hook(AuditEvent{Op: AuditSeal, Algorithm: "AES-GCM", KeyBits: keyBits(g.kh)})
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if g.tls {
 		if len(additionalData) != gcmTlsAddSize {
 			panic("cipher: incorrect additional data length given to GCM TLS")
 		}
-		// BoringCrypto enforces strictly monotonically increasing explicit nonces
-		// and to fail after 2^64 - 1 keys as per FIPS 140-2 IG A.5,
-		// but BCrypt does not perform this check, so it is implemented here.
-		const maxUint64 = 1<<64 - 1
 		counter := bigUint64(nonce[gcmTlsFixedNonceSize:])
-		if counter == maxUint64 {
-			panic("cipher: nonce counter must be less than 2^64 - 1")
-		}
-		if counter < g.minNextNonce {
-			panic("cipher: nonce counter must be strictly monotonically increasing")
+		if err := g.checkTLSCounter(counter); err != nil {
+			panic(err.Error())
 		}
 		defer func() {
 			g.minNextNonce = counter + 1
 		}()
 	}
 	// Make room in dst to append plaintext+overhead.
-	ret, out := sliceForAppend(dst, len(plaintext)+gcmTagSize)
+	ret, out := sliceForAppend(dst, len(plaintext)+g.tagSize)
 
 	// Check delayed until now to make sure len(dst) is accurate.
 	if subtle.InexactOverlap(out, plaintext) {
 		panic("cipher: invalid buffer overlap")
 	}
 
-	info := bcrypt.NewAUTHENTICATED_CIPHER_MODE_INFO(nonce, additionalData, out[len(out)-gcmTagSize:])
+	info := bcrypt.NewAUTHENTICATED_CIPHER_MODE_INFO(nonce, additionalData, out[len(out)-g.tagSize:])
 	var encSize uint32
 	err := bcrypt.Encrypt(g.kh, plaintext, unsafe.Pointer(info), nil, out, &encSize, 0)
 	if err != nil {
@@ -275,21 +472,96 @@ func (g *aesGCM) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 	return ret
 }
 
+// SealMultiAAD is like Seal, but takes the additional data as several
+// slices, which are authenticated as if they were concatenated in order.
+//
+// CNG only accepts the additional data as a single buffer, so more than
+// one slice is joined into a buffer allocated for the call.
+func (g *aesGCM) SealMultiAAD(dst, nonce, plaintext []byte, additionalData ...[]byte) []byte {
+	switch len(additionalData) {
+	case 0:
+		return g.Seal(dst, nonce, plaintext, nil)
+	case 1:
+		return g.Seal(dst, nonce, plaintext, additionalData[0])
+	}
+	var n int
+	for _, b := range additionalData {
+		n += len(b)
+	}
+	aad := make([]byte, 0, n)
+	for _, b := range additionalData {
+		aad = append(aad, b...)
+	}
+	return g.Seal(dst, nonce, plaintext, aad)
+}
+
 var errOpen = errors.New("cipher: message authentication failed")
 
+// The GCM input length limits of NIST SP 800-38D, Section 5.2.1.1,
+// are 2^39-256 bits of plaintext and 2^64-1 bits of additional data.
+const (
+	gcmMaxPlaintextSize = ((1 << 32) - 2) * aesBlockSize
+	gcmMaxAADSize       = 1<<61 - 1
+)
+
+var (
+	errGCMMessageTooLarge = errors.New("cipher: message too large for GCM")
+	errGCMAADTooLarge     = errors.New("cipher: additional data too large for GCM")
+	errCNGMessageTooLarge = errors.New("cng: message too large for a single CNG GCM call")
+	errCNGAADTooLarge     = errors.New("cng: additional data too large for a single CNG GCM call")
+)
+
+// checkGCMLengths checks the length of the plaintext, or of the ciphertext
+// without the tag, and of the additional data against the GCM limits.
+// CNG takes each buffer in a single call, with a length that must fit
+// in a Win32 LONG like len32 ensures, which is a tighter bound; it is
+// checked separately so that the spec limits stay documented.
+func checkGCMLengths(textLen, aadLen uint64) error {
+	switch {
+	case textLen > gcmMaxPlaintextSize:
+		return errGCMMessageTooLarge
+	case aadLen > gcmMaxAADSize:
+		return errGCMAADTooLarge
+	case textLen > math.MaxInt32:
+		return errCNGMessageTooLarge
+	case aadLen > math.MaxInt32:
+		return errCNGAADTooLarge
+	}
+	return nil
+}
+
 func (g *aesGCM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
 	if len(nonce) != gcmStandardNonceSize {
 		panic("cipher: incorrect nonce length given to GCM")
 	}
-	if len(ciphertext) < gcmTagSize {
+	if len(ciphertext) < g.tagSize {
 		return nil, errOpen
 	}
-	if uint64(len(ciphertext)) > ((1<<32)-2)*aesBlockSize+gcmTagSize {
+	tag := ciphertext[len(ciphertext)-g.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-g.tagSize]
+	return g.open(dst, nonce, ciphertext, tag, additionalData)
+}
+
+// OpenWithTag is like Open, but takes the authentication tag separately
+// from the ciphertext, for wire formats that don't append the tag to it.
+// The tag must be exactly Overhead bytes long.
+func (g *aesGCM) OpenWithTag(dst, nonce, ciphertext, tag, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmStandardNonceSize {
+		panic("cipher: incorrect nonce length given to GCM")
+	}
+	if len(tag) != g.tagSize {
 		return nil, errOpen
 	}
+	return g.open(dst, nonce, ciphertext, tag, additionalData)
+}
 
-	tag := ciphertext[len(ciphertext)-gcmTagSize:]
-	ciphertext = ciphertext[:len(ciphertext)-gcmTagSize]
+func (g *aesGCM) open(dst, nonce, ciphertext, tag, additionalData []byte) ([]byte, error) {
+	if err := checkGCMLengths(uint64(len(ciphertext)), uint64(len(additionalData))); err != nil {
+		return nil, err
+	}
+	if hook := auditHook(); hook != nil {
+		hook(AuditEvent{Op: AuditOpen, Algorithm: "AES-GCM", KeyBits: keyBits(g.kh)})
+	}
 
 	// Make room in dst to append ciphertext without tag.
 	ret, out := sliceForAppend(dst, len(ciphertext))
@@ -301,7 +573,9 @@ func (g *aesGCM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, er
 
 	info := bcrypt.NewAUTHENTICATED_CIPHER_MODE_INFO(nonce, additionalData, tag)
 	var decSize uint32
+	g.mu.Lock()
 	err := bcrypt.Decrypt(g.kh, ciphertext, unsafe.Pointer(info), nil, out, &decSize, 0)
+	g.mu.Unlock()
 	if err != nil || int(decSize) != len(ciphertext) {
 		for i := range out {
 			out[i] = 0
@@ -329,3 +603,62 @@ func bigUint64(b []byte) uint64 {
 	return uint64(b[7]) | uint64(b[6])<<8 | uint64(b[5])<<16 | uint64(b[4])<<24 |
 		uint64(b[3])<<32 | uint64(b[2])<<40 | uint64(b[1])<<48 | uint64(b[0])<<56
 }
+
+// ctrMaxKeyStream bounds the key stream generated at once by aesCTR and
+// the CCM streams, so that large inputs don't allocate a key stream of
+// their size.
+const ctrMaxKeyStream = 32 * 1024
+
+type aesCTR struct {
+	block     *aesCipher
+	ctr       [aesBlockSize]byte
+	keyStream []byte // unused key stream from the last call
+}
+
+func (s *aesCTR) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("crypto/cipher: output smaller than input")
+	}
+	if subtle.InexactOverlap(dst[:len(src)], src) {
+		panic("crypto/cipher: invalid buffer overlap")
+	}
+	for len(src) > 0 {
+		if len(s.keyStream) == 0 {
+			n := len(src)
+			if n > ctrMaxKeyStream {
+				n = ctrMaxKeyStream
+			}
+			s.keyStream = s.block.ctrKeyStream(&s.ctr, n)
+		}
+		n := len(src)
+		if n > len(s.keyStream) {
+			n = len(s.keyStream)
+		}
+		xorBytes(dst[:n], src[:n], s.keyStream)
+		s.keyStream = s.keyStream[n:]
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// ctrKeyStream returns at least n bytes of AES-CTR key stream, rounded up
+// to a whole number of blocks, starting with the counter block ctr,
+// and advances ctr past the generated blocks.
+// The counter is incremented as a 128-bit big-endian integer.
+// The whole key stream is encrypted using a single ECB call.
+func (c *aesCipher) ctrKeyStream(ctr *[aesBlockSize]byte, n int) []byte {
+	stream := make([]byte, (n+aesBlockSize-1)/aesBlockSize*aesBlockSize)
+	if len(stream) == 0 {
+		return stream
+	}
+	for i := 0; i < len(stream); i += aesBlockSize {
+		copy(stream[i:], ctr[:])
+		for j := aesBlockSize - 1; j >= 0; j-- {
+			ctr[j]++
+			if ctr[j] != 0 {
+				break
+			}
+		}
+	}
+	c.Encrypt(stream, stream)
+	return stream
+}
@@ -8,8 +8,18 @@ package cng
 
 import (
 	"bytes"
+	"crypto/aes"
 	"crypto/cipher"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
+	"unsafe"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
 )
 
 var key = []byte("D249BF6DEC97B1EBD69BC4D6B3A3C49D")
@@ -38,6 +48,99 @@ func TestNewGCMNonce(t *testing.T) {
 	}
 }
 
+func TestNewGCMZeroNonce(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ci.(*aesCipher)
+	for _, nonceSize := range []int{0, -1} {
+		if _, err := c.NewGCM(nonceSize, gcmTagSize); err == nil {
+			t.Errorf("expected error for nonce size %d, got none", nonceSize)
+		}
+	}
+	gcm, err := c.NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	sealed := gcm.Seal(nil, nonce, []byte("plaintext"), nil)
+	if got, err := gcm.Open(nil, nonce, sealed, nil); err != nil || string(got) != "plaintext" {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+}
+
+func TestNewGCMTagSize(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ci.(*aesCipher)
+	min, max, increment, err := GCMTagLengths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tagSize := range []int{0, int(min) - 1, int(max) + 1, 32} {
+		if _, err := c.NewGCM(gcmStandardNonceSize, tagSize); err == nil {
+			t.Errorf("expected error for tag size %d", tagSize)
+		}
+	}
+	for tagSize := int(min); tagSize <= int(max); tagSize += int(increment) {
+		if _, err := c.NewGCM(gcmStandardNonceSize, tagSize); err != nil {
+			t.Errorf("unexpected error for tag size %d: %v", tagSize, err)
+		}
+	}
+}
+
+func TestGCMShortTag(t *testing.T) {
+	const tagSize = 8 // As used by SRTP, RFC 7714; below the CNG minimum.
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ci.(*aesCipher)
+	min, _, _, err := GCMTagLengths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := c.NewGCM(gcmStandardNonceSize, tagSize)
+	if min > tagSize {
+		if err == nil {
+			t.Fatalf("expected error for tag size %d below the CNG minimum %d", tagSize, min)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcm.Overhead() != tagSize {
+		t.Errorf("Overhead() = %d, want %d", gcm.Overhead(), tagSize)
+	}
+	full, err := c.NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmStandardNonceSize)
+	plainText := []byte("short tag plaintext")
+	additionalData := []byte("header")
+	sealed := gcm.Seal(nil, nonce, plainText, additionalData)
+	// A truncated GCM tag is a prefix of the full tag.
+	if want := full.Seal(nil, nonce, plainText, additionalData)[:len(plainText)+tagSize]; !bytes.Equal(sealed, want) {
+		t.Errorf("Seal() = %x, want %x", sealed, want)
+	}
+	decrypted, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plainText) {
+		t.Errorf("Open() = %x, want %x", decrypted, plainText)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := gcm.Open(nil, nonce, sealed, additionalData); err == nil {
+		t.Error("Open succeeded with a tampered tag")
+	}
+}
+
 func TestSealAndOpen(t *testing.T) {
 	ci, err := NewAESCipher(key)
 	if err != nil {
@@ -70,6 +173,58 @@ func TestSealAndOpen(t *testing.T) {
 	}
 }
 
+func TestGCMTLSNonceCounter(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := NewGCMTLS(ci)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := aead.(*aesGCM)
+	if next, ok := g.TLSNonceCounter(); !ok || next != 0 {
+		t.Fatalf("TLSNonceCounter() = %d, %v before any Seal, want 0, true", next, ok)
+	}
+	nonceFor := func(counter byte) []byte {
+		return []byte{0xa, 0xb, 0xc, 0xd, 0, 0, 0, 0, 0, 0, 0, counter}
+	}
+	additionalData := make([]byte, gcmTlsAddSize)
+	for _, counter := range []byte{0, 1, 2, 7} {
+		if err := g.CheckTLSNonce(nonceFor(counter)); err != nil {
+			t.Fatalf("CheckTLSNonce(%d) = %v before sealing it", counter, err)
+		}
+		g.Seal(nil, nonceFor(counter), []byte("record"), additionalData)
+		if next, ok := g.TLSNonceCounter(); !ok || next != uint64(counter)+1 {
+			t.Errorf("TLSNonceCounter() = %d, %v after sealing %d", next, ok, counter)
+		}
+	}
+	// Checking doesn't advance the counter, and reports why Seal would panic.
+	for _, counter := range []byte{0, 7} {
+		if err := g.CheckTLSNonce(nonceFor(counter)); err == nil {
+			t.Errorf("CheckTLSNonce(%d) accepted a used counter", counter)
+		}
+	}
+	if err := g.CheckTLSNonce(nonceFor(8)); err != nil {
+		t.Errorf("CheckTLSNonce(8) = %v", err)
+	}
+	if next, _ := g.TLSNonceCounter(); next != 8 {
+		t.Errorf("TLSNonceCounter() = %d after checks, want 8", next)
+	}
+	max := []byte{0, 0, 0, 0, 255, 255, 255, 255, 255, 255, 255, 255}
+	if err := g.CheckTLSNonce(max); err == nil {
+		t.Error("CheckTLSNonce accepted the maximum counter")
+	}
+
+	plain := newTestGCM(t)
+	if _, ok := plain.TLSNonceCounter(); ok {
+		t.Error("TLSNonceCounter() reported a counter for a non-TLS AEAD")
+	}
+	if err := plain.CheckTLSNonce(nonceFor(0)); err == nil {
+		t.Error("CheckTLSNonce succeeded on a non-TLS AEAD")
+	}
+}
+
 func TestSealAndOpenTLS(t *testing.T) {
 	ci, err := NewAESCipher(key)
 	if err != nil {
@@ -338,3 +493,688 @@ func TestCBCDecryptSimple(t *testing.T) {
 		t.Errorf("decryption incorrect\nexp %v, got %v\n", plainText, decrypted)
 	}
 }
+
+func TestGCMOpenWithTag(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ci.(*aesCipher)
+	gcm, err := c.NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gcm.(interface {
+		OpenWithTag(dst, nonce, ciphertext, tag, additionalData []byte) ([]byte, error)
+	})
+	nonce := []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc}
+	plainText := []byte("detached tag plaintext")
+	additionalData := []byte("header")
+	sealed := gcm.Seal(nil, nonce, plainText, additionalData)
+	ciphertext, tag := sealed[:len(plainText)], sealed[len(plainText):]
+
+	want, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.OpenWithTag([]byte("prefix"), nonce, ciphertext, tag, additionalData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, append([]byte("prefix"), want...)) {
+		t.Errorf("OpenWithTag() = %x, want prefix || %x", got, want)
+	}
+
+	badTag := append([]byte(nil), tag...)
+	badTag[0] ^= 1
+	if _, err := g.OpenWithTag(nil, nonce, ciphertext, badTag, additionalData); err == nil {
+		t.Error("OpenWithTag succeeded with a modified tag")
+	}
+	if _, err := g.OpenWithTag(nil, nonce, ciphertext, tag[:gcmTagSize-1], additionalData); err == nil {
+		t.Error("OpenWithTag succeeded with a truncated tag")
+	}
+	if _, err := g.OpenWithTag(nil, nonce, ciphertext, tag, nil); err == nil {
+		t.Error("OpenWithTag succeeded with the wrong additional data")
+	}
+}
+
+func TestGCMSealMultiAAD(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gcm.(interface {
+		SealMultiAAD(dst, nonce, plaintext []byte, additionalData ...[]byte) []byte
+	})
+	nonce := make([]byte, gcmStandardNonceSize)
+	plainText := []byte("multi AAD plaintext")
+	tests := [][][]byte{
+		nil,
+		{[]byte("header")},
+		{[]byte("version"), []byte("type"), []byte("length")},
+		{nil, []byte("a"), {}, []byte("bc")},
+		{bytes.Repeat([]byte{1}, 100), bytes.Repeat([]byte{2}, 37)},
+	}
+	for i, aad := range tests {
+		want := gcm.Seal([]byte("prefix"), nonce, plainText, bytes.Join(aad, nil))
+		got := g.SealMultiAAD([]byte("prefix"), nonce, plainText, aad...)
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d: SealMultiAAD() = %x, want %x", i, got, want)
+		}
+		if _, err := gcm.Open(nil, nonce, got[len("prefix"):], bytes.Join(aad, nil)); err != nil {
+			t.Errorf("#%d: Open() with the concatenated additional data failed: %v", i, err)
+		}
+	}
+}
+
+// TestAuthenticatedCipherModeInfoLayout checks that the Go struct matches
+// BCRYPT_AUTHENTICATED_CIPHER_MODE_INFO as laid out by the C compiler,
+// which aligns cbData to 8 bytes also on 32-bit Windows.
+func TestAuthenticatedCipherModeInfoLayout(t *testing.T) {
+	var info bcrypt.AUTHENTICATED_CIPHER_MODE_INFO
+	size, dataOffset, flagsOffset := uintptr(88), uintptr(72), uintptr(80)
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		size, dataOffset, flagsOffset = 64, 48, 56
+	}
+	if got := unsafe.Sizeof(info); got != size {
+		t.Errorf("size = %d, want %d", got, size)
+	}
+	if got := unsafe.Offsetof(info.DataSize); got != dataOffset {
+		t.Errorf("DataSize offset = %d, want %d", got, dataOffset)
+	}
+	if got := unsafe.Offsetof(info.Flags); got != flagsOffset {
+		t.Errorf("Flags offset = %d, want %d", got, flagsOffset)
+	}
+}
+
+func TestGCMConcurrentSealOpen(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmStandardNonceSize)
+	plainText := []byte("concurrent seal and open")
+	additionalData := []byte{0x05, 0x05, 0x07}
+	want := gcm.Seal(nil, nonce, plainText, additionalData)
+
+	const goroutines = 64
+	const iterations = 100
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				sealed := gcm.Seal(nil, nonce, plainText, additionalData)
+				if !bytes.Equal(sealed, want) {
+					errs <- fmt.Errorf("unexpected sealed result: %x", sealed)
+					return
+				}
+				decrypted, err := gcm.Open(nil, nonce, sealed, additionalData)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(decrypted, plainText) {
+					errs <- fmt.Errorf("unexpected decrypted result: %x", decrypted)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestGCMTagLengths(t *testing.T) {
+	min, max, increment, err := GCMTagLengths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if min > gcmTagSize || max < gcmTagSize {
+		t.Fatalf("tag size %d not in reported range [%d, %d]", gcmTagSize, min, max)
+	}
+	if increment != 0 && (gcmTagSize-min)%increment != 0 {
+		t.Errorf("tag size %d not reachable from %d in steps of %d", gcmTagSize, min, increment)
+	}
+}
+
+func TestAESIsConstantTime(t *testing.T) {
+	ct, err := AESIsConstantTime()
+	switch runtime.GOARCH {
+	case "386", "amd64", "arm64":
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Logf("AESIsConstantTime() = %v, %v", ct, err)
+}
+
+func TestCBCLargeBuffer(t *testing.T) {
+	block, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, aesBlockSize)
+	plainText := make([]byte, 1<<20)
+	for i := range plainText {
+		plainText[i] = byte(i)
+	}
+
+	// A single call over the whole buffer must produce the same result
+	// as many small calls, which carry the IV forward.
+	oneCall := make([]byte, len(plainText))
+	block.(*aesCipher).NewCBCEncrypter(iv).CryptBlocks(oneCall, plainText)
+	manyCalls := make([]byte, len(plainText))
+	enc := block.(*aesCipher).NewCBCEncrypter(iv)
+	for i := 0; i < len(plainText); i += 64 {
+		enc.CryptBlocks(manyCalls[i:i+64], plainText[i:i+64])
+	}
+	if !bytes.Equal(oneCall, manyCalls) {
+		t.Fatal("single CryptBlocks call differs from multiple calls")
+	}
+
+	decrypted := make([]byte, len(plainText))
+	block.(*aesCipher).NewCBCDecrypter(iv).CryptBlocks(decrypted, oneCall)
+	if !bytes.Equal(decrypted, plainText) {
+		t.Error("unexpected decrypted result")
+	}
+}
+
+func TestCBCResumeIV(t *testing.T) {
+	block, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, aesBlockSize)
+	plainText := make([]byte, 8*aesBlockSize)
+	for i := range plainText {
+		plainText[i] = byte(i)
+	}
+	want := make([]byte, len(plainText))
+	block.(*aesCipher).NewCBCEncrypter(iv).CryptBlocks(want, plainText)
+
+	// Stop halfway, persist the IV and resume with a new BlockMode.
+	const half = 4 * aesBlockSize
+	got := make([]byte, len(plainText))
+	enc := block.(*aesCipher).NewCBCEncrypter(iv).(*cbcCipher)
+	enc.CryptBlocks(got[:half], plainText[:half])
+	saved := enc.IV()
+	if !bytes.Equal(saved, got[half-aesBlockSize:half]) {
+		t.Errorf("IV() = %x, want last ciphertext block %x", saved, got[half-aesBlockSize:half])
+	}
+	resumed := block.(*aesCipher).NewCBCEncrypter(iv).(*cbcCipher)
+	resumed.SetIV(saved)
+	resumed.CryptBlocks(got[half:], plainText[half:])
+	if !bytes.Equal(got, want) {
+		t.Error("resumed encryption differs from uninterrupted run")
+	}
+
+	dec := block.(*aesCipher).NewCBCDecrypter(iv).(*cbcCipher)
+	dec.SetIV(saved)
+	decrypted := make([]byte, len(plainText)-half)
+	dec.CryptBlocks(decrypted, got[half:])
+	if !bytes.Equal(decrypted, plainText[half:]) {
+		t.Error("resumed decryption mismatch")
+	}
+	assertPanic(t, func() { resumed.SetIV(iv[:aesBlockSize-1]) })
+}
+
+func BenchmarkCBCEncrypt1M(b *testing.B) {
+	block, err := NewAESCipher(key)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 1<<20)
+	enc := block.(*aesCipher).NewCBCEncrypter(make([]byte, aesBlockSize))
+	for _, chunk := range []int{len(buf), 4096, aesBlockSize} {
+		b.Run(fmt.Sprintf("chunk-%d", chunk), func(b *testing.B) {
+			b.SetBytes(int64(len(buf)))
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < len(buf); j += chunk {
+					enc.CryptBlocks(buf[j:j+chunk], buf[j:j+chunk])
+				}
+			}
+		})
+	}
+}
+
+func TestAESHandle(t *testing.T) {
+	block, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, ok := block.(interface{ Handle() uintptr })
+	if !ok {
+		t.Fatal("AES cipher does not implement Handle")
+	}
+	kh := bcrypt.KEY_HANDLE(h.Handle())
+	if kh == 0 {
+		t.Fatal("Handle() = 0")
+	}
+	src := []byte("0123456789abcdef")
+	want := make([]byte, aesBlockSize)
+	block.Encrypt(want, src)
+	got := make([]byte, aesBlockSize)
+	var ret uint32
+	if err := bcrypt.Encrypt(kh, src, nil, nil, got, &ret, 0); err != nil {
+		t.Fatal(err)
+	}
+	runtime.KeepAlive(block)
+	if !bytes.Equal(got, want) {
+		t.Errorf("BCryptEncrypt = %x, want %x", got, want)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcm.(interface{ Handle() uintptr }).Handle() == 0 {
+		t.Error("GCM Handle() = 0")
+	}
+	cbc := cipher.NewCBCEncrypter(block, make([]byte, aesBlockSize))
+	if cbc.(interface{ Handle() uintptr }).Handle() == 0 {
+		t.Error("CBC Handle() = 0")
+	}
+}
+
+func newTestGCM(tb testing.TB) *aesGCM {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	g, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return g.(*aesGCM)
+}
+
+func TestGCMSealPresizedNoAlloc(t *testing.T) {
+	g := newTestGCM(t)
+	nonce := make([]byte, gcmStandardNonceSize)
+	plaintext := make([]byte, 1024)
+	ad := []byte("ad")
+	dst := make([]byte, 0, len(plaintext)+g.Overhead())
+	var out []byte
+	allocs := testing.AllocsPerRun(100, func() {
+		out = g.Seal(dst, nonce, plaintext, ad)
+	})
+	if allocs != 0 {
+		t.Errorf("Seal into a pre-sized dst allocated %v times, want 0", allocs)
+	}
+	if &out[0] != &dst[:1][0] {
+		t.Error("Seal did not write into the capacity of dst")
+	}
+	if want := g.Seal(nil, nonce, plaintext, ad); !bytes.Equal(out, want) {
+		t.Error("Seal into a pre-sized dst gave a different result")
+	}
+}
+
+func BenchmarkGCMSealPresized(b *testing.B) {
+	g := newTestGCM(b)
+	nonce := make([]byte, gcmStandardNonceSize)
+	plaintext := make([]byte, 1024)
+	dst := make([]byte, 0, len(plaintext)+g.Overhead())
+	b.SetBytes(int64(len(plaintext)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		g.Seal(dst, nonce, plaintext, nil)
+	}
+}
+
+func TestGCMLengthLimits(t *testing.T) {
+	for _, tt := range []struct {
+		text, aad uint64
+		want      error
+	}{
+		{0, 0, nil},
+		{math.MaxInt32, math.MaxInt32, nil},
+		{math.MaxInt32 + 1, 0, errCNGMessageTooLarge},
+		{0, math.MaxInt32 + 1, errCNGAADTooLarge},
+		{gcmMaxPlaintextSize, 0, errCNGMessageTooLarge},
+		{gcmMaxPlaintextSize + 1, 0, errGCMMessageTooLarge},
+		{0, gcmMaxAADSize, errCNGAADTooLarge},
+		{0, gcmMaxAADSize + 1, errGCMAADTooLarge},
+		{math.MaxUint64, 0, errGCMMessageTooLarge},
+		{0, math.MaxUint64, errGCMAADTooLarge},
+	} {
+		if got := checkGCMLengths(tt.text, tt.aad); got != tt.want {
+			t.Errorf("checkGCMLengths(%d, %d) = %v, want %v", tt.text, tt.aad, got, tt.want)
+		}
+	}
+}
+
+func TestGCMLargeAdditionalData(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	if strconv.IntSize < 64 {
+		t.Skip("skipping: requires a 64-bit platform")
+	}
+	// The additional data is one byte longer than CNG takes,
+	// so the test only runs on request as it needs 2 GiB of memory.
+	if os.Getenv("GO_TEST_LARGE_ALLOC") == "" {
+		t.Skip("skipping: set GO_TEST_LARGE_ALLOC to run")
+	}
+	g := newTestGCM(t)
+	nonce := make([]byte, gcmStandardNonceSize)
+	size := int64(math.MaxInt32) + 1
+	ad := make([]byte, size)
+	if _, err := g.Open(nil, nonce, make([]byte, gcmTagSize), ad); err != errCNGAADTooLarge {
+		t.Errorf("Open with too large additional data: got %v, want %v", err, errCNGAADTooLarge)
+	}
+	assertPanic(t, func() { g.Seal(nil, nonce, nil, ad) })
+	// The largest additional data CNG takes is accepted.
+	sealed := g.Seal(nil, nonce, nil, ad[:math.MaxInt32])
+	if _, err := g.Open(nil, nonce, sealed, ad[:math.MaxInt32]); err != nil {
+		t.Errorf("Open at the additional data limit: %v", err)
+	}
+}
+
+func TestGCMTagSizeMismatch(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ci.(*aesCipher)
+	full, err := c.NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short, err := c.NewGCM(gcmStandardNonceSize, gcmMinimumTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmStandardNonceSize)
+	plainText := []byte("tag size mismatch plaintext")
+	additionalData := []byte("header")
+	for _, tt := range []struct {
+		name       string
+		seal, open cipher.AEAD
+	}{
+		{"seal16-open12", full, short},
+		{"seal12-open16", short, full},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed := tt.seal.Seal(nil, nonce, plainText, additionalData)
+			// The consumer splits the tag at the wrong offset,
+			// which must fail authentication rather than return
+			// plaintext with some tag bytes appended or missing.
+			dst := make([]byte, 0, len(sealed))
+			got, err := tt.open.Open(dst, nonce, sealed, additionalData)
+			if err != errOpen {
+				t.Fatalf("Open() error = %v, want %v", err, errOpen)
+			}
+			if got != nil {
+				t.Errorf("Open() = %x, want nil", got)
+			}
+			// Nothing is left in the spare capacity of dst.
+			if leaked := dst[:cap(dst)]; !bytes.Equal(leaked, make([]byte, len(leaked))) {
+				t.Errorf("Open() left %x in dst", leaked)
+			}
+		})
+	}
+}
+
+func TestNewGCMFromKey(t *testing.T) {
+	nonce := make([]byte, gcmStandardNonceSize)
+	plaintext := []byte("one step GCM")
+	for _, size := range []int{16, 24, 32} {
+		k := key[:size]
+		g, err := NewGCMFromKey(k)
+		if err != nil {
+			t.Fatalf("NewGCMFromKey(%d bytes): %v", size, err)
+		}
+		if g.NonceSize() != gcmStandardNonceSize || g.Overhead() != gcmTagSize {
+			t.Errorf("NonceSize() = %d, Overhead() = %d", g.NonceSize(), g.Overhead())
+		}
+		sealed := g.Seal(nil, nonce, plaintext, nil)
+		// It interoperates with the two-step construction.
+		ci, err := NewAESCipher(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g2, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := g2.Seal(nil, nonce, plaintext, nil); !bytes.Equal(sealed, want) {
+			t.Errorf("%d-byte key: Seal() = %x, want %x", size, sealed, want)
+		}
+		got, err := g.Open(nil, nonce, sealed, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Open() = %q, want %q", got, plaintext)
+		}
+	}
+	for _, size := range []int{0, 15, 20, 33} {
+		if _, err := NewGCMFromKey(make([]byte, size)); err == nil {
+			t.Errorf("NewGCMFromKey accepted a %d-byte key", size)
+		}
+	}
+}
+
+func TestGCMHashSubkey(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		k := key[:size]
+		h, err := GCMHashSubkey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ci, err := NewAESCipher(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]byte, aesBlockSize)
+		ci.Encrypt(want, want)
+		if !bytes.Equal(h[:], want) {
+			t.Errorf("%d-byte key: got %x, want %x", size, h, want)
+		}
+	}
+	// H for the all-zero AES-128 key, from test case 1 of the GCM specification.
+	h, err := GCMHashSubkey(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprintf("%x", h), "66e94bd4ef8a2c3b884cfa59ca342b2e"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := GCMHashSubkey(make([]byte, 15)); err == nil {
+		t.Error("expected error for invalid key size")
+	}
+}
+
+func TestGCMNonEmptyDst(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The generic Go implementation, used as the reference.
+	ref, err := cipher.NewGCM(&noGCM{ci})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmStandardNonceSize)
+	plaintext := []byte("appended after the existing content")
+	ad := []byte("ad")
+	sealed := ref.Seal(nil, nonce, plaintext, ad)
+	prefix := []byte("existing content")
+
+	dsts := map[string]func() []byte{
+		"full":     func() []byte { return append([]byte(nil), prefix...)[:len(prefix):len(prefix)] },
+		"capacity": func() []byte { return append(make([]byte, 0, 256), prefix...) },
+	}
+	for name, newDst := range dsts {
+		t.Run(name, func(t *testing.T) {
+			dst := newDst()
+			out := g.Seal(dst, nonce, plaintext, ad)
+			if want := append(append([]byte(nil), prefix...), sealed...); !bytes.Equal(out, want) {
+				t.Errorf("Seal() = %x, want %x", out, want)
+			}
+			if cap(dst) >= len(out) && &out[0] != &dst[0] {
+				t.Error("Seal did not reuse the capacity of dst")
+			}
+
+			dst = newDst()
+			out, err := g.Open(dst, nonce, sealed, ad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := append(append([]byte(nil), prefix...), plaintext...); !bytes.Equal(out, want) {
+				t.Errorf("Open() = %q, want %q", out, want)
+			}
+
+			dst = newDst()
+			out, err = g.Open(dst, nonce, sealed, []byte("wrong ad"))
+			if err == nil || out != nil {
+				t.Fatalf("Open() with wrong additional data = %q, %v", out, err)
+			}
+			if !bytes.Equal(dst, prefix) {
+				t.Errorf("failed Open modified dst: %q", dst)
+			}
+		})
+	}
+}
+
+func TestNewAES256GCM(t *testing.T) {
+	for _, size := range []int{16, 24} {
+		if _, err := NewAES256GCM(key[:size]); err == nil {
+			t.Errorf("%d-byte key accepted", size)
+		}
+		if _, err := NewGCMFromKey(key[:size]); err != nil {
+			t.Errorf("%d-byte key rejected by NewGCMFromKey: %v", size, err)
+		}
+	}
+	g, err := NewAES256GCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := NewGCMFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmStandardNonceSize)
+	if got, want := g.Seal(nil, nonce, []byte("plaintext"), nil), ref.Seal(nil, nonce, []byte("plaintext"), nil); !bytes.Equal(got, want) {
+		t.Errorf("Seal() = %x, want %x", got, want)
+	}
+}
+
+func TestAESCTR(t *testing.T) {
+	iv := []byte("0123456789abcdef")
+	for _, keySize := range []int{16, 24, 32} {
+		k := key[:keySize]
+		c, err := NewAESCipher(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := aes.NewCipher(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{0, 1, 15, 16, 17, 100, ctrMaxKeyStream + 33} {
+			src := make([]byte, n)
+			for i := range src {
+				src[i] = byte(i)
+			}
+			got := make([]byte, n)
+			c.(*aesCipher).NewCTR(iv).XORKeyStream(got, src)
+			want := make([]byte, n)
+			cipher.NewCTR(noGCM{ref}, iv).XORKeyStream(want, src)
+			if !bytes.Equal(got, want) {
+				t.Errorf("AES-%d, %d bytes: CTR output doesn't match crypto/aes", keySize*8, n)
+			}
+		}
+	}
+}
+
+func TestAESCTRChunks(t *testing.T) {
+	c, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, aesBlockSize)
+	src := make([]byte, 200)
+	want := make([]byte, len(src))
+	c.(*aesCipher).NewCTR(iv).XORKeyStream(want, src)
+	for _, chunks := range [][]int{
+		{1, 199},
+		{15, 1, 16, 168},
+		{7, 7, 7, 7, 172},
+		{17, 0, 33, 150},
+		{100, 100},
+	} {
+		s := c.(*aesCipher).NewCTR(iv)
+		got := make([]byte, 0, len(src))
+		off := 0
+		for _, n := range chunks {
+			out := make([]byte, n)
+			s.XORKeyStream(out, src[off:off+n])
+			got = append(got, out...)
+			off += n
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("chunks %v: key stream differs from a single call", chunks)
+		}
+	}
+
+	// In-place use is allowed.
+	buf := make([]byte, len(src))
+	s := c.(*aesCipher).NewCTR(iv)
+	s.XORKeyStream(buf[:50], buf[:50])
+	s.XORKeyStream(buf[50:], buf[50:])
+	if !bytes.Equal(buf, want) {
+		t.Error("in-place key stream differs from a single call")
+	}
+}
+
+func TestAESCTRCounterWrap(t *testing.T) {
+	c, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := bytes.Repeat([]byte{0xff}, aesBlockSize)
+	got, want := make([]byte, 64), make([]byte, 64)
+	cipher.NewCTR(c, iv).XORKeyStream(got, got)
+	cipher.NewCTR(noGCM{ref}, iv).XORKeyStream(want, want)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+	if _, ok := cipher.NewCTR(c, iv).(*aesCTR); !ok {
+		t.Error("cipher.NewCTR doesn't use aesCipher.NewCTR")
+	}
+}
+
+func TestAESCTRPanic(t *testing.T) {
+	c, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPanic(t, func() { c.(*aesCipher).NewCTR(make([]byte, 12)) })
+	s := c.(*aesCipher).NewCTR(make([]byte, aesBlockSize))
+	assertPanic(t, func() { s.XORKeyStream(make([]byte, 3), make([]byte, 4)) })
+	buf := make([]byte, 32)
+	assertPanic(t, func() { s.XORKeyStream(buf[1:17], buf[:16]) })
+}
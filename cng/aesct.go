@@ -0,0 +1,19 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+// AESIsConstantTime reports whether the AES implementation used by CNG
+// is the hardware one, which is not vulnerable to cache-timing attacks.
+//
+// CNG uses the processor AES instructions whenever they are available,
+// and otherwise falls back to a table-based software implementation.
+// AESIsConstantTime therefore reports whether the processor supports
+// the AES instructions. It returns an error if that can't be determined
+// on the current architecture.
+func AESIsConstantTime() (bool, error) {
+	return hasAESInstructions()
+}
@@ -0,0 +1,24 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows && arm64
+// +build windows,arm64
+
+package cng
+
+import "syscall"
+
+// PF_ARM_V8_CRYPTO_INSTRUCTIONS_AVAILABLE reports support
+// for the ARMv8 AES, SHA-1 and SHA-2 instructions.
+const _PF_ARM_V8_CRYPTO_INSTRUCTIONS_AVAILABLE = 30
+
+// modkernel32 is declared in audit.go.
+var procIsProcessorFeaturePresent = modkernel32.NewProc("IsProcessorFeaturePresent")
+
+func hasAESInstructions() (bool, error) {
+	if err := procIsProcessorFeaturePresent.Find(); err != nil {
+		return false, err
+	}
+	r0, _, _ := syscall.Syscall(procIsProcessorFeaturePresent.Addr(), 1, _PF_ARM_V8_CRYPTO_INSTRUCTIONS_AVAILABLE, 0, 0)
+	return r0 != 0, nil
+}
@@ -0,0 +1,13 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows && !386 && !amd64 && !arm64
+// +build windows,!386,!amd64,!arm64
+
+package cng
+
+import "errors"
+
+func hasAESInstructions() (bool, error) {
+	return false, errors.New("cng: AES implementation can't be determined on this architecture")
+}
@@ -0,0 +1,17 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows && (386 || amd64)
+// +build windows
+// +build 386 amd64
+
+package cng
+
+// cpuid is implemented in aesct_x86.s.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func hasAESInstructions() (bool, error) {
+	// AES-NI support is reported in ECX bit 25 of CPUID leaf 1.
+	_, _, ecx, _ := cpuid(1, 0)
+	return ecx&(1<<25) != 0, nil
+}
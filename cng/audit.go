@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// Operations reported in AuditEvent.Op.
+const (
+	AuditGenerateKey = "GenerateKey"
+	AuditSign        = "Sign"
+	AuditVerify      = "Verify"
+	AuditSeal        = "Seal"
+	AuditOpen        = "Open"
+	AuditAgree       = "Agree"
+)
+
+// AuditEvent describes a cryptographic operation reported to the audit hook.
+// It only carries metadata: never keys, plaintexts, or any other input.
+type AuditEvent struct {
+	Op        string // One of the Audit* operations.
+	Algorithm string // For example "ECDSA", "RSA-PSS" or "AES-GCM".
+	KeyBits   int    // Key size in bits, or 0 if unknown.
+
+	// Duration is the time spent in the operation, in nanoseconds,
+	// which time.Duration(e.Duration) converts. It is only measured
+	// for signing, verification and ECDH secret agreement, which are
+	// reported once they complete; other operations are reported
+	// before they start, with a zero Duration.
+	Duration int64
+}
+
+type auditHookFunc struct {
+	fn func(AuditEvent)
+}
+
+var auditHookValue atomic.Value // auditHookFunc
+
+// SetAuditHook installs fn to be called synchronously on key generation,
+// signing, verification, ECDH secret agreement and AES-GCM operations. It replaces any previous
+// hook, and a nil fn removes it. fn must be safe for concurrent use.
+//
+// When no hook is installed, the cost of auditing is a single atomic load.
+func SetAuditHook(fn func(AuditEvent)) {
+	auditHookValue.Store(auditHookFunc{fn})
+}
+
+// auditHook returns the installed hook, or nil.
+func auditHook() func(AuditEvent) {
+	h, _ := auditHookValue.Load().(auditHookFunc)
+	return h.fn
+}
+
+// auditDone reports e to hook with the time elapsed since start,
+// a perfCounter value. It is meant to be deferred when the operation starts.
+func auditDone(hook func(AuditEvent), e AuditEvent, start int64) {
+	ticks := perfCounter() - start
+	freq := perfFrequency()
+	e.Duration = ticks/freq*1e9 + ticks%freq*1e9/freq
+	hook(e)
+}
+
+var (
+	// kernel32.dll is a known system DLL used by Go,
+	// so protected against DLL preloading attacks.
+	modkernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	procQueryPerformanceCounter   = modkernel32.NewProc("QueryPerformanceCounter")
+	procQueryPerformanceFrequency = modkernel32.NewProc("QueryPerformanceFrequency")
+
+	perfFrequencyOnce  sync.Once
+	perfFrequencyValue int64
+)
+
+// perfCounter returns the current value of the Windows high-resolution
+// performance counter. It is used instead of package time, which this
+// package can't import.
+func perfCounter() int64 {
+	var c int64
+	syscall.Syscall(procQueryPerformanceCounter.Addr(), 1, uintptr(unsafe.Pointer(&c)), 0, 0)
+	return c
+}
+
+// perfFrequency returns the number of perfCounter ticks per second,
+// which is fixed at boot.
+func perfFrequency() int64 {
+	perfFrequencyOnce.Do(func() {
+		syscall.Syscall(procQueryPerformanceFrequency.Addr(), 1, uintptr(unsafe.Pointer(&perfFrequencyValue)), 0, 0)
+	})
+	return perfFrequencyValue
+}
+
+// keyBits returns the size of hkey in bits, or 0 if it can't be queried.
+func keyBits(hkey bcrypt.KEY_HANDLE) int {
+	n, err := getUint32(bcrypt.HANDLE(hkey), bcrypt.KEY_LENGTH)
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestAuditHook(t *testing.T) {
+	var mu sync.Mutex
+	var events []cng.AuditEvent
+	cng.SetAuditHook(func(e cng.AuditEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+	defer cng.SetAuditHook(nil)
+
+	x, y, d, err := cng.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := cng.NewPrivateKeyECDSA("P-256", x, y, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDSA("P-256", x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := cng.SHA256([]byte("testing"))
+	r, s, err := cng.SignECDSA(priv, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.VerifyECDSA(pub, hashed[:], r, s) {
+		t.Fatal("Verify failed")
+	}
+
+	want := []cng.AuditEvent{
+		{Op: cng.AuditGenerateKey, Algorithm: "ECDSA", KeyBits: 256},
+		{Op: cng.AuditSign, Algorithm: "ECDSA", KeyBits: 256},
+		{Op: cng.AuditVerify, Algorithm: "ECDSA", KeyBits: 256},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// Signing and verification are reported when they complete,
+	// with the time they took.
+	for i := range events {
+		if events[i].Op == cng.AuditGenerateKey {
+			if events[i].Duration != 0 {
+				t.Errorf("%s event has Duration %v, want 0", events[i].Op, events[i].Duration)
+			}
+		} else if events[i].Duration <= 0 {
+			t.Errorf("%s event has Duration %v, want > 0", events[i].Op, events[i].Duration)
+		}
+		events[i].Duration = 0
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+
+	// Removing the hook stops the events.
+	cng.SetAuditHook(nil)
+	events = nil
+	cng.VerifyECDSA(pub, hashed[:], r, s)
+	if len(events) != 0 {
+		t.Errorf("got %d events after removing the hook", len(events))
+	}
+}
+
+func TestAuditHookECDH(t *testing.T) {
+	var events []cng.AuditEvent
+	cng.SetAuditHook(func(e cng.AuditEvent) {
+		events = append(events, e)
+	})
+	defer cng.SetAuditHook(nil)
+
+	alice, _, err := cng.GenerateKeyECDH("P-384")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := cng.GenerateKeyECDH("P-384")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPub, err := bob.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cng.ECDH(alice, bobPub); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(events), events)
+	}
+	e := events[2]
+	if e.Op != cng.AuditAgree || e.Algorithm != "ECDH" || e.KeyBits != 384 {
+		t.Errorf("agreement event = %+v", e)
+	}
+	if e.Duration <= 0 {
+		t.Errorf("agreement event has Duration %v, want > 0", e.Duration)
+	}
+}
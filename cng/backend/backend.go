@@ -0,0 +1,137 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package backend
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"hash"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+type (
+	BigInt          = cng.BigInt
+	PublicKeyRSA    = cng.PublicKeyRSA
+	PrivateKeyRSA   = cng.PrivateKeyRSA
+	PublicKeyECDSA  = cng.PublicKeyECDSA
+	PrivateKeyECDSA = cng.PrivateKeyECDSA
+	PublicKeyECDH   = cng.PublicKeyECDH
+	PrivateKeyECDH  = cng.PrivateKeyECDH
+)
+
+// Enabled reports whether the CNG backend is in use, which is always
+// the case on Windows.
+func Enabled() bool { return true }
+
+// RandReader is a reader of cryptographically secure random bytes.
+const RandReader = cng.RandReader
+
+func SupportsHash(h crypto.Hash) bool { return cng.SupportsHash(h) }
+
+func NewSHA1() hash.Hash   { return cng.NewSHA1() }
+func NewSHA256() hash.Hash { return cng.NewSHA256() }
+func NewSHA384() hash.Hash { return cng.NewSHA384() }
+func NewSHA512() hash.Hash { return cng.NewSHA512() }
+
+func SHA1(p []byte) (sum [20]byte)   { return cng.SHA1(p) }
+func SHA256(p []byte) (sum [32]byte) { return cng.SHA256(p) }
+func SHA384(p []byte) (sum [48]byte) { return cng.SHA384(p) }
+func SHA512(p []byte) (sum [64]byte) { return cng.SHA512(p) }
+
+func NewHMAC(h func() hash.Hash, key []byte) hash.Hash { return cng.NewHMAC(h, key) }
+
+func NewAESCipher(key []byte) (cipher.Block, error) { return cng.NewAESCipher(key) }
+func NewGCMTLS(c cipher.Block) (cipher.AEAD, error) { return cng.NewGCMTLS(c) }
+
+func GenerateKeyRSA(bits int) (N, E, D, P, Q, Dp, Dq, Qinv BigInt, err error) {
+	return cng.GenerateKeyRSA(bits)
+}
+
+func NewPublicKeyRSA(N, E BigInt) (*PublicKeyRSA, error) {
+	return cng.NewPublicKeyRSA(N, E)
+}
+
+func NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv BigInt) (*PrivateKeyRSA, error) {
+	return cng.NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv)
+}
+
+func DecryptRSAOAEP(h hash.Hash, priv *PrivateKeyRSA, ciphertext, label []byte) ([]byte, error) {
+	return cng.DecryptRSAOAEP(h, priv, ciphertext, label)
+}
+
+func EncryptRSAOAEP(h hash.Hash, pub *PublicKeyRSA, msg, label []byte) ([]byte, error) {
+	return cng.EncryptRSAOAEP(h, pub, msg, label)
+}
+
+func DecryptRSAPKCS1(priv *PrivateKeyRSA, ciphertext []byte) ([]byte, error) {
+	return cng.DecryptRSAPKCS1(priv, ciphertext)
+}
+
+func EncryptRSAPKCS1(pub *PublicKeyRSA, msg []byte) ([]byte, error) {
+	return cng.EncryptRSAPKCS1(pub, msg)
+}
+
+func DecryptRSANoPadding(priv *PrivateKeyRSA, ciphertext []byte) ([]byte, error) {
+	return cng.DecryptRSANoPadding(priv, ciphertext)
+}
+
+func EncryptRSANoPadding(pub *PublicKeyRSA, msg []byte) ([]byte, error) {
+	return cng.EncryptRSANoPadding(pub, msg)
+}
+
+func SignRSAPSS(priv *PrivateKeyRSA, h crypto.Hash, hashed []byte, saltLen int) ([]byte, error) {
+	return cng.SignRSAPSS(priv, h, hashed, saltLen)
+}
+
+func VerifyRSAPSS(pub *PublicKeyRSA, h crypto.Hash, hashed, sig []byte, saltLen int) error {
+	return cng.VerifyRSAPSS(pub, h, hashed, sig, saltLen)
+}
+
+func SignRSAPKCS1v15(priv *PrivateKeyRSA, h crypto.Hash, hashed []byte) ([]byte, error) {
+	return cng.SignRSAPKCS1v15(priv, h, hashed)
+}
+
+func VerifyRSAPKCS1v15(pub *PublicKeyRSA, h crypto.Hash, hashed, sig []byte) error {
+	return cng.VerifyRSAPKCS1v15(pub, h, hashed, sig)
+}
+
+func GenerateKeyECDSA(curve string) (X, Y, D BigInt, err error) {
+	return cng.GenerateKeyECDSA(curve)
+}
+
+func NewPublicKeyECDSA(curve string, X, Y BigInt) (*PublicKeyECDSA, error) {
+	return cng.NewPublicKeyECDSA(curve, X, Y)
+}
+
+func NewPrivateKeyECDSA(curve string, X, Y, D BigInt) (*PrivateKeyECDSA, error) {
+	return cng.NewPrivateKeyECDSA(curve, X, Y, D)
+}
+
+func SignECDSA(priv *PrivateKeyECDSA, hash []byte) (r, s BigInt, err error) {
+	return cng.SignECDSA(priv, hash)
+}
+
+func VerifyECDSA(pub *PublicKeyECDSA, hash []byte, r, s BigInt) bool {
+	return cng.VerifyECDSA(pub, hash, r, s)
+}
+
+func GenerateKeyECDH(curve string) (*PrivateKeyECDH, []byte, error) {
+	return cng.GenerateKeyECDH(curve)
+}
+
+func NewPublicKeyECDH(curve string, bytes []byte) (*PublicKeyECDH, error) {
+	return cng.NewPublicKeyECDH(curve, bytes)
+}
+
+func NewPrivateKeyECDH(curve string, key []byte) (*PrivateKeyECDH, error) {
+	return cng.NewPrivateKeyECDH(curve, key)
+}
+
+func ECDH(priv *PrivateKeyECDH, pub *PublicKeyECDH) ([]byte, error) {
+	return cng.ECDH(priv, pub)
+}
@@ -0,0 +1,11 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build !windows
+// +build !windows
+
+package backend
+
+// Enabled reports whether the CNG backend is in use,
+// which is never the case outside Windows.
+func Enabled() bool { return false }
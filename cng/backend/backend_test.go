@@ -0,0 +1,240 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package backend_test
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+	"github.com/microsoft/go-crypto-winnative/cng/backend"
+)
+
+func TestEnabled(t *testing.T) {
+	if !backend.Enabled() {
+		t.Error("Enabled() = false on Windows")
+	}
+}
+
+func TestHashes(t *testing.T) {
+	msg := []byte("backend")
+	if got, want := backend.SHA1(msg), cng.SHA1(msg); got != want {
+		t.Errorf("SHA1 = %x, want %x", got, want)
+	}
+	if got, want := backend.SHA256(msg), cng.SHA256(msg); got != want {
+		t.Errorf("SHA256 = %x, want %x", got, want)
+	}
+	if got, want := backend.SHA384(msg), cng.SHA384(msg); got != want {
+		t.Errorf("SHA384 = %x, want %x", got, want)
+	}
+	if got, want := backend.SHA512(msg), cng.SHA512(msg); got != want {
+		t.Errorf("SHA512 = %x, want %x", got, want)
+	}
+	for _, tt := range []struct {
+		name string
+		got  func() []byte
+		want []byte
+	}{
+		{"NewSHA1", func() []byte { h := backend.NewSHA1(); h.Write(msg); return h.Sum(nil) }, sum(cng.SHA1(msg))},
+		{"NewSHA256", func() []byte { h := backend.NewSHA256(); h.Write(msg); return h.Sum(nil) }, sum(cng.SHA256(msg))},
+		{"NewSHA384", func() []byte { h := backend.NewSHA384(); h.Write(msg); return h.Sum(nil) }, sum(cng.SHA384(msg))},
+		{"NewSHA512", func() []byte { h := backend.NewSHA512(); h.Write(msg); return h.Sum(nil) }, sum(cng.SHA512(msg))},
+	} {
+		if got := tt.got(); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s = %x, want %x", tt.name, got, tt.want)
+		}
+	}
+	if !backend.SupportsHash(crypto.SHA256) {
+		t.Error("SupportsHash(SHA256) = false")
+	}
+
+	h := backend.NewHMAC(backend.NewSHA256, []byte("key"))
+	h.Write(msg)
+	want := cng.NewHMAC(cng.NewSHA256, []byte("key"))
+	want.Write(msg)
+	if !bytes.Equal(h.Sum(nil), want.Sum(nil)) {
+		t.Error("NewHMAC doesn't match cng.NewHMAC")
+	}
+}
+
+func sum(b interface{}) []byte {
+	switch b := b.(type) {
+	case [20]byte:
+		return b[:]
+	case [32]byte:
+		return b[:]
+	case [48]byte:
+		return b[:]
+	case [64]byte:
+		return b[:]
+	}
+	panic("unexpected digest type")
+}
+
+func TestAES(t *testing.T) {
+	key := make([]byte, 16)
+	block, err := backend.NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := cng.NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := make([]byte, 16), make([]byte, 16)
+	block.Encrypt(got, make([]byte, 16))
+	ref.Encrypt(want, make([]byte, 16))
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encrypt = %x, want %x", got, want)
+	}
+
+	aead, err := backend.NewGCMTLS(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refAEAD, err := cng.NewGCMTLS(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if got, want := aead.Seal(nil, nonce, []byte("msg"), nil), refAEAD.Seal(nil, nonce, []byte("msg"), nil); !bytes.Equal(got, want) {
+		t.Errorf("Seal = %x, want %x", got, want)
+	}
+}
+
+func TestRSA(t *testing.T) {
+	N, E, D, P, Q, Dp, Dq, Qinv, err := backend.GenerateKeyRSA(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := backend.NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := backend.NewPublicKeyRSA(N, E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := make([]byte, 32)
+	sig, err := backend.SignRSAPKCS1v15(priv, crypto.SHA256, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cng.VerifyRSAPKCS1v15(pub, crypto.SHA256, hashed, sig); err != nil {
+		t.Errorf("SignRSAPKCS1v15: %v", err)
+	}
+	if err := backend.VerifyRSAPKCS1v15(pub, crypto.SHA256, hashed, sig); err != nil {
+		t.Errorf("VerifyRSAPKCS1v15: %v", err)
+	}
+	sig, err = backend.SignRSAPSS(priv, crypto.SHA256, hashed, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.VerifyRSAPSS(pub, crypto.SHA256, hashed, sig, 32); err != nil {
+		t.Errorf("VerifyRSAPSS: %v", err)
+	}
+
+	msg := []byte("backend")
+	ct, err := backend.EncryptRSAOAEP(cng.NewSHA256(), pub, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := backend.DecryptRSAOAEP(cng.NewSHA256(), priv, ct, nil); err != nil || !bytes.Equal(pt, msg) {
+		t.Errorf("OAEP round trip = %q, %v", pt, err)
+	}
+	ct, err = backend.EncryptRSAPKCS1(pub, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := backend.DecryptRSAPKCS1(priv, ct); err != nil || !bytes.Equal(pt, msg) {
+		t.Errorf("PKCS1 round trip = %q, %v", pt, err)
+	}
+	raw := make([]byte, 256)
+	raw[255] = 2
+	ct, err = backend.EncryptRSANoPadding(pub, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := backend.DecryptRSANoPadding(priv, ct); err != nil || !bytes.Equal(pt, raw) {
+		t.Errorf("no padding round trip = %x, %v", pt, err)
+	}
+}
+
+func TestECDSA(t *testing.T) {
+	X, Y, D, err := backend.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := backend.NewPrivateKeyECDSA("P-256", X, Y, D)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := backend.NewPublicKeyECDSA("P-256", X, Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := make([]byte, 32)
+	r, s, err := backend.SignECDSA(priv, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.VerifyECDSA(pub, hash, r, s) {
+		t.Error("cng.VerifyECDSA rejected SignECDSA's signature")
+	}
+	if !backend.VerifyECDSA(pub, hash, r, s) {
+		t.Error("VerifyECDSA rejected a valid signature")
+	}
+}
+
+func TestECDH(t *testing.T) {
+	alice, _, err := backend.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, bobBytes, err := backend.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicePub, err := alice.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPriv, err := backend.NewPrivateKeyECDH("P-256", bobBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPub, err := bob.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := backend.NewPublicKeyECDH("P-256", bobPub.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := backend.ECDH(alice, peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := cng.ECDH(bobPriv, alicePub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ECDH = %x, want %x", got, want)
+	}
+}
+
+func TestRandReader(t *testing.T) {
+	b := make([]byte, 32)
+	if n, err := backend.RandReader.Read(b); err != nil || n != len(b) {
+		t.Fatalf("Read = %d, %v", n, err)
+	}
+	if bytes.Equal(b, make([]byte, 32)) {
+		t.Error("RandReader returned zeros")
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Package backend exposes the subset of the cng package that Go's crypto
+// packages call through their internal backend, under the names that
+// backend uses, so that a Go toolchain can be built against CNG by
+// swapping a single import.
+//
+// Every function forwards to the cng function of the same name.
+// On other platforms, the package only provides Enabled, which returns false.
+//
+// Like package cng, it only imports the standard library packages that
+// Go's crypto packages may depend on, which cng's TestDependencies checks.
+package backend
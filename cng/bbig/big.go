@@ -4,6 +4,7 @@
 package bbig
 
 import (
+	"errors"
 	"math/big"
 
 	"github.com/microsoft/go-crypto-winnative/cng"
@@ -29,3 +30,17 @@ func Dec(b cng.BigInt) *big.Int {
 	}
 	return new(big.Int).SetBytes(b)
 }
+
+// EncFixed is like Enc, but it returns exactly size bytes, left-padded
+// with zeros, as the fixed-width big-endian encodings of elliptic curve
+// coordinates and scalars do. size is the byte size of the curve field,
+// such as 32 for P-256 or 66 for P-521. b must not be negative.
+func EncFixed(b *big.Int, size int) (cng.BigInt, error) {
+	if b == nil {
+		return nil, nil
+	}
+	if b.Sign() < 0 || b.BitLen() > size*8 {
+		return nil, errors.New("cng: value doesn't fit in the field size")
+	}
+	return b.FillBytes(make([]byte, size)), nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package bbig
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+var errInvalidRecovery = errors.New("cng: invalid signature or recovery ID")
+
+// RecoverPublicKeyECDSA recovers the public key that produced the
+// signature sig over hash, following SEC 1 v2 section 4.1.6.
+// sig is the fixed-width r || s encoding and recoveryID is in [0, 3]:
+// bit 0 selects the parity of R.y and bit 1 whether R.x overflowed the
+// group order.
+//
+// CNG exposes no elliptic curve point arithmetic, so the recovery math
+// is done with crypto/elliptic and only the resulting key is imported.
+func RecoverPublicKeyECDSA(curve string, hash, sig []byte, recoveryID int) (*cng.PublicKeyECDSA, error) {
+	var c elliptic.Curve
+	switch curve {
+	case "P-224":
+		c = elliptic.P224()
+	case "P-256":
+		c = elliptic.P256()
+	case "P-384":
+		c = elliptic.P384()
+	case "P-521":
+		c = elliptic.P521()
+	default:
+		return nil, errors.New("cng: unknown elliptic curve")
+	}
+	if recoveryID < 0 || recoveryID > 3 {
+		return nil, errInvalidRecovery
+	}
+	params := c.Params()
+	n := params.N
+	size := (n.BitLen() + 7) / 8
+	if len(sig) != 2*size {
+		return nil, errors.New("cng: invalid signature length")
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return nil, errInvalidRecovery
+	}
+
+	// R.x = r + j*n, which must still be a field element.
+	x := new(big.Int).Set(r)
+	if recoveryID&2 != 0 {
+		x.Add(x, n)
+	}
+	if x.Cmp(params.P) >= 0 {
+		return nil, errInvalidRecovery
+	}
+	// Decompress R: y² = x³ - 3x + b.
+	y := new(big.Int).Mul(x, x)
+	y.Mul(y, x)
+	threeX := new(big.Int).Lsh(x, 1)
+	threeX.Add(threeX, x)
+	y.Sub(y, threeX)
+	y.Add(y, params.B)
+	y.Mod(y, params.P)
+	if y.ModSqrt(y, params.P) == nil {
+		return nil, errInvalidRecovery
+	}
+	if y.Bit(0) != uint(recoveryID&1) {
+		y.Sub(params.P, y)
+	}
+
+	// e is the leftmost bits of hash, as in signature generation.
+	e := new(big.Int).SetBytes(hash)
+	if excess := len(hash)*8 - n.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+
+	// Q = r⁻¹(sR - eG).
+	rInv := new(big.Int).ModInverse(r, n)
+	u1 := new(big.Int).Mul(e, rInv)
+	u1.Neg(u1)
+	u1.Mod(u1, n)
+	u2 := new(big.Int).Mul(s, rInv)
+	u2.Mod(u2, n)
+	x1, y1 := c.ScalarBaseMult(u1.Bytes())
+	x2, y2 := c.ScalarMult(x, y, u2.Bytes())
+	qx, qy := c.Add(x1, y1, x2, y2)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return nil, errInvalidRecovery
+	}
+	return cng.NewPublicKeyECDSA(curve, Enc(qx), Enc(qy))
+}
@@ -12,19 +12,29 @@ import "math/bits"
 // A BigInt is the big-endian bytes from a math/big BigInt,
 // which are normalized to remove any leading 0 byte.
 // Windows BCrypt accepts this specific data format.
+// Values with leading 0 bytes, such as fixed-width encodings,
+// are also accepted as input.
 // This definition allows us to avoid importing math/big.
 // Conversion between BigInt and *big.Int is in cng/bbig.
 type BigInt []byte
 
-const _S = bits.UintSize / 8 // word size in bytes
+// normalize returns x without its leading zero bytes.
+// BigInt values coming from fixed-width encodings, such as
+// the ones returned by GenerateKeyECDSA, may have some.
+func (x BigInt) normalize() BigInt {
+	for len(x) > 0 && x[0] == 0 {
+		x = x[1:]
+	}
+	return x
+}
 
 // Length of x in bits.
 func (x BigInt) bitLen() int {
+	x = x.normalize()
 	if len(x) == 0 {
 		return 0
 	}
-	// x is normalized, so the length in bits is
-	// the length in bits of x minus one byte (_S),
+	// The length in bits is 8 bits for each byte but the first,
 	// plus the minimum number of bits to represent the first byte.
-	return (len(x)-1)*_S + bits.Len(uint(x[0]))
+	return (len(x)-1)*8 + bits.Len8(x[0])
 }
@@ -0,0 +1,40 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cng
+
+import "testing"
+
+func TestBigIntBitLen(t *testing.T) {
+	tests := []struct {
+		x    BigInt
+		want int
+	}{
+		{nil, 0},
+		{BigInt{}, 0},
+		{BigInt{0}, 0},
+		{BigInt{0, 0, 0}, 0},
+		{BigInt{1}, 1},
+		{BigInt{0x80}, 8},
+		{BigInt{1, 0}, 9},
+		{BigInt{0, 0, 1, 0}, 9},
+		{BigInt{0xff, 0xff, 0xff, 0xff, 0xff}, 40},
+		{append(BigInt{0, 0}, make(BigInt, 256)...), 0},
+		{append(BigInt{0, 0, 0x80}, make(BigInt, 255)...), 2048},
+	}
+	for _, tt := range tests {
+		if got := tt.x.bitLen(); got != tt.want {
+			t.Errorf("%x.bitLen() = %d, want %d", []byte(tt.x), got, tt.want)
+		}
+	}
+}
+
+func TestBigIntNormalize(t *testing.T) {
+	x := BigInt{0, 0, 0, 1, 0, 2}
+	if got := x.normalize(); string(got) != "\x01\x00\x02" {
+		t.Errorf("normalize() = %x", []byte(got))
+	}
+	if got := (BigInt{0, 0}).normalize(); len(got) != 0 {
+		t.Errorf("normalize() of zero = %x", []byte(got))
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// SealBindNonce is like aead.Seal, but it also authenticates the nonce
+// as part of the additional data, which is nonce || additionalData.
+// The nonce has the fixed size aead.NonceSize(), so the concatenation is
+// unambiguous. The result must be opened with OpenBindNonce.
+//
+// GCM already authenticates its nonce, through the initial counter block
+// in the tag. Binding it into the additional data as well makes the
+// binding explicit for protocols built on any cipher.AEAD, and lets the
+// nonce be checked without reference to the cipher's internal
+// construction. It doesn't make GCM commit to its key: a ciphertext can
+// still be crafted to authenticate under several known keys.
+//
+// It panics if the nonce doesn't have the size of aead.NonceSize().
+func SealBindNonce(aead cipher.AEAD, dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != aead.NonceSize() {
+		panic("cipher: incorrect nonce length given to SealBindNonce")
+	}
+	return aead.Seal(dst, nonce, plaintext, bindNonce(nonce, additionalData))
+}
+
+// OpenBindNonce decrypts and authenticates a ciphertext produced by
+// SealBindNonce, with the same nonce and additional data, and appends the
+// plaintext to dst.
+func OpenBindNonce(aead cipher.AEAD, dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("cipher: incorrect nonce length given to OpenBindNonce")
+	}
+	return aead.Open(dst, nonce, ciphertext, bindNonce(nonce, additionalData))
+}
+
+func bindNonce(nonce, additionalData []byte) []byte {
+	ad := make([]byte, 0, len(nonce)+len(additionalData))
+	ad = append(ad, nonce...)
+	return append(ad, additionalData...)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestSealBindNonce(t *testing.T) {
+	aead, err := cng.NewGCMFromKey(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := bytes.Repeat([]byte{1}, aead.NonceSize())
+	plaintext := []byte("bound nonce")
+	ad := []byte("ad")
+	sealed := cng.SealBindNonce(aead, nil, nonce, plaintext, ad)
+
+	// The additional data is nonce || ad.
+	want := aead.Seal(nil, nonce, plaintext, append(append([]byte(nil), nonce...), ad...))
+	if !bytes.Equal(sealed, want) {
+		t.Errorf("SealBindNonce = %x, want %x", sealed, want)
+	}
+
+	got, err := cng.OpenBindNonce(aead, []byte("dst"), nonce, sealed, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append([]byte("dst"), plaintext...); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := aead.Open(nil, nonce, sealed, ad); err == nil {
+		t.Error("plain Open accepted a ciphertext with a bound nonce")
+	}
+	if _, err := cng.OpenBindNonce(aead, nil, nonce, sealed, []byte("other")); err == nil {
+		t.Error("OpenBindNonce accepted different additional data")
+	}
+	if _, err := cng.OpenBindNonce(aead, nil, nonce[1:], sealed, ad); err == nil {
+		t.Error("OpenBindNonce accepted a short nonce")
+	}
+}
+
+// TestSealBindNonceMismatch opens a message with the nonce bound in the
+// additional data under a different nonce than the one it was sealed
+// with, with the cipher nonce itself unchanged.
+func TestSealBindNonceMismatch(t *testing.T) {
+	aead, err := cng.NewGCMFromKey(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	other := bytes.Repeat([]byte{0xff}, aead.NonceSize())
+	sealed := aead.Seal(nil, nonce, []byte("message"), append(append([]byte(nil), other...), "ad"...))
+	if _, err := cng.OpenBindNonce(aead, nil, nonce, sealed, []byte("ad")); err == nil {
+		t.Error("OpenBindNonce accepted a nonce different from the one in the additional data")
+	}
+	if _, err := cng.OpenBindNonce(aead, nil, other, sealed, []byte("ad")); err == nil {
+		t.Error("OpenBindNonce accepted a different nonce")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+	"github.com/microsoft/go-crypto-winnative/internal/subtle"
+)
+
+// cbcCSCipher implements CBC with ciphertext stealing, variant CS3 of the
+// NIST SP 800-38A addendum, which is also the Kerberos AES-CTS mode of
+// RFC 3962. CNG has no ciphertext stealing mode, so it is built from the
+// CNG CBC mode and a single block decryption.
+type cbcCSCipher struct {
+	block   *aesCipher
+	cbc     *cbcCipher
+	iv      [aesBlockSize]byte
+	encrypt bool
+}
+
+// NewCBCCSEncrypter returns a cipher.BlockMode which encrypts in CBC-CS3
+// mode, using the given AES cipher and iv. c must be a cipher returned by
+// NewAESCipher and iv must be one block long.
+//
+// Unlike CBC, the ciphertext has the same length as the plaintext, which
+// can be any length of at least one block. Because the last two blocks are
+// processed together, each CryptBlocks call encrypts a complete message
+// with the current IV, which can be changed with SetIV. A CryptBlocks call
+// with less than one block of input panics.
+func NewCBCCSEncrypter(c cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	return newCBCCS(true, c, iv)
+}
+
+// NewCBCCSDecrypter returns a cipher.BlockMode which decrypts in CBC-CS3
+// mode, using the given AES cipher and iv. See NewCBCCSEncrypter.
+func NewCBCCSDecrypter(c cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	return newCBCCS(false, c, iv)
+}
+
+func newCBCCS(encrypt bool, c cipher.Block, iv []byte) (*cbcCSCipher, error) {
+	ac, ok := c.(*aesCipher)
+	if !ok {
+		return nil, errors.New("cng: CBC-CS requires an AES cipher created by NewAESCipher")
+	}
+	if len(iv) != aesBlockSize {
+		return nil, errors.New("cng: CBC-CS IV must be one block long")
+	}
+	x := &cbcCSCipher{
+		block:   ac,
+		cbc:     newCBC(encrypt, bcrypt.AES_ALGORITHM, ac.key, iv),
+		encrypt: encrypt,
+	}
+	copy(x.iv[:], iv)
+	return x, nil
+}
+
+func (x *cbcCSCipher) BlockSize() int { return aesBlockSize }
+
+// SetIV sets the IV used by the next CryptBlocks calls.
+// It panics if iv is not exactly one block long.
+func (x *cbcCSCipher) SetIV(iv []byte) {
+	if len(iv) != aesBlockSize {
+		panic("cipher: incorrect length IV")
+	}
+	copy(x.iv[:], iv)
+}
+
+// CryptBlocks encrypts or decrypts the complete message src into dst.
+func (x *cbcCSCipher) CryptBlocks(dst, src []byte) {
+	if subtle.InexactOverlap(dst, src) {
+		panic("crypto/cipher: invalid buffer overlap")
+	}
+	if len(src) < aesBlockSize {
+		panic("crypto/cipher: input not full block")
+	}
+	if len(dst) < len(src) {
+		panic("crypto/cipher: output smaller than input")
+	}
+	// n is the number of blocks, the last one holding d bytes.
+	n := (len(src) + aesBlockSize - 1) / aesBlockSize
+	d := len(src) - (n-1)*aesBlockSize
+	buf := make([]byte, n*aesBlockSize)
+	x.cbc.SetIV(x.iv[:])
+	if n == 1 {
+		x.cbc.CryptBlocks(dst[:aesBlockSize], src)
+		return
+	}
+	h := (n - 2) * aesBlockSize
+	if x.encrypt {
+		// Encrypt the zero padded plaintext, then output the last block
+		// before the truncated next-to-last one.
+		copy(buf, src)
+		x.cbc.CryptBlocks(buf, buf)
+		copy(dst, buf[:h])
+		copy(dst[h:], buf[h+aesBlockSize:])
+		copy(dst[h+aesBlockSize:], buf[h:h+d])
+		return
+	}
+	// Decrypting the last ciphertext block gives the next-to-last one
+	// XORed with the zero padded last plaintext block, so its tail holds
+	// the bytes that were stolen from the next-to-last block.
+	last, stolen := src[h:h+aesBlockSize], src[h+aesBlockSize:]
+	copy(buf, src[:h])
+	x.block.Decrypt(buf[h:h+aesBlockSize], last)
+	copy(buf[h:], stolen)
+	copy(buf[h+aesBlockSize:], last)
+	x.cbc.CryptBlocks(buf, buf)
+	copy(dst, buf[:len(src)])
+}
@@ -0,0 +1,106 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// Test vectors from RFC 3962, Appendix B, whose AES-CTS mode is CBC-CS3
+// with an all-zero IV.
+var cbcCS3Tests = []struct {
+	in, out string
+}{
+	{
+		"4920776f756c64206c696b652074686520",
+		"c6353568f2bf8cb4d8a580362da7ff7f97",
+	},
+	{
+		"4920776f756c64206c696b65207468652047656e6572616c20476175277320",
+		"fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5",
+	},
+	{
+		"4920776f756c64206c696b65207468652047656e6572616c2047617527732043",
+		"39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584",
+	},
+	{
+		"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c",
+		"97687268d6ecccc0c07b25e25ecfe584b3fffd940c16a18c1b5549d2f838029e39312523a78662d5be7fcbcc98ebf5",
+	},
+	{
+		"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c20",
+		"97687268d6ecccc0c07b25e25ecfe5849dad8bbb96c4cdc03bc103e1a194bbd839312523a78662d5be7fcbcc98ebf5a8",
+	},
+	{
+		"4920776f756c64206c696b65207468652047656e6572616c20476175277320436869636b656e2c20706c656173652c20616e6420776f6e746f6e20736f75702e",
+		"97687268d6ecccc0c07b25e25ecfe58439312523a78662d5be7fcbcc98ebf5a84807efe836ee89a526730dbc2f7bc8409dad8bbb96c4cdc03bc103e1a194bbd8",
+	},
+	{
+		// A single block is plain CBC.
+		"4920776f756c64206c696b6520746865",
+		"97687268d6ecccc0c07b25e25ecfe584",
+	},
+}
+
+func TestCBCCS3(t *testing.T) {
+	c, err := cng.NewAESCipher(hexDecode(t, "636869636b656e207465726979616b69"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, 16)
+	enc, err := cng.NewCBCCSEncrypter(c, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := cng.NewCBCCSDecrypter(c, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range cbcCS3Tests {
+		in, want := hexDecode(t, tt.in), hexDecode(t, tt.out)
+		got := make([]byte, len(in))
+		enc.CryptBlocks(got, in)
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d: encrypt = %x, want %x", i, got, want)
+		}
+		// Decrypt in place: each call is a complete message.
+		dec.CryptBlocks(got, got)
+		if !bytes.Equal(got, in) {
+			t.Errorf("#%d: decrypt = %x, want %x", i, got, in)
+		}
+	}
+}
+
+func TestCBCCSInvalid(t *testing.T) {
+	c, err := cng.NewAESCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cng.NewCBCCSEncrypter(c, make([]byte, 8)); err == nil {
+		t.Error("expected an error for a short IV")
+	}
+	des, err := cng.NewDESCipher(make([]byte, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cng.NewCBCCSEncrypter(des, make([]byte, 8)); err == nil {
+		t.Error("expected an error for a non-AES cipher")
+	}
+	enc, err := cng.NewCBCCSEncrypter(c, make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an input shorter than a block")
+		}
+	}()
+	enc.CryptBlocks(make([]byte, 15), make([]byte, 15))
+}
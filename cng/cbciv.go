@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// NewCBCEncrypterDerivedIV returns a cipher.BlockMode which encrypts in
+// CBC mode with an IV derived from nonce, for formats that store a nonce
+// instead of the IV. c must be a cipher returned by NewAESCipher.
+//
+// The IV is the encryption of nonce with c itself, IV = AES_K(nonce),
+// which is the IV generation method of NIST SP 800-38A, Appendix C.
+// nonce must be exactly one block (16 bytes) long and must be unique
+// for each message encrypted with the same key; it doesn't have to be
+// unpredictable. The IV is never output, so the decrypter must derive it
+// again from the same nonce with NewCBCDecrypterDerivedIV.
+func NewCBCEncrypterDerivedIV(c cipher.Block, nonce []byte) (cipher.BlockMode, error) {
+	ac, iv, err := deriveCBCIV(c, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return ac.NewCBCEncrypter(iv[:]), nil
+}
+
+// NewCBCDecrypterDerivedIV returns a cipher.BlockMode which decrypts in
+// CBC mode with an IV derived from nonce. See NewCBCEncrypterDerivedIV.
+func NewCBCDecrypterDerivedIV(c cipher.Block, nonce []byte) (cipher.BlockMode, error) {
+	ac, iv, err := deriveCBCIV(c, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return ac.NewCBCDecrypter(iv[:]), nil
+}
+
+func deriveCBCIV(c cipher.Block, nonce []byte) (*aesCipher, [aesBlockSize]byte, error) {
+	var iv [aesBlockSize]byte
+	ac, ok := c.(*aesCipher)
+	if !ok {
+		return nil, iv, errors.New("cng: derived CBC IVs require an AES cipher created by NewAESCipher")
+	}
+	if len(nonce) != aesBlockSize {
+		return nil, iv, errors.New("cng: CBC IV nonce must be one block long")
+	}
+	ac.Encrypt(iv[:], nonce)
+	return ac, iv, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// TestCBCDerivedIV uses the key and plaintext of NIST SP 800-38A, F.2.1.
+// The expected values were computed independently with crypto/aes.
+func TestCBCDerivedIV(t *testing.T) {
+	key := hexDecode(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	nonce := hexDecode(t, "000102030405060708090a0b0c0d0e0f")
+	plaintext := hexDecode(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51")
+	want := hexDecode(t, "668bcf60beb005a35354a201dab36bda16bd032100975551547b4de89daea630")
+	c, err := cng.NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := cng.NewCBCEncrypterDerivedIV(c, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(plaintext))
+	enc.CryptBlocks(got, plaintext)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+
+	// It is plain CBC with IV = AES_K(nonce).
+	iv := make([]byte, 16)
+	c.Encrypt(iv, nonce)
+	if want := hexDecode(t, "50fe67cc996d32b6da0937e99bafec60"); !bytes.Equal(iv, want) {
+		t.Errorf("derived IV = %x, want %x", iv, want)
+	}
+	cbc := make([]byte, len(plaintext))
+	c.(interface {
+		NewCBCEncrypter([]byte) cipher.BlockMode
+	}).NewCBCEncrypter(iv).CryptBlocks(cbc, plaintext)
+	if !bytes.Equal(cbc, want) {
+		t.Errorf("CBC with the derived IV = %x, want %x", cbc, want)
+	}
+
+	dec, err := cng.NewCBCDecrypterDerivedIV(c, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec.CryptBlocks(got, got)
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted %x, want %x", got, plaintext)
+	}
+}
+
+func TestCBCDerivedIVNonceSize(t *testing.T) {
+	c, err := cng.NewAESCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 12, 15, 17, 32} {
+		if _, err := cng.NewCBCEncrypterDerivedIV(c, make([]byte, size)); err == nil {
+			t.Errorf("%d-byte nonce accepted", size)
+		}
+		if _, err := cng.NewCBCDecrypterDerivedIV(c, make([]byte, size)); err == nil {
+			t.Errorf("%d-byte nonce accepted for decryption", size)
+		}
+	}
+}
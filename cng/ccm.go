@@ -0,0 +1,136 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+	"github.com/microsoft/go-crypto-winnative/internal/subtle"
+)
+
+// aesCCM implements AES-CCM, as specified in NIST SP 800-38C,
+// using the CNG CCM chaining mode.
+// It is safe for concurrent use by multiple goroutines.
+type aesCCM struct {
+	kh        bcrypt.KEY_HANDLE
+	nonceSize int
+	tagSize   int
+
+	// mu serializes the operations on kh.
+	mu sync.Mutex
+}
+
+// NewCCM returns the given AES cipher wrapped in Counter with CBC-MAC mode.
+// nonceSize must be between 7 and 13 bytes and tagSize must be
+// an even number between 4 and 16 bytes.
+// c must be a cipher returned by NewAESCipher.
+func NewCCM(c cipher.Block, nonceSize, tagSize int) (cipher.AEAD, error) {
+	ac, ok := c.(*aesCipher)
+	if !ok {
+		return nil, errors.New("cng: CCM requires an AES cipher created by NewAESCipher")
+	}
+	if err := checkCCMParams(nonceSize, tagSize); err != nil {
+		return nil, err
+	}
+	kh, err := newCipherHandle(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_CCM, ac.key)
+	if err != nil {
+		return nil, err
+	}
+	g := &aesCCM{kh: kh, nonceSize: nonceSize, tagSize: tagSize}
+	runtime.SetFinalizer(g, (*aesCCM).finalize)
+	return g, nil
+}
+
+func checkCCMParams(nonceSize, tagSize int) error {
+	if nonceSize < 7 || nonceSize > 13 {
+		return errors.New("cng: invalid CCM nonce size")
+	}
+	if tagSize < 4 || tagSize > 16 || tagSize%2 != 0 {
+		return errors.New("cng: invalid CCM tag size")
+	}
+	return nil
+}
+
+// ccmMaxLength returns the maximum plaintext length for nonceSize.
+func ccmMaxLength(nonceSize int) uint64 {
+	q := 15 - nonceSize
+	if q >= 8 {
+		return 1<<64 - 1
+	}
+	return 1<<(8*q) - 1
+}
+
+func (g *aesCCM) finalize() {
+	bcrypt.DestroyKey(g.kh)
+}
+
+func (g *aesCCM) NonceSize() int {
+	return g.nonceSize
+}
+
+func (g *aesCCM) Overhead() int {
+	return g.tagSize
+}
+
+func (g *aesCCM) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != g.nonceSize {
+		panic("cipher: incorrect nonce length given to CCM")
+	}
+	if uint64(len(plaintext)) > ccmMaxLength(g.nonceSize) || len(plaintext) > len32(plaintext) {
+		panic("cipher: message too large for CCM")
+	}
+	ret, out := sliceForAppend(dst, len(plaintext)+g.tagSize)
+	if subtle.InexactOverlap(out, plaintext) {
+		panic("cipher: invalid buffer overlap")
+	}
+	info := bcrypt.NewAUTHENTICATED_CIPHER_MODE_INFO(nonce, additionalData, out[len(out)-g.tagSize:])
+	var encSize uint32
+	g.mu.Lock()
+	err := bcrypt.Encrypt(g.kh, plaintext, unsafe.Pointer(info), nil, out, &encSize, 0)
+	g.mu.Unlock()
+	if err != nil {
+		panic(err)
+	}
+	if int(encSize) != len(plaintext) {
+		panic("crypto/aes: plaintext not fully encrypted")
+	}
+	runtime.KeepAlive(g)
+	return ret
+}
+
+func (g *aesCCM) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != g.nonceSize {
+		panic("cipher: incorrect nonce length given to CCM")
+	}
+	if len(ciphertext) < g.tagSize {
+		return nil, errOpen
+	}
+	tag := ciphertext[len(ciphertext)-g.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-g.tagSize]
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if subtle.InexactOverlap(out, ciphertext) {
+		panic("cipher: invalid buffer overlap")
+	}
+	info := bcrypt.NewAUTHENTICATED_CIPHER_MODE_INFO(nonce, additionalData, tag)
+	var decSize uint32
+	g.mu.Lock()
+	err := bcrypt.Decrypt(g.kh, ciphertext, unsafe.Pointer(info), nil, out, &decSize, 0)
+	g.mu.Unlock()
+	if err != nil || int(decSize) != len(ciphertext) {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errOpen
+	}
+	runtime.KeepAlive(g)
+	return ret, nil
+}
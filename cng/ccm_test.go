@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+type ccmTest struct {
+	key, nonce, aad, plaintext, ciphertext string
+	tagSize                                int
+}
+
+// Test vectors from NIST SP 800-38C, Appendix C.
+var ccmTests = []ccmTest{
+	{
+		"404142434445464748494a4b4c4d4e4f", "10111213141516", "0001020304050607",
+		"20212223", "7162015b4dac255d", 4,
+	},
+	{
+		"404142434445464748494a4b4c4d4e4f", "1011121314151617", "000102030405060708090a0b0c0d0e0f",
+		"202122232425262728292a2b2c2d2e2f", "d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd", 6,
+	},
+	{
+		"404142434445464748494a4b4c4d4e4f", "101112131415161718191a1b", "000102030405060708090a0b0c0d0e0f10111213",
+		"202122232425262728292a2b2c2d2e2f3031323334353637", "e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5484392fbc1b09951", 8,
+	},
+}
+
+func TestCCM(t *testing.T) {
+	for i, tt := range ccmTests {
+		block, err := cng.NewAESCipher(hexDecode(t, tt.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := hexDecode(t, tt.nonce)
+		ccm, err := cng.NewCCM(block, len(nonce), tt.tagSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		aad, plaintext := hexDecode(t, tt.aad), hexDecode(t, tt.plaintext)
+		ciphertext := ccm.Seal(nil, nonce, plaintext, aad)
+		if want := hexDecode(t, tt.ciphertext); !bytes.Equal(ciphertext, want) {
+			t.Errorf("#%d: Seal() = %x, want %x", i, ciphertext, want)
+		}
+		got, err := ccm.Open(nil, nonce, ciphertext, aad)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("#%d: Open() = %x, want %x", i, got, plaintext)
+		}
+		ciphertext[0] ^= 1
+		if _, err := ccm.Open(nil, nonce, ciphertext, aad); err == nil {
+			t.Errorf("#%d: Open succeeded with a modified ciphertext", i)
+		}
+	}
+}
+
+func TestCCMInvalidParams(t *testing.T) {
+	block, err := cng.NewAESCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ nonceSize, tagSize int }{
+		{6, 16}, {14, 16}, {12, 2}, {12, 5}, {12, 18},
+	} {
+		if _, err := cng.NewCCM(block, tt.nonceSize, tt.tagSize); err == nil {
+			t.Errorf("NewCCM(%d, %d) succeeded, want error", tt.nonceSize, tt.tagSize)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+var (
+	errCCMStreamLength   = errors.New("cng: CCM stream data exceeds the declared length")
+	errCCMStreamAAD      = errors.New("cng: CCM additional data must be fully provided before any data")
+	errCCMStreamShort    = errors.New("cng: CCM stream data shorter than the declared length")
+	errCCMStreamFinished = errors.New("cng: CCM stream already finished")
+)
+
+// ccmStream implements AES-CCM with the data provided in chunks.
+// CNG CCM doesn't support chained calls, so the CBC-MAC is computed
+// using a CBC key handle and the encryption using AES-CTR.
+type ccmStream struct {
+	block   *aesCipher
+	mac     *cbcCipher
+	encrypt bool
+	tagSize int
+
+	aadLen, aadSeen int
+	ptLen, ptSeen   uint64
+
+	// macBuf holds the trailing data that doesn't fill a whole CBC-MAC block.
+	macBuf []byte
+	// ctr is the next counter block and keyStream the unused key stream.
+	ctr       [aesBlockSize]byte
+	keyStream []byte
+	// s0 is the key stream block used to encrypt the tag.
+	s0       [aesBlockSize]byte
+	finished bool
+}
+
+// CCMStreamEncrypter encrypts and authenticates a message
+// which is provided in chunks.
+type CCMStreamEncrypter struct {
+	*ccmStream
+}
+
+// CCMStreamDecrypter decrypts and authenticates a message
+// which is provided in chunks.
+type CCMStreamDecrypter struct {
+	*ccmStream
+}
+
+// NewCCMStreamEncrypter returns a streaming AES-CCM encrypter.
+// CCM authenticates the data lengths before the data itself,
+// so the additional data and plaintext lengths must be declared up front.
+// c must be a cipher returned by NewAESCipher.
+func NewCCMStreamEncrypter(c cipher.Block, nonce []byte, aadLen int, plaintextLen uint64, tagSize int) (*CCMStreamEncrypter, error) {
+	s, err := newCCMStream(c, nonce, aadLen, plaintextLen, tagSize, true)
+	if err != nil {
+		return nil, err
+	}
+	return &CCMStreamEncrypter{s}, nil
+}
+
+// NewCCMStreamDecrypter returns a streaming AES-CCM decrypter.
+// ciphertextLen doesn't include the tag.
+// c must be a cipher returned by NewAESCipher.
+//
+// The decrypted data returned by Update is not authenticated
+// until Finish succeeds, so it must not be used before that.
+func NewCCMStreamDecrypter(c cipher.Block, nonce []byte, aadLen int, ciphertextLen uint64, tagSize int) (*CCMStreamDecrypter, error) {
+	s, err := newCCMStream(c, nonce, aadLen, ciphertextLen, tagSize, false)
+	if err != nil {
+		return nil, err
+	}
+	return &CCMStreamDecrypter{s}, nil
+}
+
+func newCCMStream(c cipher.Block, nonce []byte, aadLen int, ptLen uint64, tagSize int, encrypt bool) (*ccmStream, error) {
+	ac, ok := c.(*aesCipher)
+	if !ok {
+		return nil, errors.New("cng: CCM streams require an AES cipher created by NewAESCipher")
+	}
+	if err := checkCCMParams(len(nonce), tagSize); err != nil {
+		return nil, err
+	}
+	if aadLen < 0 || ptLen > ccmMaxLength(len(nonce)) {
+		return nil, errors.New("cng: invalid CCM stream length")
+	}
+	s := &ccmStream{
+		block:   ac,
+		mac:     newCBC(true, bcrypt.AES_ALGORITHM, ac.key, make([]byte, aesBlockSize)),
+		encrypt: encrypt,
+		tagSize: tagSize,
+		aadLen:  aadLen,
+		ptLen:   ptLen,
+	}
+	q := 15 - len(nonce)
+
+	// Counter block A0, as specified in NIST SP 800-38C, Appendix A.3.
+	s.ctr[0] = byte(q - 1)
+	copy(s.ctr[1:], nonce)
+	copy(s.s0[:], s.block.ctrKeyStream(&s.ctr, aesBlockSize))
+
+	// First block B0, as specified in NIST SP 800-38C, Appendix A.2.1.
+	var b0 [aesBlockSize]byte
+	b0[0] = byte((tagSize-2)/2<<3 | (q - 1))
+	if aadLen > 0 {
+		b0[0] |= 1 << 6
+	}
+	copy(b0[1:], nonce)
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], ptLen)
+	copy(b0[aesBlockSize-q:], l[8-q:])
+	s.updateMAC(b0[:])
+
+	// Additional data length encoding, as specified in NIST SP 800-38C, Appendix A.2.2.
+	switch {
+	case aadLen == 0:
+	case aadLen < 1<<16-1<<8:
+		s.updateMAC([]byte{byte(aadLen >> 8), byte(aadLen)})
+	case uint64(aadLen) < 1<<32:
+		s.updateMAC([]byte{0xff, 0xfe, byte(aadLen >> 24), byte(aadLen >> 16), byte(aadLen >> 8), byte(aadLen)})
+	default:
+		var b [10]byte
+		b[0], b[1] = 0xff, 0xff
+		binary.BigEndian.PutUint64(b[2:], uint64(aadLen))
+		s.updateMAC(b[:])
+	}
+	return s, nil
+}
+
+// updateMAC adds p to the CBC-MAC.
+func (s *ccmStream) updateMAC(p []byte) {
+	if len(s.macBuf) > 0 {
+		n := copy(s.macBuf[len(s.macBuf):aesBlockSize], p)
+		s.macBuf = s.macBuf[:len(s.macBuf)+n]
+		p = p[n:]
+		if len(s.macBuf) < aesBlockSize {
+			return
+		}
+		s.mac.CryptBlocks(s.macBuf, s.macBuf)
+		s.macBuf = s.macBuf[:0]
+	}
+	if n := len(p) / aesBlockSize * aesBlockSize; n > 0 {
+		// Only the last CBC block, which CNG stores in the IV, is needed.
+		s.mac.CryptBlocks(make([]byte, n), p[:n])
+		p = p[n:]
+	}
+	if len(p) > 0 {
+		if s.macBuf == nil {
+			s.macBuf = make([]byte, 0, aesBlockSize)
+		}
+		s.macBuf = append(s.macBuf, p...)
+	}
+}
+
+// padMAC pads the pending CBC-MAC data with zeros to a whole block.
+func (s *ccmStream) padMAC() {
+	if len(s.macBuf) > 0 {
+		s.updateMAC(make([]byte, aesBlockSize-len(s.macBuf)))
+	}
+}
+
+// UpdateAAD adds aad to the additional authenticated data.
+// It can be called several times, but all the declared additional data
+// must be provided before the first call to Update.
+func (s *ccmStream) UpdateAAD(aad []byte) error {
+	if s.finished {
+		return errCCMStreamFinished
+	}
+	if len(aad) > s.aadLen-s.aadSeen {
+		return errCCMStreamLength
+	}
+	s.aadSeen += len(aad)
+	s.updateMAC(aad)
+	if s.aadSeen == s.aadLen {
+		s.padMAC()
+	}
+	return nil
+}
+
+// Update processes src and appends the result to dst,
+// returning the updated slice. CCM uses counter mode,
+// so the result always has the same length as src.
+func (s *ccmStream) Update(dst, src []byte) ([]byte, error) {
+	if s.finished {
+		return nil, errCCMStreamFinished
+	}
+	if s.aadSeen != s.aadLen {
+		return nil, errCCMStreamAAD
+	}
+	if uint64(len(src)) > s.ptLen-s.ptSeen {
+		return nil, errCCMStreamLength
+	}
+	s.ptSeen += uint64(len(src))
+	ret, out := sliceForAppend(dst, len(src))
+	if s.encrypt {
+		s.updateMAC(src)
+		s.xorKeyStream(out, src)
+	} else {
+		s.xorKeyStream(out, src)
+		s.updateMAC(out)
+	}
+	return ret, nil
+}
+
+func (s *ccmStream) xorKeyStream(dst, src []byte) {
+	for len(src) > 0 {
+		if len(s.keyStream) == 0 {
+			n := len(src)
+			if n > ctrMaxKeyStream {
+				n = ctrMaxKeyStream
+			}
+			s.keyStream = s.block.ctrKeyStream(&s.ctr, n)
+		}
+		n := len(src)
+		if n > len(s.keyStream) {
+			n = len(s.keyStream)
+		}
+		xorBytes(dst[:n], src[:n], s.keyStream)
+		s.keyStream = s.keyStream[n:]
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// final checks the declared lengths and returns the expected tag.
+func (s *ccmStream) final() ([]byte, error) {
+	if s.finished {
+		return nil, errCCMStreamFinished
+	}
+	s.finished = true
+	if s.aadSeen != s.aadLen || s.ptSeen != s.ptLen {
+		return nil, errCCMStreamShort
+	}
+	s.padMAC()
+	tag := make([]byte, s.tagSize)
+	xorBytes(tag, s.mac.iv[:s.tagSize], s.s0[:])
+	return tag, nil
+}
+
+// Finish returns the authentication tag. It fails if less data
+// or additional data than declared has been provided.
+// The stream can't be used after calling Finish.
+func (s *CCMStreamEncrypter) Finish() ([]byte, error) {
+	return s.final()
+}
+
+// Finish reports whether tag authenticates all the data and additional data
+// provided to the stream. It fails if less data or additional data
+// than declared has been provided.
+// The stream can't be used after calling Finish.
+func (s *CCMStreamDecrypter) Finish(tag []byte) error {
+	want, err := s.final()
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(tag, want) != 1 {
+		return errOpen
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestCCMStream(t *testing.T) {
+	for i, tt := range ccmTests {
+		block, err := cng.NewAESCipher(hexDecode(t, tt.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce, aad, plaintext := hexDecode(t, tt.nonce), hexDecode(t, tt.aad), hexDecode(t, tt.plaintext)
+		want := hexDecode(t, tt.ciphertext)
+		for _, chunk := range []int{1, 3, 16, 100} {
+			enc, err := cng.NewCCMStreamEncrypter(block, nonce, len(aad), uint64(len(plaintext)), tt.tagSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out []byte
+			for j := 0; j < len(aad); j += chunk {
+				if err := enc.UpdateAAD(aad[j:minInt(j+chunk, len(aad))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for j := 0; j < len(plaintext); j += chunk {
+				if out, err = enc.Update(out, plaintext[j:minInt(j+chunk, len(plaintext))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tag, err := enc.Finish()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := append(out, tag...); !bytes.Equal(got, want) {
+				t.Errorf("#%d, chunk %d: got %x, want %x", i, chunk, got, want)
+			}
+
+			dec, err := cng.NewCCMStreamDecrypter(block, nonce, len(aad), uint64(len(out)), tt.tagSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dec.UpdateAAD(aad); err != nil {
+				t.Fatal(err)
+			}
+			var decrypted []byte
+			for j := 0; j < len(out); j += chunk {
+				if decrypted, err = dec.Update(decrypted, out[j:minInt(j+chunk, len(out))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := dec.Finish(tag); err != nil {
+				t.Fatalf("#%d, chunk %d: %v", i, chunk, err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("#%d, chunk %d: decrypted %x, want %x", i, chunk, decrypted, plaintext)
+			}
+		}
+	}
+}
+
+func TestCCMStreamMatchesOneShot(t *testing.T) {
+	block, err := cng.NewAESCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccm, err := cng.NewCCM(block, 12, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, 12)
+	aad := bytes.Repeat([]byte("aad"), 100)
+	plaintext := bytes.Repeat([]byte("streamed message"), 50)
+	want := ccm.Seal(nil, nonce, plaintext, aad)
+
+	enc, err := cng.NewCCMStreamEncrypter(block, nonce, len(aad), uint64(len(plaintext)), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.UpdateAAD(aad); err != nil {
+		t.Fatal(err)
+	}
+	out, err := enc.Update(nil, plaintext[:333])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err = enc.Update(out, plaintext[333:]); err != nil {
+		t.Fatal(err)
+	}
+	tag, err := enc.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(out, tag...); !bytes.Equal(got, want) {
+		t.Errorf("stream result differs from one-shot CCM")
+	}
+}
+
+// TestCCMStreamLargeUpdate checks a single Update spanning several
+// key stream chunks against one-shot CCM.
+func TestCCMStreamLargeUpdate(t *testing.T) {
+	block, err := cng.NewAESCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccm, err := cng.NewCCM(block, 12, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, 12)
+	plaintext := make([]byte, 100<<10+5)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+	want := ccm.Seal(nil, nonce, plaintext, nil)
+
+	enc, err := cng.NewCCMStreamEncrypter(block, nonce, 0, uint64(len(plaintext)), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := enc.Update(nil, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := enc.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(out, tag...); !bytes.Equal(got, want) {
+		t.Errorf("stream result differs from one-shot CCM")
+	}
+}
+
+func TestCCMStreamLengthMismatch(t *testing.T) {
+	block, err := cng.NewAESCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, 12)
+	newEnc := func() *cng.CCMStreamEncrypter {
+		enc, err := cng.NewCCMStreamEncrypter(block, nonce, 4, 8, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return enc
+	}
+
+	enc := newEnc()
+	if err := enc.UpdateAAD(make([]byte, 5)); err == nil {
+		t.Error("UpdateAAD accepted more data than declared")
+	}
+	enc = newEnc()
+	if _, err := enc.Update(nil, make([]byte, 8)); err == nil {
+		t.Error("Update accepted data before all the additional data")
+	}
+	enc = newEnc()
+	if err := enc.UpdateAAD(make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.Update(nil, make([]byte, 9)); err == nil {
+		t.Error("Update accepted more data than declared")
+	}
+	if _, err := enc.Update(nil, make([]byte, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.Finish(); err == nil {
+		t.Error("Finish succeeded with less data than declared")
+	}
+	if _, err := enc.Update(nil, make([]byte, 1)); err == nil {
+		t.Error("Update succeeded after Finish")
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
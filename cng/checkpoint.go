@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// checkpointHash reports the running digest of h every interval bytes.
+type checkpointHash struct {
+	h        *hashX
+	interval int
+	cb       func(offset int, digest []byte)
+
+	// offset is the number of bytes written so far
+	// and pending those written since the last checkpoint.
+	offset  int
+	pending int
+}
+
+// NewCheckpointHash returns a new Hash computing the hash identified by
+// hashID, such as "SHA256", which calls cb with the digest of the data
+// written so far each time another interval bytes have been written.
+// offset is the length of that data, a multiple of interval.
+//
+// The digests are taken on a duplicate of the hash state, using
+// BCryptDuplicateHash, so they don't disturb the ongoing hash.
+// cb is called synchronously from Write and must not retain digest.
+func NewCheckpointHash(hashID string, interval int, cb func(offset int, digest []byte)) (Hash, error) {
+	if interval <= 0 {
+		return nil, errors.New("cng: checkpoint interval must be positive")
+	}
+	if cb == nil {
+		return nil, errors.New("cng: nil checkpoint callback")
+	}
+	if _, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG); err != nil {
+		return nil, err
+	}
+	return &checkpointHash{
+		h:        newHashX(hashID, bcrypt.ALG_NONE_FLAG, nil),
+		interval: interval,
+		cb:       cb,
+	}, nil
+}
+
+func (c *checkpointHash) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		chunk := c.interval - c.pending
+		if chunk > len(p) {
+			chunk = len(p)
+		}
+		if _, err := c.h.Write(p[:chunk]); err != nil {
+			return n - len(p), err
+		}
+		p = p[chunk:]
+		c.offset += chunk
+		c.pending += chunk
+		if c.pending == c.interval {
+			c.pending = 0
+			c.cb(c.offset, c.h.Sum(nil))
+		}
+	}
+	return n, nil
+}
+
+func (c *checkpointHash) Sum(in []byte) []byte { return c.h.Sum(in) }
+
+// Reset resets the hash and the checkpoint offsets.
+func (c *checkpointHash) Reset() {
+	c.h.Reset()
+	c.offset, c.pending = 0, 0
+}
+
+func (c *checkpointHash) Size() int { return c.h.Size() }
+
+func (c *checkpointHash) BlockSize() int { return c.h.BlockSize() }
+
+func (c *checkpointHash) Algorithm() crypto.Hash { return c.h.Algorithm() }
@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestCheckpointHash(t *testing.T) {
+	const interval = 100
+	data := make([]byte, 1050)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	var offsets []int
+	var digests [][]byte
+	h, err := cng.NewCheckpointHash("SHA256", interval, func(offset int, digest []byte) {
+		offsets = append(offsets, offset)
+		digests = append(digests, append([]byte(nil), digest...))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in chunks that don't line up with the interval.
+	for p := data; len(p) > 0; {
+		n := 37
+		if n > len(p) {
+			n = len(p)
+		}
+		h.Write(p[:n])
+		p = p[n:]
+	}
+	if len(offsets) != len(data)/interval {
+		t.Fatalf("got %d checkpoints, want %d", len(offsets), len(data)/interval)
+	}
+	for i, offset := range offsets {
+		if want := (i + 1) * interval; offset != want {
+			t.Errorf("checkpoint %d at offset %d, want %d", i, offset, want)
+		}
+		if want := sha256.Sum256(data[:offset]); !bytes.Equal(digests[i], want[:]) {
+			t.Errorf("checkpoint at offset %d = %x, want %x", offset, digests[i], want)
+		}
+	}
+	// The checkpoints don't disturb the final digest.
+	if want := sha256.Sum256(data); !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Errorf("Sum() = %x, want %x", h.Sum(nil), want)
+	}
+
+	// A single large write reports every checkpoint it crosses.
+	h.Reset()
+	offsets = nil
+	h.Write(data)
+	if len(offsets) != len(data)/interval || offsets[0] != interval {
+		t.Errorf("after Reset, checkpoints at %v", offsets)
+	}
+}
+
+func TestCheckpointHashInvalid(t *testing.T) {
+	cb := func(int, []byte) {}
+	if _, err := cng.NewCheckpointHash("SHA256", 0, cb); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+	if _, err := cng.NewCheckpointHash("SHA256", 10, nil); err == nil {
+		t.Error("expected an error for a nil callback")
+	}
+	if _, err := cng.NewCheckpointHash("NOTAHASH", 10, cb); err == nil {
+		t.Error("expected an error for an unknown hash")
+	}
+}
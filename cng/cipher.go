@@ -8,8 +8,10 @@ package cng
 
 import (
 	"errors"
+	"runtime"
 
 	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+	"github.com/microsoft/go-crypto-winnative/internal/subtle"
 )
 
 type cipherAlgorithm struct {
@@ -39,13 +41,26 @@ func loadCipher(id, mode string) (cipherAlgorithm, error) {
 	return v.(cipherAlgorithm), nil
 }
 
-func newCipherHandle(id, mode string, key []byte) (bcrypt.KEY_HANDLE, error) {
+// loadCipherForKey loads the cipher id with the given chaining mode
+// and checks that key has a length it supports.
+func loadCipherForKey(id, mode string, key []byte) (cipherAlgorithm, error) {
 	h, err := loadCipher(id, mode)
 	if err != nil {
-		return 0, err
+		return cipherAlgorithm{}, err
+	}
+	if h.handle == 0 {
+		return cipherAlgorithm{}, errors.New("cng: unsupported cipher " + id)
 	}
 	if !keyIsAllowed(h.allowedKeyLengths, uint32(len(key)*8)) {
-		return 0, errors.New("crypto/cipher: invalid key size")
+		return cipherAlgorithm{}, errors.New("crypto/cipher: invalid key size")
+	}
+	return h, nil
+}
+
+func newCipherHandle(id, mode string, key []byte) (bcrypt.KEY_HANDLE, error) {
+	h, err := loadCipherForKey(id, mode, key)
+	if err != nil {
+		return 0, err
 	}
 	var kh bcrypt.KEY_HANDLE
 	err = bcrypt.GenerateSymmetricKey(h.handle, &kh, nil, key, 0)
@@ -54,3 +69,93 @@ func newCipherHandle(id, mode string, key []byte) (bcrypt.KEY_HANDLE, error) {
 	}
 	return kh, nil
 }
+
+// SymmetricKey is a CNG symmetric key handle imported by ImportSymmetricKey.
+type SymmetricKey struct {
+	kh bcrypt.KEY_HANDLE
+}
+
+// ImportSymmetricKey imports key for the CNG algorithm algID, such as "AES",
+// by wrapping it in a BCRYPT_KEY_DATA_BLOB. The key uses the provider's
+// default chaining mode. It is destroyed when the SymmetricKey is closed
+// or garbage collected.
+func ImportSymmetricKey(algID string, key []byte) (*SymmetricKey, error) {
+	h, err := loadCipherForKey(algID, "", key)
+	if err != nil {
+		return nil, err
+	}
+	kh, err := importKeyData(h.handle, key)
+	if err != nil {
+		return nil, err
+	}
+	k := &SymmetricKey{kh}
+	runtime.SetFinalizer(k, (*SymmetricKey).finalize)
+	return k, nil
+}
+
+func (k *SymmetricKey) finalize() {
+	bcrypt.DestroyKey(k.kh)
+}
+
+// Handle returns the underlying BCRYPT_KEY_HANDLE, which remains owned by k.
+// See aesCipher.Handle for the ownership rules.
+func (k *SymmetricKey) Handle() uintptr { return uintptr(k.kh) }
+
+// Close destroys the key handle. It returns ErrClosed if k is already closed.
+func (k *SymmetricKey) Close() error {
+	if k.kh == 0 {
+		return ErrClosed
+	}
+	runtime.SetFinalizer(k, nil)
+	bcrypt.DestroyKey(k.kh)
+	k.kh = 0
+	return nil
+}
+
+// SetChainingMode sets the BCRYPT_CHAINING_MODE property of the key,
+// such as "ChainingModeCBC", which is then used by Encrypt and Decrypt.
+// It gives access to modes that this package doesn't otherwise wrap.
+// Setting the chaining mode on a key requires Windows 8 or later.
+func (k *SymmetricKey) SetChainingMode(mode string) error {
+	if k.kh == 0 {
+		return ErrClosed
+	}
+	defer runtime.KeepAlive(k)
+	return setString(bcrypt.HANDLE(k.kh), bcrypt.CHAINING_MODE, mode)
+}
+
+// Encrypt encrypts src into dst using the key's chaining mode, without
+// padding, and returns the number of bytes written. For modes that take
+// an IV, iv is updated in place with the chaining value, so that a
+// later call continues the chain.
+func (k *SymmetricKey) Encrypt(dst, src, iv []byte) (int, error) {
+	return k.crypt(true, dst, src, iv)
+}
+
+// Decrypt decrypts src into dst using the key's chaining mode, without
+// padding, and returns the number of bytes written. iv is updated in
+// place as in Encrypt.
+func (k *SymmetricKey) Decrypt(dst, src, iv []byte) (int, error) {
+	return k.crypt(false, dst, src, iv)
+}
+
+func (k *SymmetricKey) crypt(encrypt bool, dst, src, iv []byte) (int, error) {
+	if k.kh == 0 {
+		return 0, ErrClosed
+	}
+	if len(dst) < len(src) {
+		return 0, errors.New("cng: output smaller than input")
+	}
+	if subtle.InexactOverlap(dst[:len(src)], src) {
+		return 0, errors.New("cng: invalid buffer overlap")
+	}
+	defer runtime.KeepAlive(k)
+	var ret uint32
+	var err error
+	if encrypt {
+		err = bcrypt.Encrypt(k.kh, src, nil, iv, dst, &ret, 0)
+	} else {
+		err = bcrypt.Decrypt(k.kh, src, nil, iv, dst, &ret, 0)
+	}
+	return int(ret), err
+}
@@ -0,0 +1,182 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+func TestImportSymmetricKey(t *testing.T) {
+	k, err := ImportSymmetricKey(bcrypt.AES_ALGORITHM, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := []byte("0123456789abcdef")
+	want := make([]byte, aesBlockSize)
+	block.Encrypt(want, src)
+
+	// AES defaults to CBC, which is equivalent to ECB
+	// for a single block encrypted with a zero IV.
+	got := make([]byte, aesBlockSize)
+	iv := make([]byte, aesBlockSize)
+	var ret uint32
+	err = bcrypt.Encrypt(bcrypt.KEY_HANDLE(k.Handle()), src, nil, iv, got, &ret, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[:ret], want) {
+		t.Errorf("got %x, want %x", got[:ret], want)
+	}
+
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Close(); err != ErrClosed {
+		t.Errorf("second Close returned %v, want ErrClosed", err)
+	}
+	if _, err := ImportSymmetricKey(bcrypt.AES_ALGORITHM, key[:15]); err == nil {
+		t.Error("expected error for invalid key size")
+	}
+}
+
+func TestSymmetricKeySetChainingMode(t *testing.T) {
+	k, err := ImportSymmetricKey(bcrypt.AES_ALGORITHM, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+	if err := k.SetChainingMode(bcrypt.CHAIN_MODE_CBC); err != nil {
+		t.Fatal(err)
+	}
+	block, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := []byte("fedcba9876543210")
+	src := bytes.Repeat([]byte("0123456789abcdef"), 3)
+	want := make([]byte, len(src))
+	cbc := block.(*aesCipher).NewCBCEncrypter(iv)
+	cbc.CryptBlocks(want, src[:2*aesBlockSize])
+	cbc.CryptBlocks(want[2*aesBlockSize:], src[2*aesBlockSize:])
+
+	// Encrypt in two calls, relying on iv carrying the chain over.
+	got := make([]byte, len(src))
+	chain := append([]byte(nil), iv...)
+	n, err := k.Encrypt(got, src[:2*aesBlockSize], chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := k.Encrypt(got[n:], src[n:], chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n+m != len(src) || !bytes.Equal(got, want) {
+		t.Errorf("Encrypt() = %x, want %x", got[:n+m], want)
+	}
+
+	dec := make([]byte, len(src))
+	copy(chain, iv)
+	if n, err := k.Decrypt(dec, got, chain); err != nil || n != len(src) {
+		t.Fatalf("Decrypt() = %d, %v", n, err)
+	}
+	if !bytes.Equal(dec, src) {
+		t.Errorf("Decrypt() = %x, want %x", dec, src)
+	}
+
+	// ECB ignores the IV and matches the block cipher.
+	if err := k.SetChainingMode(bcrypt.CHAIN_MODE_ECB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Encrypt(got[:aesBlockSize], src[:aesBlockSize], nil); err != nil {
+		t.Fatal(err)
+	}
+	block.Encrypt(want[:aesBlockSize], src[:aesBlockSize])
+	if !bytes.Equal(got[:aesBlockSize], want[:aesBlockSize]) {
+		t.Errorf("ECB Encrypt() = %x, want %x", got[:aesBlockSize], want[:aesBlockSize])
+	}
+
+	if err := k.SetChainingMode("NotAChainingMode"); err == nil {
+		t.Error("expected error for unknown chaining mode")
+	}
+	if _, err := k.Encrypt(got[:1], src, nil); err == nil {
+		t.Error("expected error for short output")
+	}
+	k.Close()
+	if err := k.SetChainingMode(bcrypt.CHAIN_MODE_CBC); err != ErrClosed {
+		t.Errorf("SetChainingMode after Close returned %v, want ErrClosed", err)
+	}
+	if _, err := k.Encrypt(got, src, iv); err != ErrClosed {
+		t.Errorf("Encrypt after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func TestAlgCacheConcurrentFirstUse(t *testing.T) {
+	const n = 500
+	// Evict the AES entry so that every goroutine races to open the
+	// provider. Handles from the evicted entry stay open, as existing
+	// keys may still depend on them.
+	algCache.Delete(struct {
+		id    string
+		flags bcrypt.AlgorithmProviderFlags
+		mode  string
+	}{bcrypt.AES_ALGORITHM, bcrypt.ALG_NONE_FLAG, bcrypt.CHAIN_MODE_ECB})
+
+	src := []byte("0123456789abcdef")
+	start := make(chan struct{})
+	handles := make([]bcrypt.ALG_HANDLE, n)
+	outs := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			h, err := loadCipher(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_ECB)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			handles[i] = h.handle
+			c, err := NewAESCipher(key)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			outs[i] = make([]byte, aesBlockSize)
+			c.Encrypt(outs[i], src)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	cached, err := loadCipher(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_ECB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("goroutine %d: %v", i, errs[i])
+		}
+		// Every goroutine must end up with the cached provider,
+		// never with a handle that lost the race and was closed.
+		if handles[i] != cached.handle {
+			t.Fatalf("goroutine %d got handle %x, want cached handle %x", i, handles[i], cached.handle)
+		}
+		if !bytes.Equal(outs[i], outs[0]) {
+			t.Fatalf("goroutine %d: Encrypt() = %x, want %x", i, outs[i], outs[0])
+		}
+	}
+}
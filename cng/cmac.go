@@ -0,0 +1,28 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+	"hash"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// NewCMAC returns a new AES-CMAC hash, as specified in NIST SP 800-38B,
+// using the given 16, 24 or 32-byte key.
+func NewCMAC(key []byte) (hash.Hash, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("crypto/aes: invalid key size")
+	}
+	if _, err := loadHash(bcrypt.AES_CMAC_ALGORITHM, bcrypt.ALG_NONE_FLAG); err != nil {
+		return nil, err
+	}
+	return newHashX(bcrypt.AES_CMAC_ALGORITHM, bcrypt.ALG_NONE_FLAG, key), nil
+}
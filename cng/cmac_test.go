@@ -0,0 +1,42 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestCMAC(t *testing.T) {
+	// Test vectors from RFC 4493, Section 4.
+	key := hexDecode(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	msg := hexDecode(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	tests := []struct {
+		len  int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, tt := range tests {
+		h, err := cng.NewCMAC(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Write(msg[:tt.len])
+		if got := h.Sum(nil); !bytes.Equal(got, hexDecode(t, tt.want)) {
+			t.Errorf("CMAC(%d bytes) = %x, want %s", tt.len, got, tt.want)
+		}
+	}
+	if _, err := cng.NewCMAC(make([]byte, 15)); err == nil {
+		t.Error("expected error for invalid key size")
+	}
+}
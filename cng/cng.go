@@ -25,6 +25,19 @@ func FIPS() (bool, error) {
 	return enabled, nil
 }
 
+// Wipe overwrites b with zeros.
+// It is meant to clear secret material, such as keys, once it is no longer needed.
+// Wipe is not inlined and keeps b alive until the writes are done,
+// so the compiler can't elide them even if b is not used afterwards.
+//
+//go:noinline
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
 // len32 clamps s length so it can fit into a Win32 LONG,
 // which is a 32-bit signed integer, without overflowing.
 func len32(s []byte) int {
@@ -34,6 +47,50 @@ func len32(s []byte) int {
 	return len(s)
 }
 
+// itoa returns the decimal representation of n.
+// It is used instead of strconv, which this package can't import.
+func itoa(n int) string {
+	var buf [20]byte
+	i := len(buf)
+	u := uint64(n)
+	if n < 0 {
+		u = uint64(-n)
+	}
+	for {
+		i--
+		buf[i] = byte('0' + u%10)
+		u /= 10
+		if u == 0 {
+			break
+		}
+	}
+	if n < 0 {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
+
+// wrapError is an error whose message adds context to err,
+// which it unwraps to.
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string { return e.msg }
+func (e *wrapError) Unwrap() error { return e.err }
+
+// ErrClosed is returned when using an object after calling its Close method.
+//
+// Objects check their handle before passing it to CNG, so a use after
+// Close yields ErrClosed instead of touching a destroyed handle. Handles
+// are otherwise only destroyed by finalizers, which can't run while an
+// operation keeps its object alive with runtime.KeepAlive.
+var ErrClosed = errors.New("cng: use of closed object")
+
+// algCache holds the algorithm provider handles. They are kept open for
+// the lifetime of the process, so no operation can see a closed provider.
 var algCache sync.Map
 
 type newAlgEntryFn func(h bcrypt.ALG_HANDLE) (interface{}, error)
@@ -106,9 +163,19 @@ func getUint32(h bcrypt.HANDLE, name string) (uint32, error) {
 const sizeOfKEY_LENGTHS_STRUCT = unsafe.Sizeof(bcrypt.KEY_LENGTHS_STRUCT{})
 
 func getKeyLengths(h bcrypt.HANDLE) (lengths bcrypt.KEY_LENGTHS_STRUCT, err error) {
+	return getLengths(h, bcrypt.KEY_LENGTHS)
+}
+
+func getAuthTagLengths(h bcrypt.HANDLE) (lengths bcrypt.AUTH_TAG_LENGTHS_STRUCT, err error) {
+	return getLengths(h, bcrypt.AUTH_TAG_LENGTH)
+}
+
+// getLengths reads the property name, which must be
+// a BCRYPT_KEY_LENGTHS_STRUCT or a BCRYPT_AUTH_TAG_LENGTHS_STRUCT.
+func getLengths(h bcrypt.HANDLE, name string) (lengths bcrypt.KEY_LENGTHS_STRUCT, err error) {
 	var discard uint32
 	ptr := (*[sizeOfKEY_LENGTHS_STRUCT]byte)(unsafe.Pointer(&lengths))
-	err = bcrypt.GetProperty(bcrypt.HANDLE(h), utf16PtrFromString(bcrypt.KEY_LENGTHS), ptr[:], &discard, 0)
+	err = bcrypt.GetProperty(bcrypt.HANDLE(h), utf16PtrFromString(name), ptr[:], &discard, 0)
 	if err != nil {
 		return
 	}
@@ -39,3 +39,15 @@ func TestFIPS(t *testing.T) {
 		}
 	}
 }
+
+func TestWipe(t *testing.T) {
+	b := []byte("secret key material")
+	cng.Wipe(b)
+	for i, v := range b {
+		if v != 0 {
+			t.Fatalf("b[%d] = %#x, want 0", i, v)
+		}
+	}
+	// Wiping an empty slice must not panic.
+	cng.Wipe(nil)
+}
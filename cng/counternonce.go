@@ -0,0 +1,78 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+var errCounterExhausted = errors.New("cng: nonce counter exhausted")
+
+// GCMCounterNonce seals records with an AEAD whose nonces are formed
+// from a fixed 4-byte salt followed by a 64-bit big-endian record
+// counter, the "fixed IV + record number" construction of RFC 5116,
+// Section 3.2. The counter starts at zero and advances on every Seal,
+// so a nonce is never reused with the same GCMCounterNonce.
+type GCMCounterNonce struct {
+	aead cipher.AEAD
+	salt [4]byte
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// NewGCMCounterNonce returns a GCMCounterNonce sealing with aead, which
+// must take 12-byte nonces, such as the AEAD returned by NewGCMFromKey.
+func NewGCMCounterNonce(aead cipher.AEAD, salt [4]byte) (*GCMCounterNonce, error) {
+	if aead.NonceSize() != gcmStandardNonceSize {
+		return nil, errors.New("cng: counter nonces require a 12-byte AEAD nonce")
+	}
+	return &GCMCounterNonce{aead: aead, salt: salt}, nil
+}
+
+// Overhead returns the length of the authentication tag appended to each record.
+func (c *GCMCounterNonce) Overhead() int { return c.aead.Overhead() }
+
+// Counter returns the record counter the next call to Seal will use.
+func (c *GCMCounterNonce) Counter() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.next
+}
+
+func (c *GCMCounterNonce) nonce(counter uint64) [gcmStandardNonceSize]byte {
+	var nonce [gcmStandardNonceSize]byte
+	copy(nonce[:], c.salt[:])
+	binary.BigEndian.PutUint64(nonce[len(c.salt):], counter)
+	return nonce
+}
+
+// Seal encrypts and authenticates plaintext under the next record counter
+// and appends the result to dst. Once 2^64 - 1 records have been sealed
+// it returns an error rather than reusing a nonce; as with the TLS GCM
+// mode, the all-ones counter is never used.
+func (c *GCMCounterNonce) Seal(dst, plaintext, additionalData []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next == 1<<64-1 {
+		return nil, errCounterExhausted
+	}
+	nonce := c.nonce(c.next)
+	c.next++
+	return c.aead.Seal(dst, nonce[:], plaintext, additionalData), nil
+}
+
+// Open decrypts and authenticates the record sealed under counter and
+// appends the plaintext to dst. It does not track which records have
+// been opened; rejecting replays is up to the record protocol.
+func (c *GCMCounterNonce) Open(dst []byte, counter uint64, ciphertext, additionalData []byte) ([]byte, error) {
+	nonce := c.nonce(counter)
+	return c.aead.Open(dst, nonce[:], ciphertext, additionalData)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestGCMCounterNonce(t *testing.T) {
+	g := newTestGCM(t)
+	salt := [4]byte{0xde, 0xad, 0xbe, 0xef}
+	c, err := NewGCMCounterNonce(g, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ad := []byte("header")
+	seen := make(map[string]bool)
+	for i := uint64(0); i < 16; i++ {
+		if got := c.Counter(); got != i {
+			t.Fatalf("Counter() = %d, want %d", got, i)
+		}
+		plaintext := []byte("the same record every time")
+		sealed, err := c.Seal(nil, plaintext, ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[string(sealed)] {
+			t.Fatalf("record %d repeats an earlier ciphertext", i)
+		}
+		seen[string(sealed)] = true
+
+		// The record must open under salt || i and nothing else.
+		var nonce [gcmStandardNonceSize]byte
+		copy(nonce[:], salt[:])
+		binary.BigEndian.PutUint64(nonce[4:], i)
+		got, err := g.Open(nil, nonce[:], sealed, ad)
+		if err != nil {
+			t.Fatalf("record %d: not sealed with salt || counter: %v", i, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("record %d: got %q, want %q", i, got, plaintext)
+		}
+		if got, err := c.Open(nil, i, sealed, ad); err != nil || !bytes.Equal(got, plaintext) {
+			t.Fatalf("record %d: Open = %q, %v", i, got, err)
+		}
+		if _, err := c.Open(nil, i+1, sealed, ad); err == nil {
+			t.Fatalf("record %d opened under the wrong counter", i)
+		}
+	}
+}
+
+func TestGCMCounterNonceExhausted(t *testing.T) {
+	c, err := NewGCMCounterNonce(newTestGCM(t), [4]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.next = 1<<64 - 2
+	if _, err := c.Seal(nil, nil, nil); err != nil {
+		t.Fatalf("last record: %v", err)
+	}
+	if _, err := c.Seal(nil, nil, nil); err != errCounterExhausted {
+		t.Fatalf("err = %v, want %v", err, errCounterExhausted)
+	}
+	if got := c.Counter(); got != 1<<64-1 {
+		t.Errorf("counter advanced past exhaustion: %d", got)
+	}
+}
+
+func TestGCMCounterNonceSize(t *testing.T) {
+	block, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := block.(*aesCipher).NewGCM(16, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGCMCounterNonce(aead, [4]byte{}); err == nil {
+		t.Error("expected error for 16-byte nonce AEAD")
+	}
+}
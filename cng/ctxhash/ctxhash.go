@@ -0,0 +1,23 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package ctxhash
+
+import (
+	"context"
+	"io"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// HashReaderContext is like cng.HashReader but checks ctx between chunks
+// and, once ctx is done, returns ctx.Err() and destroys the partial hash.
+//
+// A Read call that blocks is not interrupted; use a reader that honors
+// ctx for that.
+func HashReaderContext(ctx context.Context, hashID string, r io.Reader) ([]byte, error) {
+	return cng.HashReaderUntil(hashID, r, ctx.Err)
+}
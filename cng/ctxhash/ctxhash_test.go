@@ -0,0 +1,106 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package ctxhash_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-crypto-winnative/cng/ctxhash"
+)
+
+// patternReader returns a deterministic byte stream of n bytes
+// without holding it in memory.
+type patternReader struct {
+	n, off int
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.off >= r.n {
+		return 0, io.EOF
+	}
+	if len(p) > r.n-r.off {
+		p = p[:r.n-r.off]
+	}
+	for i := range p {
+		p[i] = byte((r.off + i) % 251)
+	}
+	r.off += len(p)
+	return len(p), nil
+}
+
+// slowReader delays each Read from r and calls onRead
+// with the number of reads made so far.
+type slowReader struct {
+	r      io.Reader
+	delay  time.Duration
+	reads  int
+	onRead func(reads int)
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	r.reads++
+	if r.onRead != nil {
+		r.onRead(r.reads)
+	}
+	return r.r.Read(p)
+}
+
+func TestHashReaderContext(t *testing.T) {
+	const size = 1<<20 + 3
+	r := &slowReader{r: &patternReader{n: size}, delay: time.Millisecond}
+	got, err := ctxhash.HashReaderContext(context.Background(), "SHA256", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.New()
+	io.Copy(want, &patternReader{n: size})
+	if !bytes.Equal(got, want.Sum(nil)) {
+		t.Errorf("got:%x want:%x", got, want.Sum(nil))
+	}
+}
+
+func TestHashReaderContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const cancelAt = 3
+	r := &slowReader{r: &patternReader{n: 1 << 30}, delay: time.Millisecond, onRead: func(reads int) {
+		if reads == cancelAt {
+			cancel()
+		}
+	}}
+	_, err := ctxhash.HashReaderContext(ctx, "SHA256", r)
+	if err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if r.reads != cancelAt {
+		t.Errorf("read %d chunks, want to stop after %d", r.reads, cancelAt)
+	}
+
+	// A context that is already done stops before the first read.
+	r = &slowReader{r: &patternReader{n: 1 << 30}}
+	if _, err := ctxhash.HashReaderContext(ctx, "SHA256", r); err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if r.reads != 0 {
+		t.Errorf("read %d chunks from a cancelled context", r.reads)
+	}
+}
+
+func TestHashReaderContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r := &slowReader{r: &patternReader{n: 1 << 30}, delay: 5 * time.Millisecond}
+	if _, err := ctxhash.HashReaderContext(ctx, "SHA256", r); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,8 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Package ctxhash hashes streams with cng while honoring a context.
+//
+// It lives outside package cng so that cng keeps the dependencies of
+// the crypto packages it backs, which can't import context.
+package ctxhash
@@ -0,0 +1,99 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import "errors"
+
+// Curve identifies an elliptic curve.
+// It is a typed alternative to the curve names, such as "P-256",
+// accepted by the ECDH functions.
+type Curve int
+
+const (
+	CurveP256 Curve = iota + 1
+	CurveP384
+	CurveP521
+	CurveX25519
+)
+
+// String returns the curve name, as accepted by the ECDH functions,
+// or an empty string if c is not a valid curve.
+func (c Curve) String() string {
+	switch c {
+	case CurveP256:
+		return "P-256"
+	case CurveP384:
+		return "P-384"
+	case CurveP521:
+		return "P-521"
+	case CurveX25519:
+		return "X25519"
+	}
+	return ""
+}
+
+// name returns the curve name or errUnknownCurve if c is not valid.
+func (c Curve) name() (string, error) {
+	name := c.String()
+	if name == "" {
+		return "", errUnknownCurve
+	}
+	return name, nil
+}
+
+// GenerateKeyECDH is like the package-level GenerateKeyECDH
+// but takes the curve from c.
+func (c Curve) GenerateKeyECDH() (*PrivateKeyECDH, []byte, error) {
+	name, err := c.name()
+	if err != nil {
+		return nil, nil, err
+	}
+	return GenerateKeyECDH(name)
+}
+
+// NewPublicKeyECDH is like the package-level NewPublicKeyECDH
+// but takes the curve from c.
+func (c Curve) NewPublicKeyECDH(bytes []byte) (*PublicKeyECDH, error) {
+	name, err := c.name()
+	if err != nil {
+		return nil, err
+	}
+	return NewPublicKeyECDH(name, bytes)
+}
+
+// NewPrivateKeyECDH is like the package-level NewPrivateKeyECDH
+// but takes the curve from c.
+func (c Curve) NewPrivateKeyECDH(key []byte) (*PrivateKeyECDH, error) {
+	name, err := c.name()
+	if err != nil {
+		return nil, err
+	}
+	return NewPrivateKeyECDH(name, key)
+}
+
+// negotiationOrder lists the ECDH curves from strongest to weakest.
+// X25519 and P-256 both provide 128-bit security; X25519 is preferred.
+var negotiationOrder = []string{"P-521", "P-384", "X25519", "P-256"}
+
+// NegotiateCurve returns the strongest curve among the offered curve
+// names, such as "P-256", that is also supported by the local CNG
+// provider. Curves that are unknown to this package or unavailable on
+// this version of Windows are skipped.
+func NegotiateCurve(offered []string) (string, error) {
+	for _, curve := range negotiationOrder {
+		for _, o := range offered {
+			if o != curve {
+				continue
+			}
+			if _, _, err := loadECDH(curve); err == nil {
+				return curve, nil
+			}
+			break
+		}
+	}
+	return "", errors.New("cng: no mutually supported elliptic curve")
+}
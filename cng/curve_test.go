@@ -0,0 +1,95 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestCurveGenerateKeyECDH(t *testing.T) {
+	for _, c := range []cng.Curve{cng.CurveP256, cng.CurveP384, cng.CurveP521, cng.CurveX25519} {
+		t.Run(c.String(), func(t *testing.T) {
+			aliceKey, _, err := c.GenerateKeyECDH()
+			if err != nil {
+				t.Fatal(err)
+			}
+			bobKey, bobPrivBytes, err := c.GenerateKeyECDH()
+			if err != nil {
+				t.Fatal(err)
+			}
+			bobKey2, err := c.NewPrivateKeyECDH(bobPrivBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			alicePub, err := aliceKey.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			alicePub2, err := c.NewPublicKeyECDH(alicePub.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret, err := cng.ECDH(bobKey, alicePub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret2, err := cng.ECDH(bobKey2, alicePub2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(secret, secret2) {
+				t.Error("two ECDH computations came out different")
+			}
+		})
+	}
+}
+
+func TestCurveInvalid(t *testing.T) {
+	for _, c := range []cng.Curve{0, cng.CurveX25519 + 1, -1} {
+		if c.String() != "" {
+			t.Errorf("Curve(%d).String() = %q, want empty", int(c), c.String())
+		}
+		if _, _, err := c.GenerateKeyECDH(); err == nil {
+			t.Errorf("Curve(%d).GenerateKeyECDH: expected error", int(c))
+		}
+		if _, err := c.NewPublicKeyECDH([]byte{4, 1, 2}); err == nil {
+			t.Errorf("Curve(%d).NewPublicKeyECDH: expected error", int(c))
+		}
+		if _, err := c.NewPrivateKeyECDH(make([]byte, 32)); err == nil {
+			t.Errorf("Curve(%d).NewPrivateKeyECDH: expected error", int(c))
+		}
+	}
+}
+
+func TestNegotiateCurve(t *testing.T) {
+	for _, tt := range []struct {
+		offered []string
+		want    string
+	}{
+		{[]string{"P-256", "brainpoolP256r1", "P-384"}, "P-384"},
+		{[]string{"secp256k1", "P-256"}, "P-256"},
+		{[]string{"P-256", "P-521", "P-384"}, "P-521"},
+		{[]string{"P-256", "X25519"}, "X25519"},
+	} {
+		got, err := cng.NegotiateCurve(tt.offered)
+		if err != nil {
+			t.Errorf("NegotiateCurve(%q): %v", tt.offered, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NegotiateCurve(%q) = %q, want %q", tt.offered, got, tt.want)
+		}
+	}
+	for _, offered := range [][]string{nil, {"secp256k1", "p-256", "Curve448"}} {
+		if got, err := cng.NegotiateCurve(offered); err == nil {
+			t.Errorf("NegotiateCurve(%q) = %q, want an error", offered, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package cng_test
+
+import (
+	"go/build"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const modulePath = "github.com/microsoft/go-crypto-winnative/"
+
+// allowedDeps lists the standard library packages that cng, cng/backend
+// and the internal packages they use may import. Package cng replaces
+// crypto/internal/boring, so it must only depend on packages that Go's
+// crypto packages may import: no fmt, strconv, time, context, math/big
+// or encoding packages other than encoding/binary.
+var allowedDeps = map[string]bool{
+	"crypto":          true,
+	"crypto/cipher":   true,
+	"crypto/subtle":   true,
+	"encoding/binary": true,
+	"errors":          true,
+	"hash":            true,
+	"io":              true,
+	"math":            true,
+	"math/bits":       true,
+	"runtime":         true,
+	"sync":            true,
+	"sync/atomic":     true,
+	"syscall":         true,
+	"unsafe":          true,
+}
+
+func TestDependencies(t *testing.T) {
+	ctxt := build.Default
+	// Check the files of every platform, not only the current one.
+	ctxt.UseAllFiles = true
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	pkgs := []string{"cng", "cng/backend"}
+	for len(pkgs) > 0 {
+		pkg := pkgs[0]
+		pkgs = pkgs[1:]
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		p, err := ctxt.ImportDir(filepath.Join(root, filepath.FromSlash(pkg)), 0)
+		if err != nil {
+			t.Fatalf("%s: %v", pkg, err)
+		}
+		var bad []string
+		for _, imp := range p.Imports {
+			switch {
+			case strings.HasPrefix(imp, modulePath):
+				pkgs = append(pkgs, strings.TrimPrefix(imp, modulePath))
+			case !allowedDeps[imp]:
+				bad = append(bad, imp)
+			}
+		}
+		if len(bad) > 0 {
+			sort.Strings(bad)
+			t.Errorf("%s imports disallowed packages %v", pkg, bad)
+		}
+	}
+}
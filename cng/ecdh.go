@@ -7,8 +7,10 @@
 package cng
 
 import (
+	"crypto"
 	"errors"
 	"runtime"
+	"unsafe"
 
 	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
 )
@@ -18,6 +20,23 @@ const ecdhUncompressedPrefix = 4
 var errInvalidPublicKey = errors.New("cng: invalid public key")
 var errInvalidPrivateKey = errors.New("cng: invalid private key")
 
+// keyImportError reports a key rejected by CNG on import.
+// errors.Is matches it against kind, errInvalidPublicKey or
+// errInvalidPrivateKey, and it unwraps to the CNG error,
+// so Status returns the NTSTATUS of the failed import.
+type keyImportError struct {
+	kind error
+	blob string // the curve and blob type, for example "P-256 ECCPUBLICBLOB"
+	err  error
+}
+
+func (e *keyImportError) Error() string {
+	return e.kind.Error() + ": CNG rejected " + e.blob + ": " + e.err.Error()
+}
+
+func (e *keyImportError) Is(target error) bool { return target == e.kind }
+func (e *keyImportError) Unwrap() error        { return e.err }
+
 type ecdhAlgorithm struct {
 	handle bcrypt.ALG_HANDLE
 }
@@ -80,54 +99,272 @@ func (k *PrivateKeyECDH) finalize() {
 
 func ECDH(priv *PrivateKeyECDH, pub *PublicKeyECDH) ([]byte, error) {
 	// First establish the shared secret.
-	var secret bcrypt.SECRET_HANDLE
-	err := bcrypt.SecretAgreement(priv.hkey, pub.hkey, &secret, 0)
+	secret, err := secretAgreement(priv, pub)
 	if err != nil {
 		return nil, err
 	}
 	defer bcrypt.DestroySecret(secret)
+	agreedSecret, err := rawSecret(secret)
+	runtime.KeepAlive(priv)
+	runtime.KeepAlive(pub)
+	return agreedSecret, err
+}
 
-	// Then we need to export the raw shared secret from the secret opaque handler.
-	// The only way to do it is using BCryptDeriveKey with BCRYPT_KDF_RAW_SECRET as key derivation function (KDF).
-	// Unfortunately, this KDF is supported starting from Windows 10.
-	kdf := utf16PtrFromString(bcrypt.KDF_RAW_SECRET)
-	var size uint32
-	err = bcrypt.DeriveKey(secret, kdf, nil, nil, &size, 0)
+// ECDHOptions configures ECDHWithOptions.
+type ECDHOptions struct {
+	// Cofactor requests cofactor ECDH, as specified in SP 800-56A,
+	// Section 5.7.1.2, which multiplies the shared point by the curve
+	// cofactor and rejects the identity element.
+	//
+	// The multiplication is a no-op for all the supported curves:
+	// the NIST prime curves have cofactor 1, and X25519 private scalars
+	// are always multiples of its cofactor 8 (RFC 7748, Section 5).
+	// The identity check does apply, rejecting the all-zero X25519
+	// output produced by small-order public keys.
+	Cofactor bool
+}
+
+// ECDHWithOptions is like ECDH but configured by opts.
+func ECDHWithOptions(priv *PrivateKeyECDH, pub *PublicKeyECDH, opts ECDHOptions) ([]byte, error) {
+	secret, err := ECDH(priv, pub)
 	if err != nil {
 		return nil, err
 	}
-	agreedSecret := make([]byte, size)
-	err = bcrypt.DeriveKey(secret, kdf, nil, agreedSecret, &size, 0)
+	if opts.Cofactor {
+		var acc byte
+		for _, b := range secret {
+			acc |= b
+		}
+		if acc == 0 {
+			return nil, errors.New("cng: ECDH shared secret is the identity element")
+		}
+	}
+	return secret, nil
+}
+
+// rawSecret exports the raw shared secret in big-endian form.
+func rawSecret(secret bcrypt.SECRET_HANDLE) ([]byte, error) {
+	// The only way to export the raw shared secret from the secret opaque handler
+	// is using BCryptDeriveKey with BCRYPT_KDF_RAW_SECRET as key derivation function (KDF).
+	// Unfortunately, this KDF is supported starting from Windows 10.
+	agreedSecret, err := deriveKey(secret, bcrypt.KDF_RAW_SECRET, nil)
 	if err != nil {
 		return nil, err
 	}
-
-	// The raw shared secret is little-endian but Go expects big-endian.
-	// Reverse the slice in-place.
+	// BCRYPT_KDF_RAW_SECRET always returns the secret in little-endian
+	// form, for every curve and Windows version. crypto/ecdh returns the
+	// big-endian x-coordinate, or the X25519 output in its RFC 7748
+	// encoding, which CNG reverses as well. The buffer has the full field
+	// size, so reversing it in-place keeps any leading zeros.
+	size := len(agreedSecret)
 	inputMid := size / 2
-	for i := uint32(0); i < inputMid; i++ {
+	for i := 0; i < inputMid; i++ {
 		j := size - i - 1
 		agreedSecret[i], agreedSecret[j] = agreedSecret[j], agreedSecret[i]
 	}
-	runtime.KeepAlive(priv)
-	runtime.KeepAlive(pub)
 	return agreedSecret, nil
 }
 
-func GenerateKeyECDH(curve string) (*PrivateKeyECDH, []byte, error) {
-	h, bits, err := loadECDH(curve)
+func deriveKey(secret bcrypt.SECRET_HANDLE, kdf string, params *bcrypt.BufferDesc) ([]byte, error) {
+	kdf16 := utf16PtrFromString(kdf)
+	var size uint32
+	err := bcrypt.DeriveKey(secret, kdf16, params, nil, &size, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, size)
+	err = bcrypt.DeriveKey(secret, kdf16, params, out, &size, 0)
 	if err != nil {
+		return nil, err
+	}
+	return out[:size], nil
+}
+
+// Key derivation functions supported by ECDHWithKDF.
+const (
+	KDFHash = bcrypt.KDF_HASH // Hash(Prepend || secret || Append)
+	KDFHMAC = bcrypt.KDF_HMAC // HMAC(HMACKey, Prepend || secret || Append)
+)
+
+// KDFSpec selects one of the CNG built-in key derivation functions
+// to be applied to an ECDH shared secret.
+type KDFSpec struct {
+	// Name is the KDF, either KDFHash or KDFHMAC.
+	// If empty, no KDF is applied.
+	Name string
+	// Hash is the hash function used by the KDF.
+	// If zero, CNG defaults to SHA-1.
+	Hash crypto.Hash
+	// Prepend and Append are concatenated to the secret before hashing.
+	Prepend, Append []byte
+	// HMACKey is the HMAC key used by KDFHMAC.
+	HMACKey []byte
+}
+
+// ECDHWithKDF performs ECDH and returns both the raw shared secret,
+// as returned by ECDH, and the output of kdf applied to it by CNG.
+// derived is nil if kdf.Name is empty.
+func ECDHWithKDF(priv *PrivateKeyECDH, pub *PublicKeyECDH, kdf KDFSpec) (raw, derived []byte, err error) {
+	if err := kdf.check(); err != nil {
 		return nil, nil, err
 	}
-	var hkey bcrypt.KEY_HANDLE
-	err = bcrypt.GenerateKeyPair(h.handle, &hkey, bits, 0)
+	secret, err := secretAgreement(priv, pub)
 	if err != nil {
 		return nil, nil, err
 	}
-	// The key cannot be used until BCryptFinalizeKeyPair has been called.
-	err = bcrypt.FinalizeKeyPair(hkey, 0)
+	defer bcrypt.DestroySecret(secret)
+	defer runtime.KeepAlive(priv)
+	defer runtime.KeepAlive(pub)
+	raw, err = rawSecret(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	if kdf.Name == "" {
+		return raw, nil, nil
+	}
+	derived, err = deriveWithKDF(secret, kdf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, derived, nil
+}
+
+func (kdf *KDFSpec) check() error {
+	switch kdf.Name {
+	case "", KDFHash, KDFHMAC:
+		return nil
+	default:
+		return errors.New("cng: unsupported KDF " + kdf.Name)
+	}
+}
+
+// deriveWithKDF applies kdf, which must have a name, to secret.
+func deriveWithKDF(secret bcrypt.SECRET_HANDLE, kdf KDFSpec) ([]byte, error) {
+	var buffers []bcrypt.Buffer
+	if kdf.Hash != 0 {
+		hashID := cryptoHashToID(kdf.Hash)
+		if hashID == "" {
+			return nil, errors.New("cng: unsupported hash function")
+		}
+		u16HashID := utf16FromString(hashID)
+		buffers = append(buffers, bcrypt.Buffer{
+			Type:   bcrypt.KDF_HASH_ALGORITHM,
+			Data:   uintptr(unsafe.Pointer(&u16HashID[0])),
+			Length: uint32(len(u16HashID) * 2),
+		})
+		defer runtime.KeepAlive(u16HashID)
+	}
+	for _, p := range []struct {
+		typ  uint32
+		data []byte
+	}{
+		{bcrypt.KDF_SECRET_PREPEND, kdf.Prepend},
+		{bcrypt.KDF_SECRET_APPEND, kdf.Append},
+		{bcrypt.KDF_HMAC_KEY, kdf.HMACKey},
+	} {
+		if len(p.data) > 0 {
+			buffers = append(buffers, bcrypt.Buffer{
+				Type:   p.typ,
+				Data:   uintptr(unsafe.Pointer(&p.data[0])),
+				Length: uint32(len(p.data)),
+			})
+		}
+	}
+	defer runtime.KeepAlive(kdf)
+	var params *bcrypt.BufferDesc
+	if len(buffers) > 0 {
+		params = &bcrypt.BufferDesc{
+			Count:   uint32(len(buffers)),
+			Buffers: &buffers[0],
+		}
+	}
+	return deriveKey(secret, kdf.Name, params)
+}
+
+// AgreedSecret is an ECDH shared secret held by CNG. It separates the
+// secret agreement from the key derivation, so that one agreement can
+// feed several derivations without being exported.
+type AgreedSecret struct {
+	h bcrypt.SECRET_HANDLE
+}
+
+// secretAgreement establishes the shared secret between priv and pub,
+// which the caller must destroy.
+func secretAgreement(priv *PrivateKeyECDH, pub *PublicKeyECDH) (bcrypt.SECRET_HANDLE, error) {
+	defer runtime.KeepAlive(priv)
+	defer runtime.KeepAlive(pub)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditAgree, Algorithm: "ECDH", KeyBits: keyBits(priv.hkey)}, perfCounter())
+	}
+	defer releaseOp(acquireOp())
+	var secret bcrypt.SECRET_HANDLE
+	err := bcrypt.SecretAgreement(priv.hkey, pub.hkey, &secret, 0)
+	return secret, err
+}
+
+// SecretAgreement performs ECDH between priv and pub and returns the
+// shared secret without exporting it.
+func SecretAgreement(priv *PrivateKeyECDH, pub *PublicKeyECDH) (*AgreedSecret, error) {
+	defer runtime.KeepAlive(priv)
+	defer runtime.KeepAlive(pub)
+	h, err := secretAgreement(priv, pub)
+	if err != nil {
+		return nil, err
+	}
+	s := &AgreedSecret{h}
+	runtime.SetFinalizer(s, (*AgreedSecret).finalize)
+	return s, nil
+}
+
+func (s *AgreedSecret) finalize() {
+	bcrypt.DestroySecret(s.h)
+}
+
+// Raw returns the raw shared secret, as returned by ECDH.
+func (s *AgreedSecret) Raw() ([]byte, error) {
+	if s.h == 0 {
+		return nil, ErrClosed
+	}
+	defer runtime.KeepAlive(s)
+	return rawSecret(s.h)
+}
+
+// DeriveKey applies kdf to the shared secret. kdf.Name must not be empty.
+func (s *AgreedSecret) DeriveKey(kdf KDFSpec) ([]byte, error) {
+	if s.h == 0 {
+		return nil, ErrClosed
+	}
+	if kdf.Name == "" {
+		return nil, errors.New("cng: missing KDF")
+	}
+	if err := kdf.check(); err != nil {
+		return nil, err
+	}
+	defer runtime.KeepAlive(s)
+	return deriveWithKDF(s.h, kdf)
+}
+
+// Close destroys the secret handle. It returns ErrClosed if s is already closed.
+func (s *AgreedSecret) Close() error {
+	if s.h == 0 {
+		return ErrClosed
+	}
+	runtime.SetFinalizer(s, nil)
+	bcrypt.DestroySecret(s.h)
+	s.h = 0
+	return nil
+}
+
+func GenerateKeyECDH(curve string) (*PrivateKeyECDH, []byte, error) {
+	h, bits, err := loadECDH(curve)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hook := auditHook(); hook != nil {
+		hook(AuditEvent{Op: AuditGenerateKey, Algorithm: "ECDH", KeyBits: int(bits)})
+	}
+	hkey, err := generateKeyPair(h.handle, bits)
 	if err != nil {
-		bcrypt.DestroyKey(hkey)
 		return nil, nil, err
 	}
 
@@ -148,34 +385,43 @@ func GenerateKeyECDH(curve string) (*PrivateKeyECDH, []byte, error) {
 }
 
 func NewPublicKeyECDH(curve string, bytes []byte) (*PublicKeyECDH, error) {
-	// Reject the point at infinity and compressed encodings.
-	// The first byte is always the key encoding.
-	nist := isNIST(curve)
-	if len(bytes) == 0 || (nist && bytes[0] != ecdhUncompressedPrefix) {
-		return nil, errInvalidPublicKey
-	}
 	h, bits, err := loadECDH(curve)
 	if err != nil {
 		return nil, err
 	}
+	// NIST public keys are uncompressed points, 0x04 || X || Y,
+	// and X25519 public keys are the raw u-coordinate.
+	nist := isNIST(curve)
+	keySize := int(bits+7) / 8
+	if nist {
+		if want := 1 + 2*keySize; len(bytes) != want {
+			return nil, &wrapError{errInvalidPublicKey.Error() + ": " + curve + " public key is " + itoa(len(bytes)) + " bytes, want " + itoa(want) + " (uncompressed point)", errInvalidPublicKey}
+		}
+		// Reject the point at infinity and compressed encodings.
+		if bytes[0] != ecdhUncompressedPrefix {
+			return nil, &wrapError{errInvalidPublicKey.Error() + ": " + curve + " public key has encoding byte " + itoa(int(bytes[0])) + ", want " + itoa(ecdhUncompressedPrefix) + " (uncompressed point)", errInvalidPublicKey}
+		}
+	} else if len(bytes) != keySize {
+		return nil, &wrapError{errInvalidPublicKey.Error() + ": " + curve + " public key is " + itoa(len(bytes)) + " bytes, want " + itoa(keySize), errInvalidPublicKey}
+	}
 	// Remove the encoding byte, if any. BCrypt doesn't want it
 	// and it only support uncompressed points anyway.
-	var keyWithoutEncoding []byte
-	var ncomponents int
+	keyWithoutEncoding := bytes
 	if nist {
-		ncomponents = 2
 		keyWithoutEncoding = bytes[1:]
-	} else {
-		ncomponents = 1
-		keyWithoutEncoding = bytes
 	}
-	keySize := int(bits+7) / 8
-	if len(keyWithoutEncoding) != keySize*ncomponents {
-		return nil, errInvalidPublicKey
+	if !nist {
+		// RFC 7748 requires X25519 implementations to ignore the most
+		// significant bit of the u-coordinate, which crypto/ecdh does.
+		// Mask it in a copy so Bytes still returns the caller's encoding.
+		var u [32]byte
+		copy(u[:], keyWithoutEncoding)
+		u[31] &= 127 // 0b0111_1111
+		keyWithoutEncoding = u[:]
 	}
 	hkey, err := importECCKey(h.handle, bcrypt.ECDH_ALGORITHM, bits, keyWithoutEncoding[:keySize], keyWithoutEncoding[keySize:], nil)
 	if err != nil {
-		return nil, err
+		return nil, &keyImportError{errInvalidPublicKey, curve + " " + bcrypt.ECCPUBLIC_BLOB, err}
 	}
 	k := &PublicKeyECDH{hkey, append([]byte(nil), bytes...), nil}
 	runtime.SetFinalizer(k, (*PublicKeyECDH).finalize)
@@ -191,11 +437,13 @@ func NewPrivateKeyECDH(curve string, key []byte) (*PrivateKeyECDH, error) {
 	}
 	keySize := int(bits+7) / 8
 	if len(key) != keySize {
-		return nil, errInvalidPrivateKey
+		return nil, &wrapError{errInvalidPrivateKey.Error() + ": " + curve + " private key is " + itoa(len(key)) + " bytes, want " + itoa(keySize) + " (scalar)", errInvalidPrivateKey}
 	}
 	nist := isNIST(curve)
 	if !nist {
 		key = convertX25519PrivKey(key)
+		// key is now a private copy of the caller's key.
+		defer Wipe(key)
 	}
 	// CNG allows to import private ECC keys without defining X/Y,
 	// in which case those will be generated from D.
@@ -204,13 +452,30 @@ func NewPrivateKeyECDH(curve string, key []byte) (*PrivateKeyECDH, error) {
 	var zero [(521 + 7) / 8]byte
 	hkey, err := importECCKey(h.handle, bcrypt.ECDH_ALGORITHM, bits, zero[:keySize], zero[:keySize], key)
 	if err != nil {
-		return nil, err
+		return nil, &keyImportError{errInvalidPrivateKey, curve + " " + bcrypt.ECCPRIVATE_BLOB, err}
 	}
 	k := &PrivateKeyECDH{hkey, nist}
 	runtime.SetFinalizer(k, (*PrivateKeyECDH).finalize)
 	return k, nil
 }
 
+// Bytes returns the private key of k in the encoding accepted by
+// NewPrivateKeyECDH, as GenerateKeyECDH does. The result is sensitive
+// and should be wiped, for example with Wipe, when no longer needed.
+func (k *PrivateKeyECDH) Bytes() ([]byte, error) {
+	defer runtime.KeepAlive(k)
+	hdr, data, err := exportECCKey(k.hkey, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 3*int(hdr.KeySize) {
+		Wipe(data)
+		return nil, errors.New("cng: exported key is corrupted")
+	}
+	// The private component is the last of the three equally-sized chunks.
+	return data[2*hdr.KeySize : 3*hdr.KeySize], nil
+}
+
 func (k *PrivateKeyECDH) PublicKey() (*PublicKeyECDH, error) {
 	defer runtime.KeepAlive(k)
 	hdr, data, err := exportECCKey(k.hkey, false)
@@ -0,0 +1,158 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows && go1.20
+// +build windows,go1.20
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+var stdECDHCurves = map[string]ecdh.Curve{
+	"P-256":  ecdh.P256(),
+	"P-384":  ecdh.P384(),
+	"P-521":  ecdh.P521(),
+	"X25519": ecdh.X25519(),
+}
+
+func TestECDHStdlibCompat(t *testing.T) {
+	for name, curve := range stdECDHCurves {
+		name, curve := name, curve
+		t.Run(name, func(t *testing.T) {
+			stdAlice, err := curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdBob, err := curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			alice, err := cng.NewPrivateKeyECDH(name, stdAlice.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			alicePub, err := alice.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := stdAlice.PublicKey().Bytes(); !bytes.Equal(alicePub.Bytes(), want) {
+				t.Errorf("public key mismatch:\ngot  %x\nwant %x", alicePub.Bytes(), want)
+			}
+			bobPub, err := cng.NewPublicKeyECDH(name, stdBob.PublicKey().Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := cng.ECDH(alice, bobPub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := stdBob.ECDH(stdAlice.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("shared secret mismatch:\ngot  %x\nwant %x", got, want)
+			}
+
+			// Keys generated here must also be usable by crypto/ecdh.
+			cngKey, cngPrivBytes, err := cng.GenerateKeyECDH(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stdKey, err := curve.NewPrivateKey(cngPrivBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err = cng.ECDH(cngKey, bobPub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err = stdKey.ECDH(stdBob.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("shared secret mismatch for exported key:\ngot  %x\nwant %x", got, want)
+			}
+		})
+	}
+}
+
+func TestX25519PublicKeyHighBit(t *testing.T) {
+	stdPriv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := append([]byte(nil), peer.PublicKey().Bytes()...)
+	u[31] |= 0x80
+	stdPub, err := ecdh.X25519().NewPublicKey(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := stdPriv.ECDH(stdPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, err := cng.NewPrivateKeyECDH("X25519", stdPriv.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDH("X25519", u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pub.Bytes(), u) {
+		t.Errorf("Bytes() = %x, want %x", pub.Bytes(), u)
+	}
+	got, err := cng.ECDH(priv, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("shared secret mismatch:\ngot  %x\nwant %x", got, want)
+	}
+}
+
+func TestX25519MarshalPKIX(t *testing.T) {
+	priv, _, err := cng.GenerateKeyECDH("X25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := priv.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := pub.MarshalPKIX()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdhPub, ok := parsed.(*ecdh.PublicKey)
+	if !ok {
+		t.Fatalf("parsed %T, want *ecdh.PublicKey", parsed)
+	}
+	if ecdhPub.Curve() != ecdh.X25519() {
+		t.Errorf("unexpected curve %v", ecdhPub.Curve())
+	}
+	if !bytes.Equal(ecdhPub.Bytes(), pub.Bytes()) {
+		t.Errorf("key = %x, want %x", ecdhPub.Bytes(), pub.Bytes())
+	}
+}
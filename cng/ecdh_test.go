@@ -8,7 +8,15 @@ package cng_test
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/hex"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/microsoft/go-crypto-winnative/cng"
@@ -151,6 +159,64 @@ func TestVectors(t *testing.T) {
 	}
 }
 
+// TestECDHLeadingZeroSecret checks that ECDH returns the x-coordinate
+// in big-endian form and padded to the field size, as crypto/ecdh does.
+// The peer keys were picked so that the secret starts with a zero byte,
+// which would be lost or moved by a byte order mistake. The expected
+// values were computed with crypto/ecdh.
+func TestECDHLeadingZeroSecret(t *testing.T) {
+	for _, tt := range []struct {
+		name, priv, peer, secret string
+	}{
+		{
+			"P-256",
+			"8815c8065fb5c33ff6719836f2f541b1b0b3548baaee42cd40b3ca2c2fcfb7ad",
+			"041ce53812e1355a62292398eeb1b17e7bc018aafafb2493c82f6667c6a1149fff" +
+				"05c5854f380024f3d83e3dba444ca9284382a6fe5a1c580e7e4bd807047a1fbf",
+			"00ef8fb768d0a4994b738e933b131ee1c4d934cb97c4ee4342d2b3319103a5fe",
+		},
+		{
+			"P-384",
+			"efd9b5d829f623baec3e70db97c6d9d93b1507c60e1a40d7fa7d6fe69613272fefd9b5d829f623baec3e70db97c6d9d9",
+			"049b2d47d562c81afaedebfa64cea67388ed804efb782b45a8c8dc0273dbeb4da8bec184208a92365c61df4e91460948ce" +
+				"48f8cf047c065a21d7185985f8b89b48dadc81fe6df12ff3887e176e4f0718d04fe4fc992f787838cc3a7caa52f89427",
+			"00f6e12b0fafdd77fcb946cf177f7872df682f367f1438db7ce2874d8563d2a83d59ea4a75fd9ba5c3031fb5d4d1de46",
+		},
+		{
+			"X25519",
+			"6140cbe81990588a380d1d5b4e8186c0d19d8e9547b715de5a4c27dc2ef7a8d0",
+			"3be186fafde46f73d10c50009309f4d256c3f32ac880a55a8d3164263d55fb01",
+			"00beccf68b2d48e9a3b7bb7dc87df335352741e8fbbf06f51be8a15cde09c81e",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := cng.NewPrivateKeyECDH(tt.name, hexDecode(t, tt.priv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			peer, err := cng.NewPublicKeyECDH(tt.name, hexDecode(t, tt.peer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := hexDecode(t, tt.secret)
+			secret, err := cng.ECDH(key, peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(secret, want) {
+				t.Errorf("ECDH = %x, want %x", secret, want)
+			}
+			raw, _, err := cng.ECDHWithKDF(key, peer, cng.KDFSpec{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(raw, want) {
+				t.Errorf("ECDHWithKDF raw secret = %x, want %x", raw, want)
+			}
+		})
+	}
+}
+
 func hexDecode(t *testing.T, s string) []byte {
 	b, err := hex.DecodeString(s)
 	if err != nil {
@@ -158,3 +224,273 @@ func hexDecode(t *testing.T, s string) []byte {
 	}
 	return b
 }
+
+func TestECDHWithKDF(t *testing.T) {
+	aliceKey, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobKey, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPubKey, err := bobKey.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := cng.ECDH(aliceKey, bobPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, derived, err := cng.ECDHWithKDF(aliceKey, bobPubKey, cng.KDFSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Errorf("raw secret = %x, want %x", raw, want)
+	}
+	if derived != nil {
+		t.Errorf("derived = %x, want nil", derived)
+	}
+
+	raw, derived, err = cng.ECDHWithKDF(aliceKey, bobPubKey, cng.KDFSpec{Name: cng.KDFHash, Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Errorf("raw secret = %x, want %x", raw, want)
+	}
+	if sum := sha256.Sum256(raw); !bytes.Equal(derived, sum[:]) {
+		t.Errorf("KDFHash = %x, want %x", derived, sum)
+	}
+
+	prepend, appendData := []byte("prepend"), []byte("append")
+	_, derived, err = cng.ECDHWithKDF(aliceKey, bobPubKey, cng.KDFSpec{
+		Name: cng.KDFHMAC, Hash: crypto.SHA256, HMACKey: []byte("key"), Prepend: prepend, Append: appendData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write(prepend)
+	mac.Write(raw)
+	mac.Write(appendData)
+	if sum := mac.Sum(nil); !bytes.Equal(derived, sum) {
+		t.Errorf("KDFHMAC = %x, want %x", derived, sum)
+	}
+
+	if _, _, err := cng.ECDHWithKDF(aliceKey, bobPubKey, cng.KDFSpec{Name: "UNKNOWN"}); err == nil {
+		t.Error("expected error for unknown KDF")
+	}
+}
+
+func TestECDHCofactor(t *testing.T) {
+	alice, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPub, err := bob.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := cng.ECDH(alice, bobPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cng.ECDHWithOptions(alice, bobPub, cng.ECDHOptions{Cofactor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("cofactor ECDH = %x, want %x", got, want)
+	}
+
+	// u = 0 is a small-order X25519 point, which gives an all-zero secret.
+	x, _, err := cng.GenerateKeyECDH("X25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeroPub, err := cng.NewPublicKeyECDH("X25519", make([]byte, 32))
+	if err != nil {
+		// Rejected on import, which is fine too.
+		return
+	}
+	if _, err := cng.ECDHWithOptions(x, zeroPub, cng.ECDHOptions{Cofactor: true}); err == nil {
+		t.Error("expected error for small-order X25519 public key")
+	}
+}
+
+func TestECDHImportLength(t *testing.T) {
+	for _, tt := range []struct {
+		curve           string
+		pubLen, privLen int
+	}{
+		{"P-256", 65, 32},
+		{"P-384", 97, 48},
+		{"P-521", 133, 66},
+		{"X25519", 32, 32},
+	} {
+		t.Run(tt.curve, func(t *testing.T) {
+			for _, n := range []int{0, tt.pubLen - 1, tt.pubLen + 1} {
+				pub := make([]byte, n)
+				if n > 0 {
+					pub[0] = 4
+				}
+				_, err := cng.NewPublicKeyECDH(tt.curve, pub)
+				if err == nil {
+					t.Errorf("expected error for %d-byte public key", n)
+				} else if want := fmt.Sprintf("want %d", tt.pubLen); !strings.Contains(err.Error(), want) {
+					t.Errorf("public key error %q does not mention %q", err, want)
+				}
+			}
+			for _, n := range []int{0, tt.privLen - 1, tt.privLen + 1} {
+				_, err := cng.NewPrivateKeyECDH(tt.curve, make([]byte, n))
+				if err == nil {
+					t.Errorf("expected error for %d-byte private key", n)
+				} else if want := fmt.Sprintf("want %d", tt.privLen); !strings.Contains(err.Error(), want) {
+					t.Errorf("private key error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSecretAgreement(t *testing.T) {
+	alice, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPub, err := bob.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdf := cng.KDFSpec{Name: cng.KDFHash, Hash: crypto.SHA256, Prepend: []byte("prepend")}
+	wantRaw, wantDerived, err := cng.ECDHWithKDF(alice, bobPub, kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := cng.SecretAgreement(alice, bobPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := s.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, wantRaw) {
+		t.Errorf("Raw() = %x, want %x", raw, wantRaw)
+	}
+	// The same secret can feed several derivations.
+	for i := 0; i < 2; i++ {
+		derived, err := s.DeriveKey(kdf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(derived, wantDerived) {
+			t.Errorf("DeriveKey() = %x, want %x", derived, wantDerived)
+		}
+	}
+	if _, err := s.DeriveKey(cng.KDFSpec{}); err == nil {
+		t.Error("expected error for missing KDF")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeriveKey(kdf); err != cng.ErrClosed {
+		t.Errorf("DeriveKey after Close returned %v, want ErrClosed", err)
+	}
+}
+
+func BenchmarkECDHDerive(b *testing.B) {
+	priv, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		b.Fatal(err)
+	}
+	peer, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		b.Fatal(err)
+	}
+	pub, err := peer.PublicKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	kdf := cng.KDFSpec{Name: cng.KDFHash, Hash: crypto.SHA256}
+	b.Run("combined", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := cng.ECDHWithKDF(priv, pub, kdf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("split", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s, err := cng.SecretAgreement(priv, pub)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := s.DeriveKey(kdf); err != nil {
+				b.Fatal(err)
+			}
+			s.Close()
+		}
+	})
+}
+
+func TestPublicKeyECDHMarshalPKIX(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		curve elliptic.Curve
+	}{
+		{"P-256", elliptic.P256()},
+		{"P-384", elliptic.P384()},
+		{"P-521", elliptic.P521()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			priv, _, err := cng.GenerateKeyECDH(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := priv.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			der, err := pub.MarshalPKIX()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ecPub, ok := parsed.(*ecdsa.PublicKey)
+			if !ok {
+				t.Fatalf("parsed %T, want *ecdsa.PublicKey", parsed)
+			}
+			if ecPub.Curve != tt.curve {
+				t.Errorf("curve = %s, want %s", ecPub.Curve.Params().Name, tt.name)
+			}
+			if got := elliptic.Marshal(ecPub.Curve, ecPub.X, ecPub.Y); !bytes.Equal(got, pub.Bytes()) {
+				t.Errorf("point = %x, want %x", got, pub.Bytes())
+			}
+			want, err := x509.MarshalPKIXPublicKey(ecPub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(der, want) {
+				t.Errorf("MarshalPKIX() = %x, want %x", der, want)
+			}
+		})
+	}
+}
@@ -56,17 +56,14 @@ func GenerateKeyECDSA(curve string) (X, Y, D BigInt, err error) {
 	if err != nil {
 		return
 	}
-	var hkey bcrypt.KEY_HANDLE
-	err = bcrypt.GenerateKeyPair(h.handle, &hkey, bits, 0)
-	if err != nil {
-		return
+	if hook := auditHook(); hook != nil {
+		hook(AuditEvent{Op: AuditGenerateKey, Algorithm: "ECDSA", KeyBits: int(bits)})
 	}
-	defer bcrypt.DestroyKey(hkey)
-	// The key cannot be used until BCryptFinalizeKeyPair has been called.
-	err = bcrypt.FinalizeKeyPair(hkey, 0)
+	hkey, err := generateKeyPair(h.handle, bits)
 	if err != nil {
 		return
 	}
+	defer bcrypt.DestroyKey(hkey)
 	hdr, data, err := exportECCKey(hkey, true)
 	if err != nil {
 		return
@@ -104,6 +101,33 @@ func (k *PublicKeyECDSA) finalize() {
 	bcrypt.DestroyKey(k.hkey)
 }
 
+// Close destroys the key handle. It returns ErrClosed if k is already closed.
+// Close must not run concurrently with any other use of k, including
+// another Close: all the goroutines sharing k must be done with it first.
+func (k *PublicKeyECDSA) Close() error {
+	if k.hkey == 0 {
+		return ErrClosed
+	}
+	runtime.SetFinalizer(k, nil)
+	bcrypt.DestroyKey(k.hkey)
+	k.hkey = 0
+	return nil
+}
+
+// Export returns the coordinates of k, each of them as long as
+// the curve coordinate size, as GenerateKeyECDSA does.
+func (k *PublicKeyECDSA) Export() (X, Y BigInt, err error) {
+	defer runtime.KeepAlive(k)
+	hdr, data, err := exportECCKey(k.hkey, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) < 2*int(hdr.KeySize) {
+		return nil, nil, errors.New("crypto/ecdsa: exported key is corrupted")
+	}
+	return data[:hdr.KeySize], data[hdr.KeySize : 2*hdr.KeySize], nil
+}
+
 type PrivateKeyECDSA struct {
 	hkey bcrypt.KEY_HANDLE
 }
@@ -126,6 +150,37 @@ func (k *PrivateKeyECDSA) finalize() {
 	bcrypt.DestroyKey(k.hkey)
 }
 
+// Close destroys the key handle, and with it the private key material,
+// which is only held by CNG. It returns ErrClosed if k is already closed.
+// Close must not run concurrently with any other use of k, including
+// another Close: all the goroutines sharing k must be done with it first.
+func (k *PrivateKeyECDSA) Close() error {
+	if k.hkey == 0 {
+		return ErrClosed
+	}
+	runtime.SetFinalizer(k, nil)
+	bcrypt.DestroyKey(k.hkey)
+	k.hkey = 0
+	return nil
+}
+
+// Export returns the public coordinates and the private scalar of k,
+// each of them as long as the curve coordinate size, as GenerateKeyECDSA
+// does. D is sensitive and should be wiped, for example with Wipe,
+// when no longer needed.
+func (k *PrivateKeyECDSA) Export() (X, Y, D BigInt, err error) {
+	defer runtime.KeepAlive(k)
+	hdr, data, err := exportECCKey(k.hkey, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(data) < 3*int(hdr.KeySize) {
+		Wipe(data)
+		return nil, nil, nil, errors.New("crypto/ecdsa: exported key is corrupted")
+	}
+	return data[:hdr.KeySize], data[hdr.KeySize : 2*hdr.KeySize], data[2*hdr.KeySize : 3*hdr.KeySize], nil
+}
+
 // SignECDSA signs a hash (which should be the result of hashing a larger message),
 // using the private key, priv.
 //
@@ -135,6 +190,9 @@ func (k *PrivateKeyECDSA) finalize() {
 // only to be decoded into raw big.Int by the caller.
 func SignECDSA(priv *PrivateKeyECDSA, hash []byte) (r, s BigInt, err error) {
 	defer runtime.KeepAlive(priv)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditSign, Algorithm: "ECDSA", KeyBits: keyBits(priv.hkey)}, perfCounter())
+	}
 	sig, err := keySign(priv.hkey, nil, hash, bcrypt.PAD_UNDEFINED)
 	if err != nil {
 		return nil, nil, err
@@ -149,12 +207,28 @@ func SignECDSA(priv *PrivateKeyECDSA, hash []byte) (r, s BigInt, err error) {
 
 // VerifyECDSA verifies the signature in r, s of hash using the public key, pub.
 func VerifyECDSA(pub *PublicKeyECDSA, hash []byte, r, s BigInt) bool {
+	size, err := pub.size()
+	if err != nil {
+		return false
+	}
+	return verifyECDSA(pub, size, hash, r, s)
+}
+
+// size returns the size in bytes of each of the signature components.
+func (pub *PublicKeyECDSA) size() (int, error) {
 	defer runtime.KeepAlive(pub)
 	sizeBits, err := getUint32(bcrypt.HANDLE(pub.hkey), bcrypt.KEY_LENGTH)
 	if err != nil {
-		return false
+		return 0, err
+	}
+	return int(sizeBits+7) / 8, nil
+}
+
+func verifyECDSA(pub *PublicKeyECDSA, size int, hash []byte, r, s BigInt) bool {
+	defer runtime.KeepAlive(pub)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditVerify, Algorithm: "ECDSA", KeyBits: keyBits(pub.hkey)}, perfCounter())
 	}
-	size := int(sizeBits+7) / 8
 	// r and s might be shorter than size
 	// if the original big number contained leading zeros,
 	// but they must not be longer than the public key size.
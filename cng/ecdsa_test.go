@@ -7,9 +7,17 @@
 package cng_test
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"errors"
+	"math/big"
+	"runtime"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/microsoft/go-crypto-winnative/cng"
 	"github.com/microsoft/go-crypto-winnative/cng/bbig"
@@ -87,6 +95,78 @@ func generateKeycurve(c elliptic.Curve) (*ecdsa.PrivateKey, error) {
 	return &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: c, X: bbig.Dec(x), Y: bbig.Dec(y)}, D: bbig.Dec(d)}, nil
 }
 
+func TestRecoverPublicKeyECDSA(t *testing.T) {
+	// Signature over SHA-256("sample") with the RFC 6979 A.2.5 key,
+	// computed independently with crypto/ecdsa.
+	hash := hexDecode(t, "af2bdbe1aa9b6ec1e2ade1d694f41fc71a831d0268e9891562113d8a62add1bf")
+	sig := hexDecode(t, "efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716"+
+		"f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8")
+	want, err := cng.NewPublicKeyECDSA("P-256",
+		hexDecode(t, "60fed4ba255a9d31c961eb74c6356d68c049b8923b61fa6ce669622e60f29fb6"),
+		hexDecode(t, "7903fe1008b8bc99a41ae9e95628bc64f2f1b20c2d7e9f5177a3c294d4462299"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bbig.RecoverPublicKeyECDSA("P-256", hash, sig, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.PublicKeyEqual(got, want) {
+		t.Error("recovered the wrong public key")
+	}
+	if got, err := bbig.RecoverPublicKeyECDSA("P-256", hash, sig, 1); err == nil && cng.PublicKeyEqual(got, want) {
+		t.Error("recovered the signer's key with the wrong recovery ID")
+	}
+	if _, err := bbig.RecoverPublicKeyECDSA("P-256", hash, sig, 4); err == nil {
+		t.Error("expected error for out of range recovery ID")
+	}
+	if _, err := bbig.RecoverPublicKeyECDSA("P-256", hash, sig[1:], 0); err == nil {
+		t.Error("expected error for short signature")
+	}
+	if _, err := bbig.RecoverPublicKeyECDSA("P-192", hash, sig, 0); err == nil {
+		t.Error("expected error for unknown curve")
+	}
+}
+
+func TestRecoverPublicKeyECDSASigned(t *testing.T) {
+	testAllCurves(t, testRecoverPublicKeyECDSASigned)
+}
+
+func testRecoverPublicKeyECDSASigned(t *testing.T, c elliptic.Curve) {
+	name := c.Params().Name
+	x, y, d, err := cng.GenerateKeyECDSA(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := cng.NewPrivateKeyECDSA(name, x, y, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDSA(name, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := []byte("testing")
+	r, s, err := cng.SignECDSA(priv, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := (c.Params().N.BitLen() + 7) / 8
+	sig := make([]byte, 2*size)
+	bbig.Dec(r).FillBytes(sig[:size])
+	bbig.Dec(s).FillBytes(sig[size:])
+	var found int
+	for id := 0; id < 4; id++ {
+		got, err := bbig.RecoverPublicKeyECDSA(name, hashed, sig, id)
+		if err == nil && cng.PublicKeyEqual(got, pub) {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Errorf("signer's key recovered by %d recovery IDs, want 1", found)
+	}
+}
+
 func BenchmarkSignECDSA(b *testing.B) {
 	name := "P-256"
 	x, y, d, err := cng.GenerateKeyECDSA(name)
@@ -147,3 +227,229 @@ func BenchmarkGenerateKeyECDSA(b *testing.B) {
 		}
 	}
 }
+
+func TestECDSAKeyClose(t *testing.T) {
+	x, y, d, err := cng.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := cng.NewPrivateKeyECDSA("P-256", x, y, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDSA("P-256", x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := make([]byte, 32)
+	r, s, err := cng.SignECDSA(priv, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.Close(); err != cng.ErrClosed {
+		t.Errorf("second Close = %v, want ErrClosed", err)
+	}
+	if _, _, err := cng.SignECDSA(priv, hash); err != cng.ErrClosed {
+		t.Errorf("SignECDSA after Close = %v, want ErrClosed", err)
+	}
+	if !cng.VerifyECDSA(pub, hash, r, s) {
+		t.Error("Verify failed after closing the private key")
+	}
+	if err := pub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if cng.VerifyECDSA(pub, hash, r, s) {
+		t.Error("VerifyECDSA succeeded after Close")
+	}
+}
+
+// TestECDSAKeyCloseAfterConcurrentUse exercises the Close contract: k may
+// be shared by goroutines, and closed once they are all done with it.
+// Run with -race to check that this usage is free of data races.
+func TestECDSAKeyCloseAfterConcurrentUse(t *testing.T) {
+	x, y, d, err := cng.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := cng.NewPrivateKeyECDSA("P-256", x, y, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDSA("P-256", x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := make([]byte, 32)
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, s, err := cng.SignECDSA(priv, hash)
+			if err == nil && !cng.VerifyECDSA(pub, hash, r, s) {
+				err = errors.New("VerifyECDSA failed")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if err := priv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var procGetProcessHandleCount = syscall.NewLazyDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+
+func processHandleCount(t *testing.T) uint32 {
+	t.Helper()
+	var n uint32
+	p, _ := syscall.GetCurrentProcess()
+	if r, _, err := procGetProcessHandleCount.Call(uintptr(p), uintptr(unsafe.Pointer(&n))); r == 0 {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSigningKeysNoHandleGrowth(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	x, y, d, err := cng.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	N, E, D, P, Q, Dp, Dq, Qinv, err := cng.GenerateKeyRSA(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := make([]byte, 32)
+	newKeys := func(close bool) {
+		for i := 0; i < 500; i++ {
+			ec, err := cng.NewPrivateKeyECDSA("P-256", x, y, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := cng.SignECDSA(ec, hash); err != nil {
+				t.Fatal(err)
+			}
+			rsa, err := cng.NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if close {
+				ec.Close()
+				rsa.Close()
+			}
+		}
+	}
+	// Warm up the algorithm providers, which are cached for the process.
+	newKeys(true)
+	runtime.GC()
+	before := processHandleCount(t)
+	newKeys(true)
+	newKeys(false)
+	// Finalizers run in the background after a GC cycle.
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := processHandleCount(t); after > before+10 {
+		t.Errorf("process handle count grew from %d to %d", before, after)
+	}
+}
+
+// TestECDSALeadingZeros imports a P-256 key whose D and X both start
+// with two zero bytes, encoded with and without the leading zeros, and
+// checks its signatures with crypto/ecdsa.
+func TestECDSALeadingZeros(t *testing.T) {
+	d := hexDecode(t, "00002b8f2f46270e4682b482a10e64ef9d6aa1936c747194f14f601c58558b8d")
+	x := hexDecode(t, "00005f395b69903ff87e0ccd09a2c0800ca45e3435d025ad7bd29f6d127c0cf8")
+	y := hexDecode(t, "b72fe5f5124e6daab01dd8472ada81788bfe9d0979d94fcd9e39a1fdda2b6383")
+	stdPub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	hash := make([]byte, 32)
+
+	fixed := func(b []byte) cng.BigInt {
+		v, err := bbig.EncFixed(new(big.Int).SetBytes(b), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(v) != 32 || !bytes.Equal(v, b) {
+			t.Fatalf("EncFixed = %x, want %x", v, b)
+		}
+		return v
+	}
+	wide := func(b []byte) cng.BigInt { return append(cng.BigInt{0, 0}, b...) }
+	for _, tt := range []struct {
+		name    string
+		X, Y, D cng.BigInt
+	}{
+		{"Normalized", bbig.Enc(stdPub.X), bbig.Enc(stdPub.Y), bbig.Enc(new(big.Int).SetBytes(d))},
+		{"Fixed", fixed(x), fixed(y), fixed(d)},
+		{"Wide", wide(x), wide(y), wide(d)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			priv, err := cng.NewPrivateKeyECDSA("P-256", tt.X, tt.Y, tt.D)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, s, err := cng.SignECDSA(priv, hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ecdsa.Verify(stdPub, hash, bbig.Dec(r), bbig.Dec(s)) {
+				t.Error("crypto/ecdsa rejected the signature")
+			}
+			pub, err := cng.NewPublicKeyECDSA("P-256", tt.X, tt.Y)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cng.VerifyECDSA(pub, hash, r, s) {
+				t.Error("VerifyECDSA rejected the signature")
+			}
+		})
+	}
+
+	// A value that really is wider than the field is still rejected.
+	if _, err := cng.NewPublicKeyECDSA("P-256", append(cng.BigInt{1}, x...), y); err == nil {
+		t.Error("NewPublicKeyECDSA accepted an X coordinate wider than the field")
+	}
+}
+
+func TestEncFixed(t *testing.T) {
+	for _, size := range []int{32, 48, 66} {
+		v, err := bbig.EncFixed(big.NewInt(0x0102), size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]byte, size)
+		want[size-2], want[size-1] = 1, 2
+		if !bytes.Equal(v, want) {
+			t.Errorf("EncFixed(0x0102, %d) = %x, want %x", size, v, want)
+		}
+		if got := bbig.Dec(v); got.Int64() != 0x0102 {
+			t.Errorf("Dec(EncFixed(0x0102, %d)) = %v", size, got)
+		}
+		if v, err := bbig.EncFixed(new(big.Int), size); err != nil || !bytes.Equal(v, make([]byte, size)) {
+			t.Errorf("EncFixed(0, %d) = %x, %v", size, v, err)
+		}
+		if _, err := bbig.EncFixed(new(big.Int).Lsh(big.NewInt(1), uint(size*8)), size); err == nil {
+			t.Errorf("EncFixed(2^%d, %d) succeeded", size*8, size)
+		}
+	}
+	if _, err := bbig.EncFixed(big.NewInt(-1), 32); err == nil {
+		t.Error("EncFixed(-1) succeeded")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// VerifyRequest is a single ECDSA verification for VerifyBatch.
+type VerifyRequest struct {
+	Pub  *PublicKeyECDSA
+	Hash []byte
+	R, S BigInt
+}
+
+// VerifyBatch verifies each request and reports, in order, whether its
+// signature is valid. There is no batch verification math involved:
+// the requests are verified independently, in parallel across up to
+// GOMAXPROCS goroutines, and the key size of each distinct public key
+// is only queried once.
+//
+// An error is returned, and nothing is verified, if a request has no
+// public key or a public key can't be queried.
+func VerifyBatch(reqs []VerifyRequest) ([]bool, error) {
+	sizes := make(map[*PublicKeyECDSA]int)
+	for _, r := range reqs {
+		if r.Pub == nil {
+			return nil, errors.New("cng: missing ECDSA public key")
+		}
+		if _, ok := sizes[r.Pub]; ok {
+			continue
+		}
+		size, err := r.Pub.size()
+		if err != nil {
+			return nil, err
+		}
+		sizes[r.Pub] = size
+	}
+
+	results := make([]bool, len(reqs))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(reqs) {
+					return
+				}
+				r := &reqs[i]
+				results[i] = verifyECDSA(r.Pub, sizes[r.Pub], r.Hash, r.R, r.S)
+			}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
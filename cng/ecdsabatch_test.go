@@ -0,0 +1,92 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func newBatchRequests(tb testing.TB, n int) []cng.VerifyRequest {
+	reqs := make([]cng.VerifyRequest, n)
+	for i := range reqs {
+		// Use a couple of distinct keys so that key handles are shared.
+		name := "P-256"
+		if i%2 == 1 {
+			name = "P-384"
+		}
+		x, y, d, err := cng.GenerateKeyECDSA(name)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		priv, err := cng.NewPrivateKeyECDSA(name, x, y, d)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		pub, err := cng.NewPublicKeyECDSA(name, x, y)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		hashed := cng.SHA256([]byte{byte(i)})
+		r, s, err := cng.SignECDSA(priv, hashed[:])
+		if err != nil {
+			tb.Fatal(err)
+		}
+		reqs[i] = cng.VerifyRequest{Pub: pub, Hash: hashed[:], R: r, S: s}
+	}
+	return reqs
+}
+
+func TestVerifyBatch(t *testing.T) {
+	reqs := newBatchRequests(t, 16)
+	want := make([]bool, len(reqs))
+	for i := range reqs {
+		want[i] = true
+		switch i % 4 {
+		case 1:
+			// Tamper with the hash.
+			reqs[i].Hash = append([]byte{^reqs[i].Hash[0]}, reqs[i].Hash[1:]...)
+			want[i] = false
+		case 2:
+			// Verify with another request's key.
+			reqs[i].Pub = reqs[(i+2)%len(reqs)].Pub
+			want[i] = false
+		}
+	}
+	got, err := cng.VerifyBatch(reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d: got %v, want %v", i, got[i], want[i])
+		}
+		if got[i] != cng.VerifyECDSA(reqs[i].Pub, reqs[i].Hash, reqs[i].R, reqs[i].S) {
+			t.Errorf("request %d: VerifyBatch disagrees with VerifyECDSA", i)
+		}
+	}
+
+	if got, err := cng.VerifyBatch(nil); err != nil || len(got) != 0 {
+		t.Errorf("VerifyBatch(nil) = %v, %v", got, err)
+	}
+	reqs[3].Pub = nil
+	if _, err := cng.VerifyBatch(reqs); err == nil {
+		t.Error("expected error for missing public key")
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	reqs := newBatchRequests(b, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cng.VerifyBatch(reqs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
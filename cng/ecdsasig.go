@@ -0,0 +1,158 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+)
+
+var errMalformedECDSASig = errors.New("cng: malformed ECDSA signature")
+
+// ecdsaOrderSize returns the size in bytes of the order of curve.
+func ecdsaOrderSize(curve string) (int, error) {
+	switch curve {
+	case "P-224":
+		return 28, nil
+	case "P-256":
+		return 32, nil
+	case "P-384":
+		return 48, nil
+	case "P-521":
+		return 66, nil
+	}
+	return 0, errUnknownCurve
+}
+
+// ECDSASigToASN1 converts the raw fixed-width r || s ECDSA signature,
+// as defined in IEEE P1363, to the ASN.1 DER encoding used by crypto/ecdsa.
+func ECDSASigToASN1(curve string, raw []byte) ([]byte, error) {
+	size, err := ecdsaOrderSize(curve)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 2*size {
+		return nil, errors.New("cng: invalid raw ECDSA signature length")
+	}
+	r := encodeDERInteger(raw[:size])
+	s := encodeDERInteger(raw[size:])
+	out := appendDERLength([]byte{0x30}, len(r)+len(s))
+	out = append(out, r...)
+	return append(out, s...), nil
+}
+
+// ECDSASigFromASN1 converts the ASN.1 DER encoded ECDSA signature der
+// to the raw fixed-width r || s encoding, as defined in IEEE P1363.
+// Non-canonical encodings and components larger than the curve order
+// size are rejected.
+func ECDSASigFromASN1(curve string, der []byte) ([]byte, error) {
+	size, err := ecdsaOrderSize(curve)
+	if err != nil {
+		return nil, err
+	}
+	seq, rest, ok := parseDERElement(der, 0x30)
+	if !ok || len(rest) != 0 {
+		return nil, errMalformedECDSASig
+	}
+	r, seq, ok := parseDERElement(seq, 0x02)
+	if !ok {
+		return nil, errMalformedECDSASig
+	}
+	s, seq, ok := parseDERElement(seq, 0x02)
+	if !ok || len(seq) != 0 {
+		return nil, errMalformedECDSASig
+	}
+	out := make([]byte, 2*size)
+	if !decodeDERInteger(out[:size], r) || !decodeDERInteger(out[size:], s) {
+		return nil, errMalformedECDSASig
+	}
+	return out, nil
+}
+
+// encodeDERInteger returns the DER encoding of the unsigned
+// big-endian integer b, including the tag and length.
+func encodeDERInteger(b []byte) []byte {
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	var pad int
+	if b[0]&0x80 != 0 {
+		// Prepend a zero byte so the integer is not negative.
+		pad = 1
+	}
+	out := appendDERLength([]byte{0x02}, len(b)+pad)
+	if pad != 0 {
+		out = append(out, 0)
+	}
+	return append(out, b...)
+}
+
+// decodeDERInteger writes the contents of the positive DER integer b
+// into out, left-padded with zeros. It reports false if b is not
+// a minimally-encoded positive integer that fits in out.
+func decodeDERInteger(out, b []byte) bool {
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		// Empty or negative.
+		return false
+	}
+	if len(b) > 1 && b[0] == 0 && b[1]&0x80 == 0 {
+		// Not minimally encoded.
+		return false
+	}
+	if b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) == 0 || len(b) > len(out) {
+		// Zero or too large.
+		return false
+	}
+	copy(out[len(out)-len(b):], b)
+	return true
+}
+
+func appendDERLength(b []byte, n int) []byte {
+	switch {
+	case n < 0x80:
+		return append(b, byte(n))
+	case n <= 0xff:
+		return append(b, 0x81, byte(n))
+	default:
+		return append(b, 0x82, byte(n>>8), byte(n))
+	}
+}
+
+// parseDERElement parses a DER element with the given tag from the start of b,
+// returning its contents and the remaining bytes.
+// Only the length encodings of up to 2 bytes are supported,
+// which is enough for any ECDSA signature.
+func parseDERElement(b []byte, tag byte) (contents, rest []byte, ok bool) {
+	if len(b) < 2 || b[0] != tag {
+		return nil, nil, false
+	}
+	n, b := int(b[1]), b[2:]
+	switch {
+	case n < 0x80:
+	case n == 0x81:
+		if len(b) < 1 || b[0] < 0x80 {
+			return nil, nil, false
+		}
+		n, b = int(b[0]), b[1:]
+	case n == 0x82:
+		if len(b) < 2 || b[0] == 0 {
+			return nil, nil, false
+		}
+		n, b = int(b[0])<<8|int(b[1]), b[2:]
+	default:
+		return nil, nil, false
+	}
+	if n > len(b) {
+		return nil, nil, false
+	}
+	return b[:n], b[n:], true
+}
@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestECDSASigASN1(t *testing.T) {
+	testAllCurves(t, testECDSASigASN1)
+}
+
+func testECDSASigASN1(t *testing.T, c elliptic.Curve) {
+	name := c.Params().Name
+	size := (c.Params().N.BitLen() + 7) / 8
+	key, err := ecdsa.GenerateKey(c, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := []byte("testing")
+	for i := 0; i < 10; i++ {
+		der, err := ecdsa.SignASN1(rand.Reader, key, hashed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := cng.ECDSASigFromASN1(name, der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(raw) != 2*size {
+			t.Fatalf("raw signature length = %d, want %d", len(raw), 2*size)
+		}
+		r := new(big.Int).SetBytes(raw[:size])
+		s := new(big.Int).SetBytes(raw[size:])
+		if !ecdsa.Verify(&key.PublicKey, hashed, r, s) {
+			t.Fatal("converted signature failed to verify")
+		}
+		der2, err := cng.ECDSASigToASN1(name, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(der2, der) {
+			t.Fatalf("round trip mismatch:\ngot  %x\nwant %x", der2, der)
+		}
+	}
+}
+
+func TestECDSASigToASN1_SmallComponents(t *testing.T) {
+	raw := make([]byte, 64)
+	raw[31] = 0x01
+	raw[32] = 0x80
+	der, err := cng.ECDSASigToASN1("P-256", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(der, &sig); err != nil || len(rest) != 0 {
+		t.Fatalf("asn1.Unmarshal: %v", err)
+	}
+	if sig.R.Cmp(big.NewInt(1)) != 0 || sig.S.Cmp(new(big.Int).SetBytes(raw[32:])) != 0 {
+		t.Errorf("got r=%v s=%v", sig.R, sig.S)
+	}
+	got, err := cng.ECDSASigFromASN1("P-256", der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, raw) {
+		t.Errorf("got %x, want %x", got, raw)
+	}
+}
+
+func TestECDSASigASN1_Invalid(t *testing.T) {
+	if _, err := cng.ECDSASigToASN1("P-256", make([]byte, 63)); err == nil {
+		t.Error("ECDSASigToASN1 accepted a short signature")
+	}
+	if _, err := cng.ECDSASigToASN1("P-192", make([]byte, 48)); err == nil {
+		t.Error("ECDSASigToASN1 accepted an unknown curve")
+	}
+	tests := []struct {
+		name string
+		der  string
+	}{
+		{"empty", ""},
+		{"not a sequence", "3106020101020101"},
+		{"trailing data", "300602010102010100"},
+		{"extra component", "3009020101020101020101"},
+		{"missing component", "3003020101"},
+		{"truncated", "3006020101020201"},
+		{"negative", "30060201ff020101"},
+		{"zero", "3006020100020101"},
+		{"empty integer", "30050200020101"},
+		{"non-minimal integer", "300702020001020101"},
+		{"non-minimal length", "308106020101020101"},
+		{"too large", "3027022201000000000000000000000000000000000000000000000000000000000000000001020101"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, _ := hex.DecodeString(tt.der)
+			if _, err := cng.ECDSASigFromASN1("P-256", der); err == nil {
+				t.Errorf("ECDSASigFromASN1(%s) succeeded, want error", tt.der)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// ConditionEntropy conditions raw entropy using the Hash_df derivation function
+// defined in NIST SP 800-90A Section 10.3.1 instantiated with SHA-256,
+// which is one of the vetted conditioning components allowed by NIST SP 800-90B.
+// It returns outLen bytes, which must be between 1 and 255*32.
+//
+// Conditioning doesn't increase the entropy of raw, the caller is responsible
+// for providing enough entropy for the intended use of the output.
+func ConditionEntropy(raw []byte, outLen int) ([]byte, error) {
+	const hashLen = 32 // SHA-256 output size
+	if outLen <= 0 || outLen > 255*hashLen {
+		return nil, errors.New("cng: invalid conditioned entropy length")
+	}
+	var prefix [5]byte
+	// no_of_bits_to_return is encoded as a 32-bit big-endian integer.
+	binary.BigEndian.PutUint32(prefix[1:], uint32(outLen*8))
+	out := make([]byte, 0, (outLen+hashLen-1)/hashLen*hashLen)
+	h := newHashX(bcrypt.SHA256_ALGORITHM, bcrypt.ALG_NONE_FLAG, nil)
+	for counter := 1; len(out) < outLen; counter++ {
+		prefix[0] = byte(counter)
+		h.Reset()
+		h.Write(prefix[:])
+		h.Write(raw)
+		out = h.Sum(out)
+	}
+	return out[:outLen], nil
+}
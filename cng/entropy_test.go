@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// hashDF is a reference implementation of SP 800-90A Hash_df using SHA-256.
+func hashDF(input []byte, outLen int) []byte {
+	var out []byte
+	for counter := byte(1); len(out) < outLen; counter++ {
+		h := sha256.New()
+		var prefix [5]byte
+		prefix[0] = counter
+		binary.BigEndian.PutUint32(prefix[1:], uint32(outLen*8))
+		h.Write(prefix[:])
+		h.Write(input)
+		out = h.Sum(out)
+	}
+	return out[:outLen]
+}
+
+func TestConditionEntropy(t *testing.T) {
+	raw := []byte("raw noise source samples")
+	for _, outLen := range []int{1, 16, 32, 33, 64, 100, 255 * 32} {
+		got, err := cng.ConditionEntropy(raw, outLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != outLen {
+			t.Errorf("outLen %d: got %d bytes", outLen, len(got))
+		}
+		again, err := cng.ConditionEntropy(raw, outLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, again) {
+			t.Errorf("outLen %d: output is not deterministic", outLen)
+		}
+		if want := hashDF(raw, outLen); !bytes.Equal(got, want) {
+			t.Errorf("outLen %d: got:%x want:%x", outLen, got, want)
+		}
+	}
+}
+
+func TestConditionEntropyInvalidLength(t *testing.T) {
+	for _, outLen := range []int{-1, 0, 255*32 + 1} {
+		if _, err := cng.ConditionEntropy([]byte("raw"), outLen); err == nil {
+			t.Errorf("outLen %d: expected error", outLen)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// EncryptThenMAC encrypts plaintext with AES-CBC under encKey and a fresh
+// random IV, then authenticates the result and additionalData with HMAC
+// under macKey, using the hash identified by hashID, such as "SHA256".
+// It appends IV || ciphertext || tag to dst. The IV is read from the
+// source set by SetRandReader.
+//
+// The plaintext is padded as in PKCS #7, and the tag is
+//
+//	HMAC(macKey, additionalData || IV || ciphertext || AL)
+//
+// where AL is the bit length of additionalData as a 64-bit big-endian
+// integer, the composition of draft-mcgrew-aead-aes-cbc-hmac-sha2 with an
+// untruncated tag. encKey and macKey must be independent keys.
+//
+// Prefer an AEAD such as GCM for new protocols. EncryptThenMAC is meant
+// for formats that require CBC and HMAC. Use VerifyThenDecrypt to open
+// the result.
+func EncryptThenMAC(dst, encKey, macKey, plaintext, additionalData []byte, hashID string) ([]byte, error) {
+	c, h, err := newEtM(encKey, macKey, hashID)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+	padLen := aesBlockSize - len(plaintext)%aesBlockSize
+	padded := make([]byte, len(plaintext)+padLen)
+	copy(padded, plaintext)
+	for i := len(plaintext); i < len(padded); i++ {
+		padded[i] = byte(padLen)
+	}
+	defer Wipe(padded)
+
+	ret, out := sliceForAppend(dst, aesBlockSize+len(padded)+h.Size())
+	iv, ct := out[:aesBlockSize], out[aesBlockSize:aesBlockSize+len(padded)]
+	if _, err := io.ReadFull(randomReader(), iv); err != nil {
+		return nil, err
+	}
+	c.NewCBCEncrypter(iv).CryptBlocks(ct, padded)
+	etmTag(h, out[:aesBlockSize+len(padded)], additionalData, out[aesBlockSize+len(padded):])
+	return ret, nil
+}
+
+// VerifyThenDecrypt opens a message produced by EncryptThenMAC with the
+// same keys, additional data and hash, and appends the plaintext to dst.
+//
+// The tag is checked in constant time before anything is decrypted.
+// A malformed message, a wrong tag and bad padding all return the same
+// error, so a failure reveals nothing about the plaintext.
+func VerifyThenDecrypt(dst, encKey, macKey, sealed, additionalData []byte, hashID string) ([]byte, error) {
+	c, h, err := newEtM(encKey, macKey, hashID)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+	n := h.Size()
+	if len(sealed) < 2*aesBlockSize+n || (len(sealed)-n)%aesBlockSize != 0 {
+		return nil, errOpen
+	}
+	body, tag := sealed[:len(sealed)-n], sealed[len(sealed)-n:]
+	var sum [hmacMaxSize]byte
+	if subtle.ConstantTimeCompare(etmTag(h, body, additionalData, sum[:n]), tag) != 1 {
+		return nil, errOpen
+	}
+
+	iv, ct := body[:aesBlockSize], body[aesBlockSize:]
+	padded := make([]byte, len(ct))
+	defer Wipe(padded)
+	c.NewCBCDecrypter(iv).CryptBlocks(padded, ct)
+	// The tag is valid, so the padding was made by a key holder and
+	// checking it can't be used as an oracle.
+	padLen := int(padded[len(padded)-1])
+	if padLen == 0 || padLen > aesBlockSize {
+		return nil, errOpen
+	}
+	for _, b := range padded[len(padded)-padLen:] {
+		if int(b) != padLen {
+			return nil, errOpen
+		}
+	}
+	return append(dst, padded[:len(padded)-padLen]...), nil
+}
+
+func newEtM(encKey, macKey []byte, hashID string) (*aesCipher, *hashX, error) {
+	b, err := NewAESCipher(encKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	h, err := newHMACByID(hashID, macKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Size() > hmacMaxSize {
+		h.Close()
+		return nil, nil, errors.New("cng: unsupported hash for EncryptThenMAC")
+	}
+	return b.(*aesCipher), h, nil
+}
+
+// etmTag writes the EncryptThenMAC tag of body, which is IV || ciphertext,
+// to out and returns it.
+func etmTag(h *hashX, body, additionalData, out []byte) []byte {
+	var al [8]byte
+	binary.BigEndian.PutUint64(al[:], uint64(len(additionalData))*8)
+	h.Reset()
+	h.Write(additionalData)
+	h.Write(body)
+	h.Write(al[:])
+	return h.Sum(out[:0])
+}
@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestEncryptThenMAC(t *testing.T) {
+	encKey := bytes.Repeat([]byte{1}, 16)
+	macKey := bytes.Repeat([]byte{2}, 32)
+	ad := []byte("header")
+	for _, n := range []int{0, 1, 15, 16, 17, 100} {
+		plaintext := bytes.Repeat([]byte{'p'}, n)
+		sealed, err := cng.EncryptThenMAC([]byte("dst"), encKey, macKey, plaintext, ad, "SHA256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(sealed, []byte("dst")) {
+			t.Fatalf("dst not preserved: %x", sealed)
+		}
+		sealed = sealed[3:]
+
+		// Recompute the message with the standard library from its IV.
+		padLen := 16 - n%16
+		padded := append(append([]byte(nil), plaintext...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+		block, err := aes.NewCipher(encKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iv := sealed[:16]
+		want := append([]byte(nil), iv...)
+		ct := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ct, padded)
+		want = append(want, ct...)
+		mac := hmac.New(sha256.New, macKey)
+		mac.Write(ad)
+		mac.Write(want)
+		var al [8]byte
+		binary.BigEndian.PutUint64(al[:], uint64(len(ad))*8)
+		mac.Write(al[:])
+		want = mac.Sum(want)
+		if !bytes.Equal(sealed, want) {
+			t.Errorf("%d bytes: got %x, want %x", n, sealed, want)
+		}
+
+		got, err := cng.VerifyThenDecrypt([]byte("out"), encKey, macKey, sealed, ad, "SHA256")
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if want := append([]byte("out"), plaintext...); !bytes.Equal(got, want) {
+			t.Errorf("%d bytes: decrypted %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestVerifyThenDecryptTampered(t *testing.T) {
+	encKey := bytes.Repeat([]byte{1}, 32)
+	macKey := bytes.Repeat([]byte{2}, 48)
+	ad := []byte("header")
+	sealed, err := cng.EncryptThenMAC(nil, encKey, macKey, []byte("attack at dawn"), ad, "SHA384")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) []byte {
+		b := append([]byte(nil), sealed...)
+		b[i] ^= 1
+		return b
+	}
+	tests := []struct {
+		name           string
+		sealed, ad     []byte
+		encKey, macKey []byte
+	}{
+		{"IV", flip(0), ad, encKey, macKey},
+		{"Ciphertext", flip(20), ad, encKey, macKey},
+		{"LastCiphertextBlock", flip(len(sealed) - 48 - 1), ad, encKey, macKey},
+		{"MAC", flip(len(sealed) - 1), ad, encKey, macKey},
+		{"AdditionalData", sealed, []byte("Header"), encKey, macKey},
+		{"Truncated", sealed[:len(sealed)-16], ad, encKey, macKey},
+		{"Empty", nil, ad, encKey, macKey},
+		{"EncKey", sealed, ad, bytes.Repeat([]byte{3}, 32), macKey},
+		{"MACKey", sealed, ad, encKey, bytes.Repeat([]byte{3}, 48)},
+	}
+	var first error
+	for _, tt := range tests {
+		got, err := cng.VerifyThenDecrypt(nil, tt.encKey, tt.macKey, tt.sealed, tt.ad, "SHA384")
+		if err == nil {
+			t.Errorf("%s: tampered message opened to %q", tt.name, got)
+			continue
+		}
+		if first == nil {
+			first = err
+		} else if err != first {
+			t.Errorf("%s: error %q differs from %q", tt.name, err, first)
+		}
+	}
+}
+
+func TestEncryptThenMACInvalid(t *testing.T) {
+	if _, err := cng.EncryptThenMAC(nil, make([]byte, 15), make([]byte, 32), nil, nil, "SHA256"); err == nil {
+		t.Error("invalid AES key size accepted")
+	}
+	if _, err := cng.EncryptThenMAC(nil, make([]byte, 16), make([]byte, 32), nil, nil, "NOTAHASH"); err == nil {
+		t.Error("unknown hash accepted")
+	}
+}
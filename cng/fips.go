@@ -0,0 +1,169 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+// Usage is the purpose an algorithm is used for.
+type Usage int
+
+const (
+	UsageHash          Usage = iota + 1 // Message digests not used in signatures.
+	UsageSign                           // Signature generation, including hashing the message.
+	UsageVerify                         // Signature verification, including hashing the message.
+	UsageEncrypt                        // Encryption and key wrapping.
+	UsageDecrypt                        // Decryption and key unwrapping.
+	UsageMAC                            // Message authentication codes.
+	UsageKeyAgreement                   // Key agreement.
+	UsageKeyDerivation                  // Key derivation functions.
+)
+
+func usages(u ...Usage) uint32 {
+	var mask uint32
+	for _, v := range u {
+		mask |= 1 << v
+	}
+	return mask
+}
+
+// fipsApproved maps algorithm identifiers to the usages for which
+// they are approved by FIPS 140-3, following the NIST SP 800-131A Rev. 2
+// transition rules.
+var fipsApproved = map[string]uint32{
+	// SHA-1 can no longer be used to generate digital signatures,
+	// but legacy signatures can still be verified.
+	"SHA1":     usages(UsageHash, UsageVerify, UsageMAC, UsageKeyDerivation),
+	"SHA256":   usages(UsageHash, UsageSign, UsageVerify, UsageMAC, UsageKeyDerivation),
+	"SHA384":   usages(UsageHash, UsageSign, UsageVerify, UsageMAC, UsageKeyDerivation),
+	"SHA512":   usages(UsageHash, UsageSign, UsageVerify, UsageMAC, UsageKeyDerivation),
+	"SHA3-256": usages(UsageHash, UsageSign, UsageVerify, UsageMAC, UsageKeyDerivation),
+	"SHA3-384": usages(UsageHash, UsageSign, UsageVerify, UsageMAC, UsageKeyDerivation),
+	"SHA3-512": usages(UsageHash, UsageSign, UsageVerify, UsageMAC, UsageKeyDerivation),
+
+	"AES": usages(UsageEncrypt, UsageDecrypt, UsageMAC),
+	// Three-key Triple DES encryption was disallowed after 2023,
+	// but legacy data can still be decrypted.
+	"3DES": usages(UsageDecrypt),
+
+	// PKCS #1 v1.5 padding is approved for signatures,
+	// but not for key transport since the end of 2023.
+	"RSA-PKCS1v15": usages(UsageSign, UsageVerify),
+	"RSA-PSS":      usages(UsageSign, UsageVerify),
+	"RSA-OAEP":     usages(UsageEncrypt, UsageDecrypt),
+
+	"P-224": usages(UsageSign, UsageVerify, UsageKeyAgreement),
+	"P-256": usages(UsageSign, UsageVerify, UsageKeyAgreement),
+	"P-384": usages(UsageSign, UsageVerify, UsageKeyAgreement),
+	"P-521": usages(UsageSign, UsageVerify, UsageKeyAgreement),
+
+	"HKDF":       usages(UsageKeyDerivation),
+	"PBKDF2":     usages(UsageKeyDerivation),
+	"TLS1_1_KDF": usages(UsageKeyDerivation),
+	"TLS1_2_KDF": usages(UsageKeyDerivation),
+}
+
+// IsFIPSApproved reports whether the algorithm identified by algID
+// is approved by FIPS 140-3 for the given usage.
+//
+// algID is a CNG algorithm identifier, such as "SHA256", "AES" or "HKDF",
+// an RSA padding scheme, such as "RSA-PSS", "RSA-OAEP" or "RSA-PKCS1v15",
+// or an elliptic curve name, such as "P-256".
+// Algorithms not listed, such as "MD5", "DES", "RC4" or "X25519",
+// are never approved.
+//
+// IsFIPSApproved doesn't check key sizes nor whether the system
+// is running in FIPS mode, see FIPS.
+func IsFIPSApproved(algID string, usage Usage) bool {
+	if usage < UsageHash || usage > UsageKeyDerivation {
+		return false
+	}
+	return fipsApproved[algID]&(1<<usage) != 0
+}
+
+// ifcStrengths maps RSA and finite field Diffie-Hellman modulus sizes
+// to their security strength, from NIST SP 800-57 Part 1 Rev. 5, Table 2.
+var ifcStrengths = []struct{ bits, strength int }{
+	{15360, 256},
+	{7680, 192},
+	{3072, 128},
+	{2048, 112},
+	{1024, 80},
+}
+
+// eccStrengths maps elliptic curve order sizes to their security strength,
+// from NIST SP 800-57 Part 1 Rev. 5, Table 2.
+var eccStrengths = []struct{ bits, strength int }{
+	{512, 256},
+	{384, 192},
+	{256, 128},
+	{224, 112},
+	{160, 80},
+}
+
+// fixedStrengths lists the algorithms whose security strength doesn't
+// depend on a key size: named curves and hash functions, for which it is
+// the collision resistance strength of NIST SP 800-107 Rev. 1, Table 1.
+var fixedStrengths = map[string]int{
+	"P-224":    112,
+	"P-256":    128,
+	"P-384":    192,
+	"P-521":    256,
+	"X25519":   128,
+	"SHA256":   128,
+	"SHA384":   192,
+	"SHA512":   256,
+	"SHA3-256": 128,
+	"SHA3-384": 192,
+	"SHA3-512": 256,
+}
+
+// SecurityStrength returns the security strength, in bits, estimated by
+// NIST SP 800-57 Part 1 Rev. 5 for the algorithm identified by algID used
+// with a key of keyBits bits, so that keys can be checked against a policy
+// floor. For example, a 2048-bit RSA key provides 112 bits and P-256 128.
+//
+// algID is one of "RSA", an RSA padding scheme such as "RSA-PSS", "DH",
+// "ECDSA" or "ECDH", whose strength depends on keyBits, a curve name such
+// as "P-256", or a hash such as "SHA256", for which keyBits is ignored,
+// or "AES", "3DES" or "DES". Key sizes between two rows of the NIST table
+// get the strength of the smaller one.
+//
+// SecurityStrength returns 0 for unknown algorithms and for those that
+// provide less than 80 bits of security, such as DES, SHA-1 collisions
+// or RSA keys shorter than 1024 bits.
+func SecurityStrength(algID string, keyBits int) int {
+	if s, ok := fixedStrengths[algID]; ok {
+		return s
+	}
+	var table []struct{ bits, strength int }
+	switch algID {
+	case "RSA", "RSA-PKCS1v15", "RSA-PSS", "RSA-OAEP", "DH":
+		table = ifcStrengths
+	case "ECDSA", "ECDH":
+		table = eccStrengths
+	case "AES":
+		switch keyBits {
+		case 128, 192, 256:
+			return keyBits
+		}
+		return 0
+	case "3DES":
+		switch keyBits {
+		case 168, 192: // Three-key Triple DES, with or without the parity bits.
+			return 112
+		case 112, 128: // Two-key Triple DES.
+			return 80
+		}
+		return 0
+	default:
+		return 0
+	}
+	for _, row := range table {
+		if keyBits >= row.bits {
+			return row.strength
+		}
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestIsFIPSApproved(t *testing.T) {
+	tests := []struct {
+		algID string
+		usage cng.Usage
+		want  bool
+	}{
+		{"SHA1", cng.UsageVerify, true},
+		{"SHA1", cng.UsageSign, false},
+		{"SHA1", cng.UsageMAC, true},
+		{"SHA256", cng.UsageSign, true},
+		{"SHA256", cng.UsageEncrypt, false},
+		{"MD5", cng.UsageHash, false},
+		{"AES", cng.UsageEncrypt, true},
+		{"3DES", cng.UsageEncrypt, false},
+		{"3DES", cng.UsageDecrypt, true},
+		{"DES", cng.UsageDecrypt, false},
+		{"RC4", cng.UsageEncrypt, false},
+		{"RSA-PKCS1v15", cng.UsageSign, true},
+		{"RSA-PKCS1v15", cng.UsageEncrypt, false},
+		{"RSA-OAEP", cng.UsageEncrypt, true},
+		{"P-256", cng.UsageKeyAgreement, true},
+		{"P-384", cng.UsageSign, true},
+		{"X25519", cng.UsageKeyAgreement, false},
+		{"HKDF", cng.UsageKeyDerivation, true},
+		{"HKDF", cng.UsageMAC, false},
+		{"unknown", cng.UsageHash, false},
+		{"SHA256", cng.Usage(100), false},
+		{"SHA256", cng.Usage(-1), false},
+	}
+	for _, tt := range tests {
+		if got := cng.IsFIPSApproved(tt.algID, tt.usage); got != tt.want {
+			t.Errorf("IsFIPSApproved(%q, %d) = %v, want %v", tt.algID, tt.usage, got, tt.want)
+		}
+	}
+}
+
+func TestSecurityStrength(t *testing.T) {
+	tests := []struct {
+		algID   string
+		keyBits int
+		want    int
+	}{
+		{"RSA", 1024, 80},
+		{"RSA", 2048, 112},
+		{"RSA-PSS", 3072, 128},
+		{"RSA", 4096, 128},
+		{"RSA", 7680, 192},
+		{"RSA-OAEP", 15360, 256},
+		{"RSA", 512, 0},
+		{"DH", 2048, 112},
+		{"P-224", 0, 112},
+		{"P-256", 0, 128},
+		{"P-384", 0, 192},
+		{"P-521", 0, 256},
+		{"X25519", 0, 128},
+		{"ECDSA", 256, 128},
+		{"ECDH", 521, 256},
+		{"ECDSA", 192, 80},
+		{"AES", 128, 128},
+		{"AES", 256, 256},
+		{"AES", 100, 0},
+		{"3DES", 192, 112},
+		{"3DES", 128, 80},
+		{"DES", 64, 0},
+		{"SHA256", 0, 128},
+		{"SHA512", 0, 256},
+		{"SHA1", 0, 0},
+		{"unknown", 2048, 0},
+	}
+	for _, tt := range tests {
+		if got := cng.SecurityStrength(tt.algID, tt.keyBits); got != tt.want {
+			t.Errorf("SecurityStrength(%q, %d) = %d, want %d", tt.algID, tt.keyBits, got, tt.want)
+		}
+	}
+}
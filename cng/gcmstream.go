@@ -0,0 +1,235 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+	"runtime"
+	"unsafe"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+	"github.com/microsoft/go-crypto-winnative/internal/subtle"
+)
+
+var (
+	errGCMStreamAADAfterData = errors.New("cng: GCM additional data must be provided before any data")
+	errGCMStreamFinished     = errors.New("cng: GCM stream already finished")
+)
+
+// gcmStream implements AES-GCM using CNG chained calls,
+// so the data can be provided in several chunks.
+type gcmStream struct {
+	kh      bcrypt.KEY_HANDLE
+	encrypt bool
+	info    *bcrypt.AUTHENTICATED_CIPHER_MODE_INFO
+
+	// nonce, iv, macCtx and tag are referenced by info,
+	// so they must live as long as the stream.
+	nonce  [gcmStandardNonceSize]byte
+	iv     [aesBlockSize]byte
+	macCtx [gcmTagSize]byte
+	tag    [gcmTagSize]byte
+
+	aad []byte
+	// buf holds the trailing data that doesn't fill a whole block.
+	// CNG requires every chained call but the last one to be block-aligned.
+	buf      []byte
+	started  bool
+	finished bool
+}
+
+// GCMStreamEncrypter encrypts and authenticates a message
+// which is provided in chunks.
+type GCMStreamEncrypter struct {
+	*gcmStream
+}
+
+// GCMStreamDecrypter decrypts and authenticates a message
+// which is provided in chunks.
+type GCMStreamDecrypter struct {
+	*gcmStream
+}
+
+// NewGCMStreamEncrypter returns a streaming AES-GCM encrypter
+// using the standard nonce and tag sizes.
+// c must be a cipher returned by NewAESCipher.
+func NewGCMStreamEncrypter(c cipher.Block, nonce []byte) (*GCMStreamEncrypter, error) {
+	g, err := newGCMStream(c, nonce, true)
+	if err != nil {
+		return nil, err
+	}
+	return &GCMStreamEncrypter{g}, nil
+}
+
+// NewGCMStreamDecrypter returns a streaming AES-GCM decrypter
+// using the standard nonce and tag sizes.
+// c must be a cipher returned by NewAESCipher.
+//
+// The decrypted data returned by Update is not authenticated
+// until Finish succeeds, so it must not be used before that.
+func NewGCMStreamDecrypter(c cipher.Block, nonce []byte) (*GCMStreamDecrypter, error) {
+	g, err := newGCMStream(c, nonce, false)
+	if err != nil {
+		return nil, err
+	}
+	return &GCMStreamDecrypter{g}, nil
+}
+
+func newGCMStream(c cipher.Block, nonce []byte, encrypt bool) (*gcmStream, error) {
+	ac, ok := c.(*aesCipher)
+	if !ok {
+		return nil, errors.New("cng: GCM streams require an AES cipher created by NewAESCipher")
+	}
+	if len(nonce) != gcmStandardNonceSize {
+		return nil, errors.New("cng: incorrect nonce length given to GCM")
+	}
+	kh, err := newCipherHandle(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_GCM, ac.key)
+	if err != nil {
+		return nil, err
+	}
+	g := &gcmStream{kh: kh, encrypt: encrypt}
+	copy(g.nonce[:], nonce)
+	runtime.SetFinalizer(g, (*gcmStream).finalize)
+	return g, nil
+}
+
+func (g *gcmStream) finalize() {
+	bcrypt.DestroyKey(g.kh)
+}
+
+// UpdateAAD adds aad to the additional authenticated data.
+// It can be called several times, but all the additional data
+// must be provided before the first call to Update.
+func (g *gcmStream) UpdateAAD(aad []byte) error {
+	if g.finished {
+		return errGCMStreamFinished
+	}
+	if g.started {
+		return errGCMStreamAADAfterData
+	}
+	g.aad = append(g.aad, aad...)
+	return nil
+}
+
+// Update processes src and appends the result to dst,
+// returning the updated slice.
+// The result can be shorter than src, as incomplete blocks
+// are kept until more data is provided or the stream is finished.
+// dst and src must not overlap.
+func (g *gcmStream) Update(dst, src []byte) ([]byte, error) {
+	if g.finished {
+		return nil, errGCMStreamFinished
+	}
+	g.started = true
+	n := (len(g.buf) + len(src)) / aesBlockSize * aesBlockSize
+	ret, out := sliceForAppend(dst, n)
+	if subtle.AnyOverlap(out, src) {
+		panic("cipher: invalid buffer overlap")
+	}
+	if n == 0 {
+		g.buf = append(g.buf, src...)
+		return ret, nil
+	}
+	if len(g.buf) > 0 {
+		// Complete the pending block first.
+		k := aesBlockSize - len(g.buf)
+		g.buf = append(g.buf, src[:k]...)
+		if err := g.crypt(out[:aesBlockSize], g.buf, false); err != nil {
+			return nil, err
+		}
+		g.buf = g.buf[:0]
+		src = src[k:]
+		out = out[aesBlockSize:]
+	}
+	if m := len(out); m > 0 {
+		if err := g.crypt(out, src[:m], false); err != nil {
+			return nil, err
+		}
+		src = src[m:]
+	}
+	g.buf = append(g.buf, src...)
+	return ret, nil
+}
+
+// final processes the pending data with the last chained call,
+// which also computes or verifies the tag.
+func (g *gcmStream) final(dst []byte) ([]byte, error) {
+	if g.finished {
+		return nil, errGCMStreamFinished
+	}
+	g.finished = true
+	ret, out := sliceForAppend(dst, len(g.buf))
+	if err := g.crypt(out, g.buf, true); err != nil {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, err
+	}
+	Wipe(g.buf)
+	g.buf = nil
+	return ret, nil
+}
+
+func (g *gcmStream) crypt(out, in []byte, last bool) error {
+	defer runtime.KeepAlive(g)
+	if g.info == nil {
+		g.info = bcrypt.NewAUTHENTICATED_CIPHER_MODE_INFO(g.nonce[:], g.aad, g.tag[:])
+		g.info.MacContext = &g.macCtx[0]
+		g.info.MacContextSize = uint32(len(g.macCtx))
+	}
+	if last {
+		g.info.Flags &^= bcrypt.AUTH_MODE_CHAIN_CALLS_FLAG
+	} else {
+		g.info.Flags |= bcrypt.AUTH_MODE_CHAIN_CALLS_FLAG
+	}
+	var n uint32
+	var err error
+	if g.encrypt {
+		err = bcrypt.Encrypt(g.kh, in, unsafe.Pointer(g.info), g.iv[:], out, &n, 0)
+	} else {
+		err = bcrypt.Decrypt(g.kh, in, unsafe.Pointer(g.info), g.iv[:], out, &n, 0)
+	}
+	if err != nil {
+		return err
+	}
+	if int(n) != len(in) {
+		return errors.New("cng: GCM data not fully processed")
+	}
+	return nil
+}
+
+// Finish encrypts the pending data, appends it to dst,
+// and returns the updated slice and the authentication tag.
+// The stream can't be used after calling Finish.
+func (g *GCMStreamEncrypter) Finish(dst []byte) (out, tag []byte, err error) {
+	out, err = g.final(dst)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, append([]byte(nil), g.tag[:]...), nil
+}
+
+// Finish decrypts the pending data, appends it to dst,
+// and returns the updated slice if tag authenticates
+// all the data and additional data provided to the stream.
+// The stream can't be used after calling Finish.
+func (g *GCMStreamDecrypter) Finish(dst, tag []byte) ([]byte, error) {
+	if g.finished {
+		return nil, errGCMStreamFinished
+	}
+	if len(tag) != gcmTagSize {
+		g.finished = true
+		return nil, errOpen
+	}
+	copy(g.tag[:], tag)
+	out, err := g.final(dst)
+	if err != nil {
+		return nil, errOpen
+	}
+	return out, nil
+}
@@ -0,0 +1,201 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGCMStreamAADChunks(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte{0x91, 0xc7, 0xa7, 0x54, 0x52, 0xef, 0x10, 0xdb, 0x91, 0xa8, 0x6c, 0xf9}
+	plainText := []byte("this message is split across several updates")
+	aad := []byte("header fields streamed in chunks")
+	want := gcm.Seal(nil, nonce, plainText, aad)
+
+	enc, err := NewGCMStreamEncrypter(ci, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range [][]byte{aad[:5], aad[5:20], aad[20:]} {
+		if err := enc.UpdateAAD(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := enc.Update(nil, plainText[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = enc.Update(out, plainText[32:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, tag, err := enc.Finish(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := append(out, tag...); !bytes.Equal(got, want) {
+		t.Errorf("got:%x want:%x", got, want)
+	}
+
+	dec, err := NewGCMStreamDecrypter(ci, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.UpdateAAD(aad[:10]); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.UpdateAAD(aad[10:]); err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := dec.Update(nil, want[:len(plainText)])
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err = dec.Finish(decrypted, want[len(plainText):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plainText) {
+		t.Errorf("got:%x want:%x", decrypted, plainText)
+	}
+}
+
+func TestGCMStreamUpdateChunkSizes(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmStandardNonceSize)
+	aad := []byte("additional data")
+	for _, chunkSize := range []int{1, 15, 16, 17} {
+		for _, size := range []int{0, 1, 15, 16, 17, 100} {
+			plainText := bytes.Repeat([]byte{0x5a}, size)
+			want := gcm.Seal(nil, nonce, plainText, aad)
+
+			enc, err := NewGCMStreamEncrypter(ci, nonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.UpdateAAD(aad); err != nil {
+				t.Fatal(err)
+			}
+			var out []byte
+			for off := 0; off < size; off += chunkSize {
+				end := off + chunkSize
+				if end > size {
+					end = size
+				}
+				if out, err = enc.Update(out, plainText[off:end]); err != nil {
+					t.Fatal(err)
+				}
+				// Only whole blocks are processed before Finish.
+				if len(out) != end/aesBlockSize*aesBlockSize {
+					t.Fatalf("chunk %d, size %d: %d bytes out after %d bytes in", chunkSize, size, len(out), end)
+				}
+			}
+			out, tag, err := enc.Finish(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tag) != gcmTagSize {
+				t.Errorf("chunk %d, size %d: tag is %d bytes, want %d", chunkSize, size, len(tag), gcmTagSize)
+			}
+			if got := append(out, tag...); !bytes.Equal(got, want) {
+				t.Errorf("chunk %d, size %d: got:%x want:%x", chunkSize, size, got, want)
+			}
+
+			dec, err := NewGCMStreamDecrypter(ci, nonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dec.UpdateAAD(aad); err != nil {
+				t.Fatal(err)
+			}
+			var decrypted []byte
+			for off := 0; off < size; off += chunkSize {
+				end := off + chunkSize
+				if end > size {
+					end = size
+				}
+				if decrypted, err = dec.Update(decrypted, want[off:end]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if decrypted, err = dec.Finish(decrypted, want[size:]); err != nil {
+				t.Fatalf("chunk %d, size %d: %v", chunkSize, size, err)
+			}
+			if !bytes.Equal(decrypted, plainText) {
+				t.Errorf("chunk %d, size %d: got:%x want:%x", chunkSize, size, decrypted, plainText)
+			}
+		}
+	}
+}
+
+func TestGCMStreamAADAfterData(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewGCMStreamEncrypter(ci, make([]byte, gcmStandardNonceSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.UpdateAAD([]byte("aad")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.Update(nil, make([]byte, aesBlockSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.UpdateAAD([]byte("late aad")); err != errGCMStreamAADAfterData {
+		t.Errorf("got %v, want %v", err, errGCMStreamAADAfterData)
+	}
+}
+
+func TestGCMStreamAuthenticationError(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmStandardNonceSize)
+	enc, err := NewGCMStreamEncrypter(ci, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc.UpdateAAD([]byte("aad"))
+	ct, err := enc.Update(nil, []byte("plaintext"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, tag, err := enc.Finish(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewGCMStreamDecrypter(ci, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec.UpdateAAD([]byte("bad"))
+	if _, err := dec.Update(nil, ct); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dec.Finish(nil, tag); err != errOpen {
+		t.Errorf("got %v, want %v", err, errOpen)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/subtle"
+)
+
+// VerifyGMAC reports whether tag is the valid AES-GMAC authentication tag
+// of aad under key and nonce, that is, the AES-GCM tag of an empty plaintext.
+// nonce and tag must have the standard GCM sizes, 12 and 16 bytes respectively.
+// The tags are compared in constant time.
+func VerifyGMAC(key, nonce, aad, tag []byte) bool {
+	if len(nonce) != gcmStandardNonceSize || len(tag) != gcmTagSize {
+		return false
+	}
+	g, err := newGCM(key, false)
+	if err != nil {
+		return false
+	}
+	want := g.Seal(nil, nonce, nil, aad)
+	return subtle.ConstantTimeCompare(want, tag) == 1
+}
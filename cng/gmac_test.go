@@ -0,0 +1,47 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestVerifyGMAC(t *testing.T) {
+	key := []byte("D249BF6DEC97B1EBD69BC4D6B3A3C49D")
+	nonce := []byte{0x91, 0xc7, 0xa7, 0x54, 0x52, 0xef, 0x10, 0xdb, 0x91, 0xa8, 0x6c, 0xf9}
+	aad := []byte("authenticated but not encrypted record")
+
+	// Compute the reference tag using the standard library.
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := gcm.Seal(nil, nonce, nil, aad)
+
+	if !cng.VerifyGMAC(key, nonce, aad, tag) {
+		t.Error("valid tag failed to verify")
+	}
+	aad[0] ^= 0x01
+	if cng.VerifyGMAC(key, nonce, aad, tag) {
+		t.Error("tag verified despite flipped AAD bit")
+	}
+	aad[0] ^= 0x01
+	if cng.VerifyGMAC(key, nonce, aad, tag[:12]) {
+		t.Error("truncated tag verified")
+	}
+	if cng.VerifyGMAC(key, nonce[:8], aad, tag) {
+		t.Error("tag verified with a short nonce")
+	}
+}
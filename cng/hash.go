@@ -8,8 +8,11 @@ package cng
 
 import (
 	"crypto"
+	"errors"
 	"hash"
+	"math"
 	"runtime"
+	"sync"
 	"unsafe"
 
 	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
@@ -38,6 +41,15 @@ func hashOneShot(id string, p, sum []byte) error {
 	if err != nil {
 		return err
 	}
+	if len(p) > math.MaxInt32 {
+		// BCryptHash takes a 32-bit input length, so larger inputs
+		// are fed through the chunked Write of an incremental hash.
+		hx := newHashX(id, bcrypt.ALG_NONE_FLAG, nil)
+		defer hx.Close()
+		hx.Write(p)
+		copy(sum, hx.Sum(nil))
+		return nil
+	}
 	return bcrypt.Hash(h.handle, nil, p, sum)
 }
 
@@ -149,11 +161,96 @@ func NewSHA3_512() hash.Hash {
 	return newHashX(bcrypt.SHA3_512_ALGORITHM, bcrypt.ALG_NONE_FLAG, nil)
 }
 
+// PrefixedHash returns the hash identified by hashID, such as "SHA256",
+// of prefix followed by data. It panics if hashID is not supported.
+//
+// The prefix is not length-delimited, so each domain must use a fixed
+// prefix that is not a prefix of another domain's.
+func PrefixedHash(hashID string, prefix, data []byte) []byte {
+	h := newHashX(hashID, bcrypt.ALG_NONE_FLAG, nil)
+	h.Write(prefix)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// HashOptions configures the hashes returned by NewHashWithOptions
+// and NewHMACWithOptions. Each distinct set of options uses its own
+// cached algorithm provider.
+//
+// Ciphers have no equivalent options, as the remaining provider flag,
+// BCRYPT_PROV_DISPATCH, only applies to kernel-mode callers.
+type HashOptions struct {
+	// DisableReuse opens the algorithm provider without
+	// BCRYPT_HASH_REUSABLE_FLAG, so that Reset destroys the CNG hash
+	// object and the next Write recreates it. By default, hashes are
+	// opened as reusable wherever the platform supports it, which makes
+	// Reset cheaper, so this is mostly useful for benchmarks.
+	DisableReuse bool
+}
+
+// loadHash loads the algorithm provider for id configured by o.
+func (o HashOptions) loadHash(id string, flags bcrypt.AlgorithmProviderFlags) (*hashAlgorithm, error) {
+	if o.DisableReuse {
+		return loadHash(id, flags)
+	}
+	return loadReusableHash(id, flags)
+}
+
+// NewHashWithOptions returns a new hash.Hash computing h,
+// using an algorithm provider configured by opts.
+func NewHashWithOptions(h crypto.Hash, opts HashOptions) (hash.Hash, error) {
+	id := cryptoHashToID(h)
+	if id == "" {
+		return nil, errors.New("cng: unsupported hash function")
+	}
+	alg, err := opts.loadHash(id, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	return newHashXWithAlg(alg, nil), nil
+}
+
+// hashOIDs maps CNG hash IDs to their ASN.1 object identifiers,
+// as used in AlgorithmIdentifier and PKCS #1 DigestInfo structures.
+var hashOIDs = map[string][]int{
+	bcrypt.MD4_ALGORITHM:      {1, 2, 840, 113549, 2, 4},
+	bcrypt.MD5_ALGORITHM:      {1, 2, 840, 113549, 2, 5},
+	bcrypt.SHA1_ALGORITHM:     {1, 3, 14, 3, 2, 26},
+	bcrypt.SHA256_ALGORITHM:   {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	bcrypt.SHA384_ALGORITHM:   {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	bcrypt.SHA512_ALGORITHM:   {2, 16, 840, 1, 101, 3, 4, 2, 3},
+	bcrypt.SHA3_256_ALGORITHM: {2, 16, 840, 1, 101, 3, 4, 2, 8},
+	bcrypt.SHA3_384_ALGORITHM: {2, 16, 840, 1, 101, 3, 4, 2, 9},
+	bcrypt.SHA3_512_ALGORITHM: {2, 16, 840, 1, 101, 3, 4, 2, 10},
+}
+
+// HashInfo returns the object identifier, digest size and block size,
+// in bytes, of the hash identified by hashID, such as "SHA256".
+// The sizes are queried from CNG, so an error is returned if the hash
+// is not available on this system.
+//
+// oid can be assigned directly to an asn1.ObjectIdentifier;
+// this package doesn't depend on encoding/asn1.
+func HashInfo(hashID string) (oid []int, size, blockSize int, err error) {
+	known, ok := hashOIDs[hashID]
+	if !ok {
+		return nil, 0, 0, errors.New("cng: unsupported hash function")
+	}
+	h, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return append([]int(nil), known...), int(h.size), int(h.blockSize), nil
+}
+
 type hashAlgorithm struct {
 	handle    bcrypt.ALG_HANDLE
 	id        string
 	size      uint32
 	blockSize uint32
+	// reusable is true if the provider was opened with BCRYPT_HASH_REUSABLE_FLAG,
+	// in which case BCryptFinishHash resets the hash object for reuse.
+	reusable bool
 }
 
 func loadHash(id string, flags bcrypt.AlgorithmProviderFlags) (*hashAlgorithm, error) {
@@ -166,7 +263,7 @@ func loadHash(id string, flags bcrypt.AlgorithmProviderFlags) (*hashAlgorithm, e
 		if err != nil {
 			return nil, err
 		}
-		return &hashAlgorithm{h, id, size, blockSize}, nil
+		return &hashAlgorithm{h, id, size, blockSize, flags&bcrypt.HASH_REUSABLE_FLAG != 0}, nil
 	})
 	if err != nil {
 		return nil, err
@@ -184,20 +281,65 @@ func hashToID(h hash.Hash) string {
 	return hx.alg.id
 }
 
+// Hash is a hash.Hash that also reports which crypto.Hash it implements.
+// All the hashes returned by this package implement Hash.
+type Hash interface {
+	hash.Hash
+	// Algorithm returns the crypto.Hash implemented by the hash,
+	// or 0 if it is not identified by a crypto.Hash, as with MACs
+	// not built on top of a hash function.
+	Algorithm() crypto.Hash
+}
+
 type hashX struct {
 	alg  *hashAlgorithm
 	_ctx bcrypt.HASH_HANDLE // access it using withCtx
 
-	buf []byte
-	key []byte
+	buf    []byte
+	key    []byte
+	closed bool
+}
+
+var (
+	reusableHashOnce      sync.Once
+	reusableHashSupported bool
+)
+
+// supportsReusableHash reports whether hash providers can be opened
+// with BCRYPT_HASH_REUSABLE_FLAG, which requires Windows 8 or later.
+// The result is cached so older platforms don't retry on every hash.
+func supportsReusableHash() bool {
+	reusableHashOnce.Do(func() {
+		_, err := loadHash(bcrypt.SHA256_ALGORITHM, bcrypt.HASH_REUSABLE_FLAG)
+		reusableHashSupported = err == nil
+	})
+	return reusableHashSupported
+}
+
+// loadReusableHash loads the algorithm provider for id, opened with
+// BCRYPT_HASH_REUSABLE_FLAG where supported, so that Reset doesn't
+// have to recreate the hash object.
+func loadReusableHash(id string, flag bcrypt.AlgorithmProviderFlags) (*hashAlgorithm, error) {
+	if flag&bcrypt.HASH_REUSABLE_FLAG == 0 && supportsReusableHash() {
+		if alg, err := loadHash(id, flag|bcrypt.HASH_REUSABLE_FLAG); err == nil {
+			return alg, nil
+		}
+	}
+	return loadHash(id, flag)
 }
 
-// newHashX returns a new hash.Hash using the specified algorithm.
+// newHashX returns a new hash.Hash using the specified algorithm,
+// reusable where supported.
 func newHashX(id string, flag bcrypt.AlgorithmProviderFlags, key []byte) *hashX {
-	alg, err := loadHash(id, flag)
+	alg, err := loadReusableHash(id, flag)
 	if err != nil {
 		panic(err)
 	}
+	return newHashXWithAlg(alg, key)
+}
+
+// newHashXWithAlg returns a new hash.Hash using the algorithm provider alg.
+func newHashXWithAlg(alg *hashAlgorithm, key []byte) *hashX {
 	h := new(hashX)
 	h.alg = alg
 	if len(key) > 0 {
@@ -217,10 +359,14 @@ func (h *hashX) finalize() {
 	if h._ctx != 0 {
 		bcrypt.DestroyHash(h._ctx)
 	}
+	Wipe(h.key)
 }
 
 func (h *hashX) withCtx(fn func(ctx bcrypt.HASH_HANDLE) error) error {
 	defer runtime.KeepAlive(h)
+	if h.closed {
+		return ErrClosed
+	}
 	if h._ctx == 0 {
 		err := bcrypt.CreateHash(h.alg.handle, &h._ctx, nil, h.key, 0)
 		if err != nil {
@@ -248,14 +394,47 @@ func (h *hashX) Clone() (hash.Hash, error) {
 	return h2, nil
 }
 
-func (h *hashX) Reset() {
+// Close destroys the hash handle and zeros the key, if any.
+// Write and Clone return ErrClosed after Close, and Sum returns its
+// argument unchanged.
+// Close returns ErrClosed if the hash is already closed.
+func (h *hashX) Close() error {
+	if h.closed {
+		return ErrClosed
+	}
+	h.closed = true
+	h.destroy()
+	Wipe(h.key)
+	h.key = nil
+	runtime.SetFinalizer(h, nil)
+	return nil
+}
+
+func (h *hashX) destroy() {
 	if h._ctx != 0 {
 		bcrypt.DestroyHash(h._ctx)
 		h._ctx = 0
 	}
 }
 
+func (h *hashX) Reset() {
+	if h._ctx != 0 && h.alg.reusable {
+		// Finishing a reusable hash resets it to its initial state,
+		// which is cheaper than destroying and recreating it.
+		if h.buf == nil {
+			h.buf = make([]byte, h.alg.size)
+		}
+		if bcrypt.FinishHash(h._ctx, h.buf, 0) == nil {
+			return
+		}
+	}
+	h.destroy()
+}
+
 func (h *hashX) Write(p []byte) (n int, err error) {
+	if h.closed {
+		return 0, ErrClosed
+	}
 	err = h.withCtx(func(ctx bcrypt.HASH_HANDLE) error {
 		for n < len(p) && err == nil {
 			nn := len32(p[n:])
@@ -282,6 +461,9 @@ func (h *hashX) WriteString(s string) (int, error) {
 }
 
 func (h *hashX) WriteByte(c byte) error {
+	if h.closed {
+		return ErrClosed
+	}
 	err := h.withCtx(func(ctx bcrypt.HASH_HANDLE) error {
 		return bcrypt.HashDataRaw(h._ctx, &c, 1, 0)
 	})
@@ -292,6 +474,10 @@ func (h *hashX) WriteByte(c byte) error {
 	return nil
 }
 
+func (h *hashX) Algorithm() crypto.Hash {
+	return idToCryptoHash(h.alg.id)
+}
+
 func (h *hashX) Size() int {
 	return int(h.alg.size)
 }
@@ -301,6 +487,11 @@ func (h *hashX) BlockSize() int {
 }
 
 func (h *hashX) Sum(in []byte) []byte {
+	if h.closed {
+		// hash.Hash has no way to report an error from Sum,
+		// and Write already reported ErrClosed.
+		return in
+	}
 	var ctx2 bcrypt.HASH_HANDLE
 	err := h.withCtx(func(ctx bcrypt.HASH_HANDLE) error {
 		return bcrypt.DuplicateHash(ctx, &ctx2, nil, 0)
@@ -9,8 +9,13 @@ package cng_test
 import (
 	"bytes"
 	"crypto"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
 	"hash"
 	"io"
+	"os"
+	"strconv"
 	"testing"
 
 	"github.com/microsoft/go-crypto-winnative/cng"
@@ -112,6 +117,41 @@ func TestHash(t *testing.T) {
 	}
 }
 
+func TestHashAlgorithm(t *testing.T) {
+	if got := cng.NewSHA256().(cng.Hash).Algorithm(); got != crypto.SHA256 {
+		t.Errorf("NewSHA256().Algorithm() = %v, want %v", got, crypto.SHA256)
+	}
+	var tests = []crypto.Hash{
+		crypto.MD4,
+		crypto.MD5,
+		crypto.SHA1,
+		crypto.SHA256,
+		crypto.SHA384,
+		crypto.SHA512,
+		crypto.SHA3_256,
+		crypto.SHA3_384,
+		crypto.SHA3_512,
+	}
+	for _, tt := range tests {
+		t.Run(tt.String(), func(t *testing.T) {
+			if !cng.SupportsHash(tt) {
+				t.Skip("skipping: not supported")
+			}
+			h, ok := cryptoToHash(tt)().(cng.Hash)
+			if !ok {
+				t.Fatal("hash does not implement cng.Hash")
+			}
+			if got := h.Algorithm(); got != tt {
+				t.Errorf("Algorithm() = %v, want %v", got, tt)
+			}
+			hmac := cng.NewHMAC(cryptoToHash(tt), []byte("key")).(cng.Hash)
+			if got := hmac.Algorithm(); got != tt {
+				t.Errorf("HMAC Algorithm() = %v, want %v", got, tt)
+			}
+		})
+	}
+}
+
 func TestHash_OneShot(t *testing.T) {
 	msg := []byte("testing")
 	var tests = []struct {
@@ -171,6 +211,181 @@ func TestHash_OneShot(t *testing.T) {
 	}
 }
 
+func TestPrefixedHash(t *testing.T) {
+	prefix := []byte("example.com/v1/commitment")
+	data := []byte("hello world")
+	h := cng.NewSHA256()
+	h.Write(prefix)
+	h.Write(data)
+	want := h.Sum(nil)
+	if got := cng.PrefixedHash("SHA256", prefix, data); !bytes.Equal(got, want) {
+		t.Errorf("PrefixedHash() = %x, want %x", got, want)
+	}
+	if got := cng.PrefixedHash("SHA256", nil, data); bytes.Equal(got, want) {
+		t.Error("PrefixedHash() ignored the prefix")
+	}
+}
+
+func TestHashInfo(t *testing.T) {
+	tests := []struct {
+		id        string
+		oid       asn1.ObjectIdentifier
+		size      int
+		blockSize int
+	}{
+		{"SHA1", asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, 20, 64},
+		{"SHA256", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, 32, 64},
+		{"SHA384", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}, 48, 128},
+		{"SHA512", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}, 64, 128},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			var oid asn1.ObjectIdentifier
+			oid, size, blockSize, err := cng.HashInfo(tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !oid.Equal(tt.oid) {
+				t.Errorf("oid = %v, want %v", oid, tt.oid)
+			}
+			if size != tt.size || blockSize != tt.blockSize {
+				t.Errorf("sizes = %d, %d, want %d, %d", size, blockSize, tt.size, tt.blockSize)
+			}
+		})
+	}
+	if _, _, _, err := cng.HashInfo("SHA224"); err == nil {
+		t.Error("expected error for unsupported hash")
+	}
+}
+
+func TestHashInfoDigestInfo(t *testing.T) {
+	// The DigestInfo prefix for SHA-256 from RFC 8017, Section 9.2.
+	want := hexDecode(t, "3031300d060960864801650304020105000420")
+	oid, size, _, err := cng.HashInfo("SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type algorithmIdentifier struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.RawValue
+	}
+	type digestInfo struct {
+		Algorithm algorithmIdentifier
+		Digest    []byte
+	}
+	der, err := asn1.Marshal(digestInfo{
+		Algorithm: algorithmIdentifier{oid, asn1.NullRawValue},
+		Digest:    make([]byte, size),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := der[:len(der)-size]; !bytes.Equal(got, want) {
+		t.Errorf("DigestInfo prefix = %x, want %x", got, want)
+	}
+}
+
+func TestHashLargeInput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	if strconv.IntSize < 64 {
+		t.Skip("skipping: requires a 64-bit platform")
+	}
+	// Feed a stream longer than 4 GiB, so that a 32-bit length
+	// truncation anywhere in the hash path would change the digest.
+	const chunkSize = 64 << 20
+	const total = 1<<32 + 3*chunkSize
+	chunk := make([]byte, chunkSize)
+	for i := range chunk {
+		chunk[i] = byte(i * 7)
+	}
+	h := cng.NewSHA256()
+	ref := sha256.New()
+	for n := int64(0); n < total; n += chunkSize {
+		h.Write(chunk)
+		ref.Write(chunk)
+	}
+	if got, want := h.Sum(nil), ref.Sum(nil); !bytes.Equal(got, want) {
+		t.Errorf("streamed SHA256:\ngot  %x\nwant %x", got, want)
+	}
+
+	// A single buffer larger than 4 GiB is only tested on request,
+	// as it needs that much memory.
+	if os.Getenv("GO_TEST_LARGE_ALLOC") == "" {
+		return
+	}
+	size := int64(1<<32 + chunkSize)
+	buf := make([]byte, size)
+	for n := 0; n < len(buf); n += chunkSize {
+		copy(buf[n:], chunk)
+	}
+	ref.Reset()
+	ref.Write(buf)
+	want := ref.Sum(nil)
+	if got := cng.SHA256(buf); !bytes.Equal(got[:], want) {
+		t.Errorf("one-shot SHA256:\ngot  %x\nwant %x", got, want)
+	}
+	h.Reset()
+	h.Write(buf)
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		t.Errorf("single Write SHA256:\ngot  %x\nwant %x", got, want)
+	}
+}
+
+func TestHashDisableReuse(t *testing.T) {
+	opts := cng.HashOptions{DisableReuse: true}
+	h, err := cng.NewHashWithOptions(crypto.SHA256, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, err := cng.NewHMACWithOptions(crypto.SHA256, []byte("key"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := cng.NewHMAC(cng.NewSHA256, []byte("key"))
+	for _, msg := range []string{"", "a", "hello world", "a"} {
+		h.Reset()
+		h.Write([]byte("garbage"))
+		h.Reset()
+		h.Write([]byte(msg))
+		if got, want := h.Sum(nil), cng.SHA256([]byte(msg)); !bytes.Equal(got, want[:]) {
+			t.Errorf("hash(%q) = %x, want %x", msg, got, want)
+		}
+
+		mac.Reset()
+		mac.Write([]byte(msg))
+		ref.Reset()
+		ref.Write([]byte(msg))
+		if got, want := mac.Sum(nil), ref.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("hmac(%q) = %x, want %x", msg, got, want)
+		}
+	}
+	if _, err := cng.NewHashWithOptions(crypto.RIPEMD160, opts); err == nil {
+		t.Error("expected error for unsupported hash")
+	}
+}
+
+func BenchmarkSHA256_Reset(b *testing.B) {
+	buf := make([]byte, 8)
+	for _, reusable := range []bool{false, true} {
+		b.Run(fmt.Sprintf("reusable=%v", reusable), func(b *testing.B) {
+			h, err := cng.NewHashWithOptions(crypto.SHA256, cng.HashOptions{DisableReuse: !reusable})
+			if err != nil {
+				b.Fatal(err)
+			}
+			sum := make([]byte, h.Size())
+			b.SetBytes(int64(len(buf)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h.Reset()
+				h.Write(buf)
+				h.Sum(sum[:0])
+			}
+		})
+	}
+}
+
 func BenchmarkSHA256_8Bytes(b *testing.B) {
 	b.StopTimer()
 	h := cng.NewSHA256()
@@ -7,6 +7,7 @@
 package cng
 
 import (
+	"crypto/cipher"
 	"encoding/binary"
 	"errors"
 	"hash"
@@ -94,14 +95,19 @@ func (c *hkdf) Read(p []byte) (int, error) {
 			// The buffer can't grow beyond maxDerived.
 			alloc = maxDerived - len(c.buf)
 		}
+		prevLen := len(c.buf)
 		c.buf = append(c.buf, make([]byte, alloc)...)
 		n, err := hkdfDerive(c.hkey, c.info, c.buf)
+		if err == nil && n < totalDerived {
+			err = errors.New("hkdf: derived less bytes than requested")
+		}
 		if err != nil {
-			c.buf = c.buf[:c.n]
+			c.buf = c.buf[:prevLen]
 			return 0, err
 		}
-		// Adjust totalDerived to the actual number of bytes derived.
-		totalDerived = n
+		// Only keep the bytes actually derived, which might be less
+		// than len(c.buf) but are always enough to fill p.
+		c.buf = c.buf[:n]
 	}
 	n := copy(p, c.buf[c.n:totalDerived])
 	c.n += n
@@ -114,48 +120,44 @@ func newHKDF(h func() hash.Hash, secret, salt []byte, info []byte) (*hkdf, error
 	if hashID == "" {
 		return nil, errors.New("cng: unsupported hash function")
 	}
-	alg, err := loadHKDF()
+	kh, err := newHKDFKey(hashID, secret, salt)
 	if err != nil {
 		return nil, err
 	}
-	var kh bcrypt.KEY_HANDLE
-	if err := bcrypt.GenerateSymmetricKey(alg, &kh, nil, secret, 0); err != nil {
-		return nil, err
-	}
-	if err := setString(bcrypt.HANDLE(kh), bcrypt.HKDF_HASH_ALGORITHM, hashID); err != nil {
-		bcrypt.DestroyKey(kh)
-		return nil, err
-	}
-	if salt != nil {
-		// Used for Extract.
-		err = bcrypt.SetProperty(bcrypt.HANDLE(kh), utf16PtrFromString(bcrypt.HKDF_SALT_AND_FINALIZE), salt, 0)
-	} else {
-		// Used for Expand.
-		err = bcrypt.SetProperty(bcrypt.HANDLE(kh), utf16PtrFromString(bcrypt.HKDF_PRK_AND_FINALIZE), nil, 0)
-	}
-	if err != nil {
-		bcrypt.DestroyKey(kh)
-		return nil, err
-	}
 	k := &hkdf{kh, info, ch.Size(), 0, nil}
 	runtime.SetFinalizer(k, (*hkdf).finalize)
 	return k, nil
 }
 
+// ExtractHKDF returns the HKDF pseudorandom key extracted from secret
+// and salt using the hash returned by h. A nil or empty salt is replaced
+// by HashLen zero bytes, the default of RFC 5869, Section 2.2, as in
+// x/crypto/hkdf. HMAC pads keys with zeros, so both give the same result,
+// but CNG is never handed an empty salt.
 func ExtractHKDF(h func() hash.Hash, secret, salt []byte) ([]byte, error) {
-	if salt == nil {
-		// Replicate x/crypto/hkdf behavior.
-		salt = make([]byte, h().Size())
+	ch := h()
+	hashID := hashToID(ch)
+	if hashID == "" {
+		return nil, errors.New("cng: unsupported hash function")
+	}
+	if len(salt) == 0 {
+		salt = make([]byte, ch.Size())
 	}
-	kh, err := newHKDF(h, secret, salt, nil)
+	return extractHKDF(hashID, secret, salt)
+}
+
+// extractHKDF implements HKDF-Extract using the hash hashID.
+// salt must not be nil.
+func extractHKDF(hashID string, secret, salt []byte) ([]byte, error) {
+	kh, err := newHKDFKey(hashID, secret, salt)
 	if err != nil {
 		return nil, err
 	}
-	hdr, blob, err := exportKeyData(kh.hkey)
+	defer bcrypt.DestroyKey(kh)
+	hdr, blob, err := exportKeyData(kh)
 	if err != nil {
 		return nil, err
 	}
-	runtime.KeepAlive(kh)
 	if hdr.Version != bcrypt.KEY_DATA_BLOB_VERSION1 {
 		return nil, errors.New("cng: unknown key data blob version")
 	}
@@ -170,6 +172,35 @@ func ExtractHKDF(h func() hash.Hash, secret, salt []byte) ([]byte, error) {
 	return blob[4+hashLength:], nil
 }
 
+// newHKDFKey returns a finalized HKDF key handle using the hash hashID.
+// If salt is nil, secret is used as the pseudorandom key.
+func newHKDFKey(hashID string, secret, salt []byte) (bcrypt.KEY_HANDLE, error) {
+	alg, err := loadHKDF()
+	if err != nil {
+		return 0, err
+	}
+	var kh bcrypt.KEY_HANDLE
+	if err := bcrypt.GenerateSymmetricKey(alg, &kh, nil, secret, 0); err != nil {
+		return 0, err
+	}
+	if err := setString(bcrypt.HANDLE(kh), bcrypt.HKDF_HASH_ALGORITHM, hashID); err != nil {
+		bcrypt.DestroyKey(kh)
+		return 0, err
+	}
+	if salt != nil {
+		// Used for Extract.
+		err = bcrypt.SetProperty(bcrypt.HANDLE(kh), utf16PtrFromString(bcrypt.HKDF_SALT_AND_FINALIZE), salt, 0)
+	} else {
+		// Used for Expand.
+		err = bcrypt.SetProperty(bcrypt.HANDLE(kh), utf16PtrFromString(bcrypt.HKDF_PRK_AND_FINALIZE), nil, 0)
+	}
+	if err != nil {
+		bcrypt.DestroyKey(kh)
+		return 0, err
+	}
+	return kh, nil
+}
+
 func ExpandHKDF(h func() hash.Hash, pseudorandomKey, info []byte) (io.Reader, error) {
 	kh, err := newHKDF(h, pseudorandomKey, nil, info)
 	if err != nil {
@@ -177,3 +208,79 @@ func ExpandHKDF(h func() hash.Hash, pseudorandomKey, info []byte) (io.Reader, er
 	}
 	return kh, nil
 }
+
+// ExpandRequest describes a single HKDF-Expand output.
+type ExpandRequest struct {
+	Info   []byte
+	Length int
+}
+
+// HKDFExpandMulti expands pseudorandomKey once per request using the hash
+// identified by hashID, such as "SHA256", and returns the outputs in order.
+// All the expansions share a single CNG key handle, avoiding the cost
+// of importing the pseudorandom key for each of them.
+func HKDFExpandMulti(hashID string, pseudorandomKey []byte, requests []ExpandRequest) ([][]byte, error) {
+	alg, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	maxLength := 255 * int(alg.size)
+	for _, r := range requests {
+		if r.Length < 0 || r.Length > maxLength {
+			return nil, errors.New("hkdf: invalid output length")
+		}
+	}
+	kh, err := newHKDFKey(hashID, pseudorandomKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer bcrypt.DestroyKey(kh)
+	out := make([][]byte, len(requests))
+	for i, r := range requests {
+		out[i] = make([]byte, r.Length)
+		if r.Length == 0 {
+			continue
+		}
+		n, err := hkdfDerive(kh, r.Info, out[i])
+		if err != nil {
+			return nil, err
+		}
+		if n != r.Length {
+			return nil, errors.New("hkdf: short derivation")
+		}
+	}
+	return out, nil
+}
+
+// NewGCMFromSecret derives a 32-byte AES key from secret with HKDF,
+// using the hash identified by hashID, such as "SHA256", and the given
+// salt and info, and returns an AES-256-GCM AEAD with the standard nonce
+// and tag sizes. A nil or empty salt is replaced by a hash-length
+// zero salt, as in ExtractHKDF.
+//
+// Parties sharing a secret, for example from ECDH, get interoperable
+// AEADs as long as they agree on salt, info and hashID.
+func NewGCMFromSecret(secret, salt, info []byte, hashID string) (cipher.AEAD, error) {
+	alg, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	if len(salt) == 0 {
+		salt = make([]byte, alg.size)
+	}
+	kh, err := newHKDFKey(hashID, secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer bcrypt.DestroyKey(kh)
+	var key [32]byte
+	defer Wipe(key[:])
+	n, err := hkdfDerive(kh, info, key[:])
+	if err != nil {
+		return nil, err
+	}
+	if n != len(key) {
+		return nil, errors.New("hkdf: short derivation")
+	}
+	return NewGCMFromKey(key[:])
+}
@@ -8,6 +8,9 @@ package cng_test
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
 	"hash"
 	"io"
 	"testing"
@@ -307,6 +310,40 @@ func newHKDF(hash func() hash.Hash, secret, salt, info []byte) io.Reader {
 	return r
 }
 
+// TestExtractHKDFDefaultSalt checks that a nil or empty salt is the
+// HashLen zero salt of RFC 5869, which x/crypto/hkdf substitutes too.
+func TestExtractHKDFDefaultSalt(t *testing.T) {
+	if !cng.SupportsHKDF() {
+		t.Skip("HKDF is not supported")
+	}
+	secret := []byte("input keying material")
+	tests := []struct {
+		name string
+		cng  func() hash.Hash
+		std  func() hash.Hash
+	}{
+		{"SHA256", cng.NewSHA256, sha256.New},
+		{"SHA384", cng.NewSHA384, sha512.New384},
+		{"SHA512", cng.NewSHA512, sha512.New},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mac := hmac.New(tt.std, make([]byte, tt.std().Size()))
+			mac.Write(secret)
+			want := mac.Sum(nil)
+			for _, salt := range [][]byte{nil, {}, make([]byte, tt.std().Size())} {
+				prk, err := cng.ExtractHKDF(tt.cng, secret, salt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(prk, want) {
+					t.Errorf("salt %#v: got %x, want %x", salt, prk, want)
+				}
+			}
+		})
+	}
+}
+
 func TestHKDF(t *testing.T) {
 	if !cng.SupportsHKDF() {
 		t.Skip("HKDF is not supported")
@@ -439,3 +476,159 @@ func benchmarkHKDFStream(hasher func() hash.Hash, block int, b *testing.B) {
 		}
 	}
 }
+
+func TestHKDFExpandMulti(t *testing.T) {
+	prk, err := cng.ExtractHKDF(cng.NewSHA256, []byte("secret"), []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []cng.ExpandRequest{
+		{Info: []byte("client key"), Length: 16},
+		{Info: []byte("server key"), Length: 16},
+		{Info: []byte("client iv"), Length: 12},
+		{Info: nil, Length: 100},
+		{Info: []byte("empty"), Length: 0},
+	}
+	got, err := cng.HKDFExpandMulti("SHA256", prk, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(requests) {
+		t.Fatalf("got %d outputs, want %d", len(got), len(requests))
+	}
+	for i, r := range requests {
+		rd, err := cng.ExpandHKDF(cng.NewSHA256, prk, r.Info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]byte, r.Length)
+		if _, err := io.ReadFull(rd, want); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got[i], want) {
+			t.Errorf("output %d = %x, want %x", i, got[i], want)
+		}
+	}
+	if _, err := cng.HKDFExpandMulti("SHA256", prk, []cng.ExpandRequest{{Length: 255*32 + 1}}); err == nil {
+		t.Error("expected error for too long output")
+	}
+}
+
+func TestExpandHKDFLengths(t *testing.T) {
+	prk := bytes.Repeat([]byte{0x0b}, 32)
+	info := []byte("info")
+	const hashLen = sha256.Size
+	for _, length := range []int{1, hashLen - 1, hashLen, hashLen + 1, 255*hashLen - 1, 255 * hashLen} {
+		r, err := cng.ExpandHKDF(cng.NewSHA256, prk, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, length)
+		n, err := r.Read(out)
+		if err != nil {
+			t.Fatalf("length %d: %v", length, err)
+		}
+		if n != length {
+			t.Fatalf("length %d: Read() = %d bytes", length, n)
+		}
+		if want := hkdfExpandRef(prk, info, length); !bytes.Equal(out, want) {
+			t.Errorf("length %d: got %x, want %x", length, out, want)
+		}
+		if _, err := r.Read(make([]byte, 255*hashLen-length+1)); err == nil {
+			t.Errorf("length %d: reading past the limit succeeded", length)
+		}
+	}
+}
+
+// hkdfExpandRef is a reference HKDF-Expand implementation using crypto/hmac.
+func hkdfExpandRef(prk, info []byte, length int) []byte {
+	var out, prev []byte
+	for i := byte(1); len(out) < length; i++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		prev = mac.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
+
+func TestNewGCMFromSecret(t *testing.T) {
+	alice, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicePub, err := alice.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPub, err := bob.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceSecret, err := cng.ECDH(alice, bobPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSecret, err := cng.ECDH(bob, alicePub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt, info := []byte("salt"), []byte("app v1 channel key")
+	aliceAEAD, err := cng.NewGCMFromSecret(aliceSecret, salt, info, "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobAEAD, err := cng.NewGCMFromSecret(bobSecret, salt, info, "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aliceAEAD.NonceSize())
+	msg := []byte("hello bob")
+	sealed := aliceAEAD.Seal(nil, nonce, msg, nil)
+	got, err := bobAEAD.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("Open() = %q, want %q", got, msg)
+	}
+
+	// The key is the HKDF output.
+	prk, err := cng.ExtractHKDF(cng.NewSHA256, aliceSecret, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := cng.ExpandHKDF(cng.NewSHA256, prk, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(r, key); err != nil {
+		t.Fatal(err)
+	}
+	want, err := cng.NewGCMFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := want.Seal(nil, nonce, msg, nil); !bytes.Equal(sealed, w) {
+		t.Errorf("Seal() = %x, want %x", sealed, w)
+	}
+
+	// A different info gives an AEAD that can't open the message.
+	other, err := cng.NewGCMFromSecret(bobSecret, salt, []byte("other"), "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(nil, nonce, sealed, nil); err == nil {
+		t.Error("AEAD derived with another info opened the message")
+	}
+	if _, err := cng.NewGCMFromSecret(aliceSecret, salt, info, "NOTAHASH"); err == nil {
+		t.Error("expected an error for an unknown hash")
+	}
+}
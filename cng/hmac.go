@@ -7,6 +7,8 @@
 package cng
 
 import (
+	"crypto"
+	"errors"
 	"hash"
 
 	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
@@ -17,19 +19,49 @@ import (
 // CNG (for example, h could be cng.NewSHA256).
 // If h is not recognized, NewHMAC returns nil.
 func NewHMAC(h func() hash.Hash, key []byte) hash.Hash {
-	ch := h()
-	id := hashToID(ch)
+	id := hashToID(h())
 	if id == "" {
 		return nil
 	}
-	if len(key) > ch.BlockSize() {
+	hx, err := newHMACByID(id, key)
+	if err != nil {
+		panic(err)
+	}
+	return hx
+}
+
+// newHMACByID returns a new HMAC using the CNG hash algorithm
+// identified by id, such as "SHA256".
+func newHMACByID(id string, key []byte) (*hashX, error) {
+	return newHMACWithOptions(id, key, HashOptions{})
+}
+
+// NewHMACWithOptions returns a new HMAC hash using the given hash function
+// and key, using an algorithm provider configured by opts.
+func NewHMACWithOptions(h crypto.Hash, key []byte, opts HashOptions) (hash.Hash, error) {
+	id := cryptoHashToID(h)
+	if id == "" {
+		return nil, errors.New("cng: unsupported hash function")
+	}
+	return newHMACWithOptions(id, key, opts)
+}
+
+func newHMACWithOptions(id string, key []byte, opts HashOptions) (*hashX, error) {
+	alg, err := opts.loadHash(id, bcrypt.ALG_HANDLE_HMAC_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) > int(alg.blockSize) {
 		// Keys longer than BlockSize are first hashed using
 		// the same hash function, according to RFC 2104, Section 3.
 		// BCrypt already does that, but if we hash the key on our side
 		// we avoid allocating unnecessary memory and
 		// allow keys longer than math.MaxUint32 bytes.
-		ch.Write(key)
-		key = ch.Sum(nil)
+		sum := make([]byte, alg.size)
+		if err := hashOneShot(id, key, sum); err != nil {
+			return nil, err
+		}
+		key = sum
 	}
-	return newHashX(id, bcrypt.ALG_HANDLE_HMAC_FLAG, key)
+	return newHashXWithAlg(alg, key), nil
 }
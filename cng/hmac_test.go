@@ -8,9 +8,16 @@ package cng
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
 	"hash"
 	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
 )
 
 func TestHMAC_EmptyKey(t *testing.T) {
@@ -88,3 +95,204 @@ func TestHMAC(t *testing.T) {
 		})
 	}
 }
+
+func TestHMAC_Close(t *testing.T) {
+	h := NewHMAC(NewSHA256, []byte("key"))
+	h.Write([]byte("message"))
+	hx := h.(*hashX)
+	if err := hx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if hx.key != nil {
+		t.Error("key not wiped")
+	}
+	if err := hx.Close(); err != ErrClosed {
+		t.Errorf("second Close() = %v, want %v", err, ErrClosed)
+	}
+	if _, err := h.Write([]byte("message")); err != ErrClosed {
+		t.Errorf("Write() after Close = %v, want %v", err, ErrClosed)
+	}
+	if err := hx.WriteByte(0); err != ErrClosed {
+		t.Errorf("WriteByte() after Close = %v, want %v", err, ErrClosed)
+	}
+	if _, err := hx.Clone(); err != ErrClosed {
+		t.Errorf("Clone() after Close = %v, want %v", err, ErrClosed)
+	}
+	h.Reset()
+	in := []byte("prefix")
+	if got := h.Sum(in); string(got) != "prefix" {
+		t.Errorf("Sum() after Close = %q, want the input unchanged", got)
+	}
+}
+
+func TestHashReusableByDefault(t *testing.T) {
+	if !supportsReusableHash() {
+		t.Skip("reusable hashes are not supported")
+	}
+	if h := NewSHA256().(*hashX); !h.alg.reusable {
+		t.Error("NewSHA256 is not reusable")
+	}
+	if h := NewHMAC(NewSHA256, []byte("key")).(*hashX); !h.alg.reusable {
+		t.Error("NewHMAC is not reusable")
+	}
+}
+
+func TestHashOptionsDisableReuse(t *testing.T) {
+	opts := HashOptions{DisableReuse: true}
+	h, err := NewHashWithOptions(crypto.SHA256, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.(*hashX).alg.reusable {
+		t.Error("NewHashWithOptions with DisableReuse is reusable")
+	}
+	mac, err := NewHMACWithOptions(crypto.SHA256, []byte("key"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mac.(*hashX).alg.reusable {
+		t.Error("NewHMACWithOptions with DisableReuse is reusable")
+	}
+}
+
+// BenchmarkHashReuse hashes one million small messages per iteration,
+// resetting the same hash object in between, with and without
+// BCRYPT_HASH_REUSABLE_FLAG.
+func BenchmarkHashReuse(b *testing.B) {
+	const messages = 1000000
+	msg := make([]byte, 16)
+	for _, reusable := range []bool{false, true} {
+		b.Run(fmt.Sprintf("reusable=%v", reusable), func(b *testing.B) {
+			flags := bcrypt.ALG_NONE_FLAG
+			if reusable {
+				if !supportsReusableHash() {
+					b.Skip("reusable hashes are not supported")
+				}
+				flags = bcrypt.HASH_REUSABLE_FLAG
+			}
+			h := newHashX(bcrypt.SHA256_ALGORITHM, bcrypt.ALG_NONE_FLAG, nil)
+			// Override the default provider to control reusability.
+			alg, err := loadHash(bcrypt.SHA256_ALGORITHM, flags)
+			if err != nil {
+				b.Fatal(err)
+			}
+			h.alg = alg
+			sum := make([]byte, h.Size())
+			b.SetBytes(messages * int64(len(msg)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < messages; j++ {
+					h.Reset()
+					h.Write(msg)
+					h.Sum(sum[:0])
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	key := []byte("webhook secret")
+	tests := []struct {
+		id string
+		h  func() hash.Hash
+	}{
+		{"SHA1", sha1.New},
+		{"SHA256", sha256.New},
+		{"SHA384", sha512.New384},
+		{"SHA512", sha512.New},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			// Verify several messages so that pooled objects are reused.
+			for i := 0; i < 5; i++ {
+				msg := []byte(fmt.Sprintf("payload %d", i))
+				ref := hmac.New(tt.h, key)
+				ref.Write(msg)
+				mac := ref.Sum(nil)
+				if !VerifyHMAC(tt.id, key, msg, mac) {
+					t.Fatalf("message %d: valid MAC rejected", i)
+				}
+				if VerifyHMAC(tt.id, key, msg, mac[:len(mac)-1]) {
+					t.Fatalf("message %d: truncated MAC accepted", i)
+				}
+				mac[0] ^= 1
+				if VerifyHMAC(tt.id, key, msg, mac) {
+					t.Fatalf("message %d: corrupted MAC accepted", i)
+				}
+				mac[0] ^= 1
+				if VerifyHMAC(tt.id, []byte("another secret"), msg, mac) {
+					t.Fatalf("message %d: MAC accepted under another key", i)
+				}
+			}
+		})
+	}
+	if VerifyHMAC("NOTAHASH", key, nil, make([]byte, 32)) {
+		t.Error("MAC accepted for an unknown hash")
+	}
+}
+
+func TestVerifyHMACPoolEviction(t *testing.T) {
+	msg := []byte("payload")
+	macFor := func(key []byte) []byte {
+		ref := hmac.New(sha256.New, key)
+		ref.Write(msg)
+		return ref.Sum(nil)
+	}
+	hot := []byte("hot key")
+	hotMAC := macFor(hot)
+	for i := 0; i < 2*maxHMACPools; i++ {
+		key := []byte(fmt.Sprintf("tenant key %d", i))
+		if !VerifyHMAC("SHA256", key, msg, macFor(key)) {
+			t.Fatalf("key %d: valid MAC rejected", i)
+		}
+		if !VerifyHMAC("SHA256", hot, msg, hotMAC) {
+			t.Fatal("valid MAC rejected for the hot key")
+		}
+	}
+	hmacPools.RLock()
+	n := len(hmacPools.m)
+	hmacPools.RUnlock()
+	if n > maxHMACPools {
+		t.Errorf("%d pools, want at most %d", n, maxHMACPools)
+	}
+
+	// The most recently used keys keep their pools, the others were evicted.
+	hasPool := func(key []byte) bool {
+		k := hmacPoolKey{hashID: "SHA256"}
+		if !hmacKeyID(key, &k.id) {
+			t.Fatal("fingerprint failed")
+		}
+		hmacPools.RLock()
+		defer hmacPools.RUnlock()
+		return hmacPools.m[k] != nil
+	}
+	if !hasPool(hot) {
+		t.Error("pool of the most used key was evicted")
+	}
+	if hasPool([]byte("tenant key 0")) {
+		t.Error("pool of the least recently used key was kept")
+	}
+	if !hasPool([]byte(fmt.Sprintf("tenant key %d", 2*maxHMACPools-1))) {
+		t.Error("pool of the last key was evicted")
+	}
+}
+
+func BenchmarkVerifyHMAC(b *testing.B) {
+	key := []byte("webhook secret")
+	msg := make([]byte, 1024)
+	ref := hmac.New(sha256.New, key)
+	ref.Write(msg)
+	mac := ref.Sum(nil)
+	// Warm up the pool.
+	if !VerifyHMAC("SHA256", key, msg, mac) {
+		b.Fatal("valid MAC rejected")
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !VerifyHMAC("SHA256", key, msg, mac) {
+			b.Fatal("valid MAC rejected")
+		}
+	}
+}
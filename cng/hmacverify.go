@@ -0,0 +1,183 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/subtle"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// maxHMACPools bounds the number of distinct (hash, key) pairs
+// for which VerifyHMAC keeps a pool of HMAC objects.
+const maxHMACPools = 64
+
+// hmacMaxSize is the largest digest size of the supported hashes.
+const hmacMaxSize = 64
+
+// hmacPoolKey indexes the VerifyHMAC pools. The key is identified by
+// an HMAC-SHA256 fingerprint under a random process secret, so the
+// index doesn't retain it: only the pooled HMAC objects hold a copy,
+// which they wipe when closed or finalized.
+type hmacPoolKey struct {
+	hashID string
+	id     [keyFingerprintSize]byte
+}
+
+type hmacPoolEntry struct {
+	pool    sync.Pool // of *hashX
+	lastUse int64     // hmacPools.clock value of the last use, accessed atomically
+}
+
+// hmacPools holds the pools of reusable HMAC objects used by VerifyHMAC.
+// When full, the least recently used pool is evicted to make room.
+var hmacPools struct {
+	sync.RWMutex
+	m     map[hmacPoolKey]*hmacPoolEntry
+	clock int64 // accessed atomically
+}
+
+var (
+	hmacFingerprintOnce sync.Once
+	hmacFingerprints    sync.Pool // of *hashX keyed with the fingerprint secret
+)
+
+// hmacKeyID computes the fingerprint of key used to index hmacPools.
+func hmacKeyID(key []byte, id *[keyFingerprintSize]byte) bool {
+	hmacFingerprintOnce.Do(func() {
+		secret := make([]byte, keyFingerprintSize)
+		// Always use CNG, as the fingerprints must not be predictable.
+		if _, err := io.ReadFull(RandReader, secret); err != nil {
+			return
+		}
+		hmacFingerprints.New = func() interface{} {
+			h, err := newHMACByID(bcrypt.SHA256_ALGORITHM, secret)
+			if err != nil {
+				return nil
+			}
+			return h
+		}
+	})
+	h, _ := hmacFingerprints.Get().(*hashX)
+	if h == nil {
+		return false
+	}
+	h.Write(key)
+	ok := h.sumReset(id[:]) == nil
+	hmacFingerprints.Put(h)
+	return ok
+}
+
+// hmacPool returns the pool of HMAC objects for hashID and key,
+// or nil if hashID is not supported.
+func hmacPool(hashID string, key []byte) *sync.Pool {
+	k := hmacPoolKey{hashID: hashID}
+	if !hmacKeyID(key, &k.id) {
+		return nil
+	}
+	now := atomic.AddInt64(&hmacPools.clock, 1)
+	hmacPools.RLock()
+	e := hmacPools.m[k]
+	hmacPools.RUnlock()
+	if e != nil {
+		atomic.StoreInt64(&e.lastUse, now)
+		return &e.pool
+	}
+	if _, err := loadHash(hashID, bcrypt.ALG_HANDLE_HMAC_FLAG); err != nil {
+		return nil
+	}
+	hmacPools.Lock()
+	defer hmacPools.Unlock()
+	if e := hmacPools.m[k]; e != nil {
+		atomic.StoreInt64(&e.lastUse, now)
+		return &e.pool
+	}
+	if hmacPools.m == nil {
+		hmacPools.m = make(map[hmacPoolKey]*hmacPoolEntry)
+	}
+	if len(hmacPools.m) >= maxHMACPools {
+		evictHMACPool()
+	}
+	e = &hmacPoolEntry{lastUse: now}
+	hmacPools.m[k] = e
+	return &e.pool
+}
+
+// evictHMACPool removes the least recently used pool and closes the
+// HMAC objects it still holds. Objects in use by a concurrent VerifyHMAC
+// are put back in the evicted pool and wiped by their finalizer.
+// hmacPools must be locked.
+func evictHMACPool() {
+	var oldest hmacPoolKey
+	var oldestEntry *hmacPoolEntry
+	for k, e := range hmacPools.m {
+		if oldestEntry == nil || atomic.LoadInt64(&e.lastUse) < atomic.LoadInt64(&oldestEntry.lastUse) {
+			oldest, oldestEntry = k, e
+		}
+	}
+	delete(hmacPools.m, oldest)
+	for {
+		h, _ := oldestEntry.pool.Get().(*hashX)
+		if h == nil {
+			break
+		}
+		h.Close()
+	}
+}
+
+// VerifyHMAC reports whether mac is the HMAC of message under key,
+// using the hash identified by hashID, such as "SHA256". The comparison
+// is done in constant time, and a truncated mac is rejected.
+//
+// VerifyHMAC is meant for hot paths verifying messages under a few
+// long-lived keys: the HMAC objects are pooled per hash and key,
+// so once warmed up a verification doesn't allocate. Pools are kept
+// for the 64 most recently used pairs, indexed by a keyed fingerprint
+// of the key. The pooled objects hold a copy of the key, which is wiped
+// when the pool is evicted or the objects are garbage collected.
+func VerifyHMAC(hashID string, key, message, mac []byte) bool {
+	pool := hmacPool(hashID, key)
+	var h *hashX
+	if pool != nil {
+		h, _ = pool.Get().(*hashX)
+	}
+	if h == nil {
+		var err error
+		h, err = newHMACByID(hashID, key)
+		if err != nil {
+			return false
+		}
+	}
+	var sum [hmacMaxSize]byte
+	out := sum[:h.Size()]
+	h.Write(message)
+	ok := h.sumReset(out) == nil && subtle.ConstantTimeCompare(out, mac) == 1
+	if pool != nil {
+		pool.Put(h)
+	} else {
+		h.Close()
+	}
+	return ok
+}
+
+// sumReset writes the digest of the data written so far to out,
+// which must be h.Size() bytes long, and resets h. Unlike Sum,
+// it doesn't duplicate the hash state first.
+func (h *hashX) sumReset(out []byte) error {
+	err := h.withCtx(func(ctx bcrypt.HASH_HANDLE) error {
+		return bcrypt.FinishHash(ctx, out, 0)
+	})
+	// Finishing a reusable hash resets it, otherwise
+	// the handle can't be used anymore.
+	if err != nil || !h.alg.reusable {
+		h.destroy()
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// HOTP computes the HMAC-based one-time password defined in RFC 4226
+// for the given key and counter, using the CNG hash algorithm identified by hashID,
+// which must be "SHA1", "SHA256" or "SHA512", the hashes allowed by RFC 6238.
+// digits must be between 6 and 8, both included.
+//
+// A TOTP value, as defined in RFC 6238, can be computed by passing
+// the number of time steps since the Unix epoch as counter.
+func HOTP(key []byte, counter uint64, digits int, hashID string) (string, error) {
+	if digits < 6 || digits > 8 {
+		return "", errors.New("cng: HOTP digits must be between 6 and 8")
+	}
+	switch hashID {
+	case bcrypt.SHA1_ALGORITHM, bcrypt.SHA256_ALGORITHM, bcrypt.SHA512_ALGORITHM:
+	default:
+		// Dynamic truncation reads 4 bytes at an offset of up to 15,
+		// so shorter digests such as MD5 can't be used.
+		return "", errors.New("cng: unsupported HOTP hash " + hashID)
+	}
+	h, err := newHMACByID(hashID, key)
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+
+	// Dynamic truncation, see RFC 4226 Section 5.3.
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	var otp [8]byte
+	for i := digits - 1; i >= 0; i-- {
+		otp[i] = '0' + byte(code%10)
+		code /= 10
+	}
+	return string(otp[:digits]), nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestHOTP(t *testing.T) {
+	// Test vectors from RFC 4226, Appendix D.
+	key := []byte("12345678901234567890")
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for i, w := range want {
+		got, err := cng.HOTP(key, uint64(i), 6, "SHA1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("counter %d: got %s, want %s", i, got, w)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// Test vectors from RFC 6238, Appendix B.
+	keys := map[string][]byte{
+		"SHA1":   []byte("12345678901234567890"),
+		"SHA256": []byte("12345678901234567890123456789012"),
+		"SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		time uint64
+		hash string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, tt := range tests {
+		got, err := cng.HOTP(keys[tt.hash], tt.time/30, 8, tt.hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s at %d: got %s, want %s", tt.hash, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestHOTPInvalidDigits(t *testing.T) {
+	for _, digits := range []int{0, 5, 9} {
+		if _, err := cng.HOTP([]byte("key"), 0, digits, "SHA1"); err == nil {
+			t.Errorf("digits %d: expected error", digits)
+		}
+	}
+}
+
+func TestHOTPUnsupportedHash(t *testing.T) {
+	// With MD5, the truncation offset can be 15, past the end of the
+	// 16-byte digest, so it must be rejected rather than panic.
+	for _, id := range []string{"MD5", "MD4", "SHA384", "NOTAHASH"} {
+		for counter := uint64(0); counter < 64; counter++ {
+			if _, err := cng.HOTP([]byte("12345678901234567890"), counter, 6, id); err == nil {
+				t.Fatalf("%s: expected error", id)
+			}
+		}
+	}
+}
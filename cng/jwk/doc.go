@@ -0,0 +1,9 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Package jwk encodes and parses cng keys as JSON Web Keys,
+// as defined in RFC 7517 and RFC 7518.
+//
+// It lives outside package cng so that cng keeps the dependencies of
+// the crypto packages it backs, which can't import encoding/json.
+package jwk
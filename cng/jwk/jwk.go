@@ -0,0 +1,244 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package jwk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+var errPrivateFromPublic = errors.New("cng: cannot marshal private JWK from a public key")
+
+// jsonWebKey is the JSON Web Key representation defined in RFC 7517 and RFC 7518.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	Dp  string `json:"dp,omitempty"`
+	Dq  string `json:"dq,omitempty"`
+	Qi  string `json:"qi,omitempty"`
+}
+
+// curves lists the supported "crv" values, those registered by RFC 7518,
+// and their coordinate sizes.
+var curves = []struct {
+	name string
+	size int
+}{
+	{"P-256", 32},
+	{"P-384", 48},
+	{"P-521", 66},
+}
+
+// Marshal returns the JSON Web Key encoding of key, which must be a
+// *cng.PublicKeyECDSA, *cng.PrivateKeyECDSA, *cng.PublicKeyRSA or
+// *cng.PrivateKeyRSA.
+//
+// Only the public members are encoded unless includePrivate is true,
+// in which case key must be a private key and the private members
+// ("d", and "p", "q", "dp", "dq", "qi" for RSA) are encoded as well.
+func Marshal(key interface{}, includePrivate bool) ([]byte, error) {
+	var k jsonWebKey
+	var err error
+	switch key := key.(type) {
+	case *cng.PublicKeyECDSA:
+		if includePrivate {
+			return nil, errPrivateFromPublic
+		}
+		var x, y cng.BigInt
+		if x, y, err = key.Export(); err == nil {
+			err = k.setECC(x, y, nil)
+		}
+	case *cng.PrivateKeyECDSA:
+		var x, y, d cng.BigInt
+		if x, y, d, err = key.Export(); err == nil {
+			if !includePrivate {
+				cng.Wipe(d)
+				d = nil
+			}
+			err = k.setECC(x, y, d)
+		}
+	case *cng.PublicKeyRSA:
+		if includePrivate {
+			return nil, errPrivateFromPublic
+		}
+		err = k.setRSA(key.N, key.E, nil)
+	case *cng.PrivateKeyRSA:
+		var private []func() (cng.BigInt, error)
+		if includePrivate {
+			private = []func() (cng.BigInt, error){key.D, key.PrimeP, key.PrimeQ, key.Dp, key.Dq, key.Qinv}
+		}
+		err = k.setRSA(key.N, key.E, private)
+	default:
+		return nil, errors.New("cng: unsupported JWK key type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&k)
+}
+
+// Parse parses a JSON Web Key and imports it as a *cng.PublicKeyECDSA,
+// *cng.PrivateKeyECDSA, *cng.PublicKeyRSA or *cng.PrivateKeyRSA,
+// depending on its "kty" and on whether it has private members.
+//
+// EC coordinates must be encoded at the full curve size as required by
+// RFC 7518, Section 6.2.1. RSA private keys must include the CRT members.
+func Parse(data []byte) (interface{}, error) {
+	var k jsonWebKey
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, errors.New("cng: invalid JWK: " + err.Error())
+	}
+	switch k.Kty {
+	case "EC":
+		return k.parseECC()
+	case "RSA":
+		return k.parseRSA()
+	case "":
+		return nil, errors.New("cng: JWK is missing kty")
+	default:
+		return nil, errors.New("cng: unsupported JWK kty " + strconv.Quote(k.Kty))
+	}
+}
+
+func (k *jsonWebKey) parseECC() (interface{}, error) {
+	size := 0
+	for _, c := range curves {
+		if c.name == k.Crv {
+			size = c.size
+		}
+	}
+	if size == 0 {
+		return nil, errors.New("cng: unsupported JWK crv " + strconv.Quote(k.Crv))
+	}
+	x, err := decodeMember("x", k.X, size)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeMember("y", k.Y, size)
+	if err != nil {
+		return nil, err
+	}
+	if k.D == "" {
+		return cng.NewPublicKeyECDSA(k.Crv, x, y)
+	}
+	d, err := decodeMember("d", k.D, size)
+	if err != nil {
+		return nil, err
+	}
+	defer cng.Wipe(d)
+	return cng.NewPrivateKeyECDSA(k.Crv, x, y, d)
+}
+
+func (k *jsonWebKey) parseRSA() (interface{}, error) {
+	n, err := decodeMember("n", k.N, 0)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeMember("e", k.E, 0)
+	if err != nil {
+		return nil, err
+	}
+	if k.D == "" {
+		return cng.NewPublicKeyRSA(n, e)
+	}
+	if k.P == "" || k.Q == "" || k.Dp == "" || k.Dq == "" || k.Qi == "" {
+		return nil, errors.New("cng: RSA JWK without CRT members is not supported")
+	}
+	var priv [6]cng.BigInt
+	defer func() {
+		for _, b := range priv {
+			cng.Wipe(b)
+		}
+	}()
+	for i, m := range []struct{ name, value string }{
+		{"d", k.D}, {"p", k.P}, {"q", k.Q}, {"dp", k.Dp}, {"dq", k.Dq}, {"qi", k.Qi},
+	} {
+		if priv[i], err = decodeMember(m.name, m.value, 0); err != nil {
+			return nil, err
+		}
+	}
+	return cng.NewPrivateKeyRSA(n, e, priv[0], priv[1], priv[2], priv[3], priv[4], priv[5])
+}
+
+// decodeMember decodes the base64url member value. If size is not
+// zero, the decoded value must be exactly size bytes long.
+func decodeMember(name, value string, size int) (cng.BigInt, error) {
+	if value == "" {
+		return nil, errors.New("cng: JWK is missing " + name)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("cng: invalid JWK " + name + ": " + err.Error())
+	}
+	if size != 0 && len(b) != size {
+		return nil, errors.New("cng: invalid JWK " + name + " length")
+	}
+	return b, nil
+}
+
+// setECC sets the EC members of k from the fixed-size coordinates x and y
+// and, if not nil, the private scalar d, which it wipes.
+func (k *jsonWebKey) setECC(x, y, d cng.BigInt) error {
+	defer cng.Wipe(d)
+	for _, c := range curves {
+		if c.size == len(x) {
+			k.Crv = c.name
+		}
+	}
+	if k.Crv == "" {
+		return errors.New("cng: unsupported JWK curve")
+	}
+	k.Kty = "EC"
+	k.X = base64URL(x)
+	k.Y = base64URL(y)
+	if d != nil {
+		k.D = base64URL(d)
+	}
+	return nil
+}
+
+// setRSA sets the RSA members of k from the public components n and e
+// and, if not empty, the private components d, p, q, dp, dq and qi.
+func (k *jsonWebKey) setRSA(n, e func() (cng.BigInt, error), private []func() (cng.BigInt, error)) error {
+	var members [8]string
+	for i, component := range append([]func() (cng.BigInt, error){n, e}, private...) {
+		v, err := component()
+		if err != nil {
+			return err
+		}
+		members[i] = base64URL(trimLeadingZeros(v))
+		cng.Wipe(v)
+	}
+	k.Kty = "RSA"
+	k.N, k.E = members[0], members[1]
+	if len(private) != 0 {
+		k.D, k.P, k.Q, k.Dp, k.Dq, k.Qi = members[2], members[3], members[4], members[5], members[6], members[7]
+	}
+	return nil
+}
+
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func trimLeadingZeros(b []byte) []byte {
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
@@ -0,0 +1,299 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package jwk_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+	"github.com/microsoft/go-crypto-winnative/cng/jwk"
+)
+
+func newRSAKey(t *testing.T, size int) (*cng.PrivateKeyRSA, *cng.PublicKeyRSA) {
+	t.Helper()
+	N, E, D, P, Q, Dp, Dq, Qinv, err := cng.GenerateKeyRSA(size)
+	if err != nil {
+		t.Fatalf("GenerateKeyRSA(%d): %v", size, err)
+	}
+	priv, err := cng.NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv)
+	if err != nil {
+		t.Fatalf("NewPrivateKeyRSA(%d): %v", size, err)
+	}
+	pub, err := cng.NewPublicKeyRSA(N, E)
+	if err != nil {
+		t.Fatalf("NewPublicKeyRSA(%d): %v", size, err)
+	}
+	return priv, pub
+}
+
+func decodeJWK(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("invalid JWK %s: %v", data, err)
+	}
+	return m
+}
+
+func b64url(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// rfc7517EC is the P-256 private key from RFC 7517, Appendix A.2.
+var rfc7517EC = map[string]string{
+	"kty": "EC",
+	"crv": "P-256",
+	"x":   "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+	"y":   "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+	"d":   "870MB6gfuTJ4HtUnUvYMyJpr5eUZNP4Bk43bVdj3eAE",
+}
+
+func TestMarshalJWKECDSA(t *testing.T) {
+	x := b64url(t, rfc7517EC["x"])
+	y := b64url(t, rfc7517EC["y"])
+	d := b64url(t, rfc7517EC["d"])
+	priv, err := cng.NewPrivateKeyECDSA("P-256", x, y, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDSA("P-256", x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPub := map[string]string{
+		"kty": rfc7517EC["kty"],
+		"crv": rfc7517EC["crv"],
+		"x":   rfc7517EC["x"],
+		"y":   rfc7517EC["y"],
+	}
+
+	data, err := jwk.Marshal(pub, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJWK(t, data); !reflect.DeepEqual(got, wantPub) {
+		t.Errorf("public key JWK:\ngot  %v\nwant %v", got, wantPub)
+	}
+	data, err = jwk.Marshal(priv, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJWK(t, data); !reflect.DeepEqual(got, wantPub) {
+		t.Errorf("private key JWK without private members:\ngot  %v\nwant %v", got, wantPub)
+	}
+	data, err = jwk.Marshal(priv, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJWK(t, data); !reflect.DeepEqual(got, rfc7517EC) {
+		t.Errorf("private key JWK:\ngot  %v\nwant %v", got, rfc7517EC)
+	}
+	if _, err := jwk.Marshal(pub, true); err == nil {
+		t.Error("expected error marshaling private JWK from a public key")
+	}
+}
+
+func TestMarshalJWKRSA(t *testing.T) {
+	N, E, D, P, Q, Dp, Dq, Qinv, err := cng.GenerateKeyRSA(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := cng.NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyRSA(N, E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := func(b cng.BigInt) string {
+		return base64.RawURLEncoding.EncodeToString(new(big.Int).SetBytes(b).Bytes())
+	}
+	wantPub := map[string]string{
+		"kty": "RSA",
+		"n":   enc(N),
+		"e":   enc(E),
+	}
+	if wantPub["e"] != "AQAB" {
+		t.Errorf("e = %q, want AQAB", wantPub["e"])
+	}
+	wantPriv := map[string]string{
+		"kty": "RSA",
+		"n":   enc(N),
+		"e":   enc(E),
+		"d":   enc(D),
+		"p":   enc(P),
+		"q":   enc(Q),
+		"dp":  enc(Dp),
+		"dq":  enc(Dq),
+		"qi":  enc(Qinv),
+	}
+
+	data, err := jwk.Marshal(pub, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJWK(t, data); !reflect.DeepEqual(got, wantPub) {
+		t.Errorf("public key JWK:\ngot  %v\nwant %v", got, wantPub)
+	}
+	data, err = jwk.Marshal(priv, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJWK(t, data); !reflect.DeepEqual(got, wantPub) {
+		t.Errorf("private key JWK without private members:\ngot  %v\nwant %v", got, wantPub)
+	}
+	data, err = jwk.Marshal(priv, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJWK(t, data); !reflect.DeepEqual(got, wantPriv) {
+		t.Errorf("private key JWK:\ngot  %v\nwant %v", got, wantPriv)
+	}
+	if _, err := jwk.Marshal(pub, true); err == nil {
+		t.Error("expected error marshaling private JWK from a public key")
+	}
+}
+
+func TestMarshalJWKUnsupported(t *testing.T) {
+	priv, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwk.Marshal(priv, false); err == nil {
+		t.Error("expected error for unsupported key type")
+	}
+	// P-224 is not a registered JWK curve.
+	x, y, _, err := cng.GenerateKeyECDSA("P-224")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDSA("P-224", x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwk.Marshal(pub, false); err == nil {
+		t.Error("expected error for P-224 key")
+	}
+}
+
+func TestParseJWKECDSA(t *testing.T) {
+	data, err := json.Marshal(rfc7517EC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, ok := key.(*cng.PrivateKeyECDSA)
+	if !ok {
+		t.Fatalf("Parse returned %T, want *cng.PrivateKeyECDSA", key)
+	}
+	out, err := jwk.Marshal(priv, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJWK(t, out); !reflect.DeepEqual(got, rfc7517EC) {
+		t.Errorf("round trip:\ngot  %v\nwant %v", got, rfc7517EC)
+	}
+
+	pubJWK := map[string]string{"kty": "EC", "crv": "P-256", "x": rfc7517EC["x"], "y": rfc7517EC["y"]}
+	data, err = json.Marshal(pubJWK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = jwk.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, ok := key.(*cng.PublicKeyECDSA)
+	if !ok {
+		t.Fatalf("Parse returned %T, want *cng.PublicKeyECDSA", key)
+	}
+	hashed := make([]byte, 32)
+	r, s, err := cng.SignECDSA(priv, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.VerifyECDSA(pub, hashed, r, s) {
+		t.Error("signature from parsed private key does not verify with parsed public key")
+	}
+}
+
+func TestParseJWKRSA(t *testing.T) {
+	priv, _ := newRSAKey(t, 2048)
+	data, err := jwk.Marshal(priv, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, ok := key.(*cng.PrivateKeyRSA)
+	if !ok {
+		t.Fatalf("Parse returned %T, want *cng.PrivateKeyRSA", key)
+	}
+	out, err := jwk.Marshal(parsed, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decodeJWK(t, out), decodeJWK(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\ngot  %v\nwant %v", got, want)
+	}
+
+	data, err = jwk.Marshal(priv, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = jwk.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := key.(*cng.PublicKeyRSA); !ok {
+		t.Fatalf("Parse returned %T, want *cng.PublicKeyRSA", key)
+	}
+}
+
+func TestParseJWKInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  map[string]string
+	}{
+		{"missing kty", map[string]string{"crv": "P-256", "x": rfc7517EC["x"], "y": rfc7517EC["y"]}},
+		{"unsupported kty", map[string]string{"kty": "oct", "k": "AAAA"}},
+		{"unsupported crv", map[string]string{"kty": "EC", "crv": "secp256k1", "x": rfc7517EC["x"], "y": rfc7517EC["y"]}},
+		{"P-224", map[string]string{"kty": "EC", "crv": "P-224", "x": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "y": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		{"missing y", map[string]string{"kty": "EC", "crv": "P-256", "x": rfc7517EC["x"]}},
+		{"short x", map[string]string{"kty": "EC", "crv": "P-256", "x": "AQAB", "y": rfc7517EC["y"]}},
+		{"bad base64", map[string]string{"kty": "EC", "crv": "P-256", "x": "!!", "y": rfc7517EC["y"]}},
+		{"curve mismatch", map[string]string{"kty": "EC", "crv": "P-384", "x": rfc7517EC["x"], "y": rfc7517EC["y"]}},
+		{"RSA without CRT", map[string]string{"kty": "RSA", "n": "AQAB", "e": "AQAB", "d": "AQAB"}},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.jwk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jwk.Parse(data); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+	if _, err := jwk.Parse([]byte("{")); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// TestResult is the outcome of a known-answer test.
+type TestResult struct {
+	// Algorithm is the name of the tested algorithm, for example "AES-GCM".
+	Algorithm string
+	// Err is nil if the test passed, else it describes the failure.
+	Err error
+}
+
+// Passed reports whether the test passed.
+func (r TestResult) Passed() bool {
+	return r.Err == nil
+}
+
+// KnownAnswerTests runs a known-answer test for each of the
+// AES, AES-GCM, SHA, HMAC, ECDSA and RSA implementations
+// and returns the results, in that order.
+//
+// It is meant to be called by applications that want to gate their
+// startup on the correct behavior of the cryptographic module,
+// so it doesn't depend on the Go testing package.
+func KnownAnswerTests() []TestResult {
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{"AES", katAES},
+		{"AES-GCM", katAESGCM},
+		{"SHA-1", katHash(bcrypt.SHA1_ALGORITHM, []byte{
+			0xa9, 0x99, 0x3e, 0x36, 0x47, 0x06, 0x81, 0x6a, 0xba, 0x3e, 0x25, 0x71, 0x78, 0x50, 0xc2, 0x6c,
+			0x9c, 0xd0, 0xd8, 0x9d,
+		})},
+		{"SHA-256", katHash(bcrypt.SHA256_ALGORITHM, []byte{
+			0xba, 0x78, 0x16, 0xbf, 0x8f, 0x01, 0xcf, 0xea, 0x41, 0x41, 0x40, 0xde, 0x5d, 0xae, 0x22, 0x23,
+			0xb0, 0x03, 0x61, 0xa3, 0x96, 0x17, 0x7a, 0x9c, 0xb4, 0x10, 0xff, 0x61, 0xf2, 0x00, 0x15, 0xad,
+		})},
+		{"SHA-384", katHash(bcrypt.SHA384_ALGORITHM, []byte{
+			0xcb, 0x00, 0x75, 0x3f, 0x45, 0xa3, 0x5e, 0x8b, 0xb5, 0xa0, 0x3d, 0x69, 0x9a, 0xc6, 0x50, 0x07,
+			0x27, 0x2c, 0x32, 0xab, 0x0e, 0xde, 0xd1, 0x63, 0x1a, 0x8b, 0x60, 0x5a, 0x43, 0xff, 0x5b, 0xed,
+			0x80, 0x86, 0x07, 0x2b, 0xa1, 0xe7, 0xcc, 0x23, 0x58, 0xba, 0xec, 0xa1, 0x34, 0xc8, 0x25, 0xa7,
+		})},
+		{"SHA-512", katHash(bcrypt.SHA512_ALGORITHM, []byte{
+			0xdd, 0xaf, 0x35, 0xa1, 0x93, 0x61, 0x7a, 0xba, 0xcc, 0x41, 0x73, 0x49, 0xae, 0x20, 0x41, 0x31,
+			0x12, 0xe6, 0xfa, 0x4e, 0x89, 0xa9, 0x7e, 0xa2, 0x0a, 0x9e, 0xee, 0xe6, 0x4b, 0x55, 0xd3, 0x9a,
+			0x21, 0x92, 0x99, 0x2a, 0x27, 0x4f, 0xc1, 0xa8, 0x36, 0xba, 0x3c, 0x23, 0xa3, 0xfe, 0xeb, 0xbd,
+			0x45, 0x4d, 0x44, 0x23, 0x64, 0x3c, 0xe8, 0x0e, 0x2a, 0x9a, 0xc9, 0x4f, 0xa5, 0x4c, 0xa4, 0x9f,
+		})},
+		{"HMAC-SHA-256", katHMAC},
+		{"ECDSA", katECDSA},
+		{"RSA", katRSA},
+	}
+	results := make([]TestResult, len(tests))
+	for i, tt := range tests {
+		results[i] = TestResult{tt.name, runKAT(tt.fn)}
+	}
+	return results
+}
+
+// runKAT runs fn and converts any panic into an error,
+// as many of the functions under test panic on failure.
+func runKAT(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg := "cng: known-answer test panicked"
+			switch r := r.(type) {
+			case error:
+				msg += ": " + r.Error()
+			case string:
+				msg += ": " + r
+			}
+			err = errors.New(msg)
+		}
+	}()
+	return fn()
+}
+
+var errKATMismatch = errors.New("cng: known-answer test output mismatch")
+
+func katAES() error {
+	// FIPS 197, Appendix C.1.
+	key := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	pt := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	want := []byte{0x69, 0xc4, 0xe0, 0xd8, 0x6a, 0x7b, 0x04, 0x30, 0xd8, 0xcd, 0xb7, 0x80, 0x70, 0xb4, 0xc5, 0x5a}
+	c, err := NewAESCipher(key)
+	if err != nil {
+		return err
+	}
+	ct := make([]byte, aesBlockSize)
+	c.Encrypt(ct, pt)
+	if string(ct) != string(want) {
+		return errKATMismatch
+	}
+	c.Decrypt(ct, ct)
+	if string(ct) != string(pt) {
+		return errKATMismatch
+	}
+	return nil
+}
+
+func katAESGCM() error {
+	// Test Case 2 from "The Galois/Counter Mode of Operation (GCM)", McGrew & Viega.
+	key := make([]byte, 16)
+	nonce := make([]byte, gcmStandardNonceSize)
+	pt := make([]byte, 16)
+	want := []byte{
+		0x03, 0x88, 0xda, 0xce, 0x60, 0xb6, 0xa3, 0x92, 0xf3, 0x28, 0xc2, 0xb9, 0x71, 0xb2, 0xfe, 0x78,
+		0xab, 0x6e, 0x47, 0xd4, 0x2c, 0xec, 0x13, 0xbd, 0xf5, 0x3a, 0x67, 0xb2, 0x12, 0x57, 0xbd, 0xdf,
+	}
+	c, err := NewAESCipher(key)
+	if err != nil {
+		return err
+	}
+	g, err := cipher.NewGCM(c)
+	if err != nil {
+		return err
+	}
+	ct := g.Seal(nil, nonce, pt, nil)
+	if string(ct) != string(want) {
+		return errKATMismatch
+	}
+	out, err := g.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return err
+	}
+	if string(out) != string(pt) {
+		return errKATMismatch
+	}
+	return nil
+}
+
+func katHash(id string, want []byte) func() error {
+	return func() error {
+		sum := make([]byte, len(want))
+		if err := hashOneShot(id, []byte("abc"), sum); err != nil {
+			return err
+		}
+		if string(sum) != string(want) {
+			return errKATMismatch
+		}
+		return nil
+	}
+}
+
+func katHMAC() error {
+	// RFC 4231, Test Case 2.
+	want := []byte{
+		0x5b, 0xdc, 0xc1, 0x46, 0xbf, 0x60, 0x75, 0x4e, 0x6a, 0x04, 0x24, 0x26, 0x08, 0x95, 0x75, 0xc7,
+		0x5a, 0x00, 0x3f, 0x08, 0x9d, 0x27, 0x39, 0x83, 0x9d, 0xec, 0x58, 0xb9, 0x64, 0xec, 0x38, 0x43,
+	}
+	h, err := newHMACByID(bcrypt.SHA256_ALGORITHM, []byte("Jefe"))
+	if err != nil {
+		return err
+	}
+	h.Write([]byte("what do ya want for nothing?"))
+	if string(h.Sum(nil)) != string(want) {
+		return errKATMismatch
+	}
+	return nil
+}
+
+var (
+	katECDSAX = []byte{
+		0x3b, 0x81, 0x8e, 0xb4, 0x6c, 0x7f, 0x22, 0x3e, 0x5a, 0x42, 0xf8, 0x0f, 0x89, 0xb4, 0xb0, 0x69,
+		0xfd, 0x8f, 0x37, 0x1a, 0xc1, 0x87, 0xd2, 0xa7, 0x36, 0xa9, 0x06, 0x36, 0x4f, 0x4a, 0x65, 0x00,
+	}
+	katECDSAY = []byte{
+		0x53, 0x2f, 0xe5, 0xfe, 0x60, 0x8b, 0x73, 0x07, 0x89, 0x92, 0x36, 0x7f, 0xb0, 0xce, 0xb9, 0x23,
+		0x69, 0xf9, 0x51, 0x33, 0x8c, 0x90, 0x87, 0x18, 0x9d, 0x18, 0x21, 0x99, 0x76, 0x90, 0x9b, 0x0b,
+	}
+	katECDSAR = []byte{
+		0x8c, 0x2d, 0xb6, 0xa6, 0x06, 0x51, 0xa4, 0x37, 0x67, 0x37, 0xc3, 0x47, 0x46, 0xe4, 0x5f, 0x01,
+		0xc5, 0xb3, 0x1a, 0x9c, 0xb7, 0xe2, 0xe5, 0xf9, 0xd8, 0x8a, 0x55, 0x36, 0x87, 0x71, 0x25, 0xa9,
+	}
+	katECDSAS = []byte{
+		0x0e, 0xc2, 0x58, 0xe4, 0x2e, 0x60, 0x96, 0x7b, 0x95, 0x5e, 0x83, 0x18, 0x94, 0x34, 0x31, 0x13,
+		0xb2, 0xe8, 0x61, 0x13, 0x9a, 0x7d, 0x7c, 0x19, 0xd6, 0x25, 0x43, 0x0b, 0x08, 0x03, 0x11, 0x98,
+	}
+)
+
+// katDigest is SHA-256("abc").
+var katDigest = []byte{
+	0xba, 0x78, 0x16, 0xbf, 0x8f, 0x01, 0xcf, 0xea, 0x41, 0x41, 0x40, 0xde, 0x5d, 0xae, 0x22, 0x23,
+	0xb0, 0x03, 0x61, 0xa3, 0x96, 0x17, 0x7a, 0x9c, 0xb4, 0x10, 0xff, 0x61, 0xf2, 0x00, 0x15, 0xad,
+}
+
+func katECDSA() error {
+	// digest is modified below, so it must not alias katDigest.
+	digest := append([]byte(nil), katDigest...)
+	pub, err := NewPublicKeyECDSA("P-256", katECDSAX, katECDSAY)
+	if err != nil {
+		return err
+	}
+	r, s := katECDSAR, katECDSAS
+	if !VerifyECDSA(pub, digest, r, s) {
+		return errors.New("cng: ECDSA known signature failed to verify")
+	}
+	digest[0] ^= 0xff
+	if VerifyECDSA(pub, digest, r, s) {
+		return errors.New("cng: ECDSA signature verified with a modified digest")
+	}
+	// ECDSA signatures are randomized, so signing is tested
+	// with a pairwise consistency check.
+	X, Y, D, err := GenerateKeyECDSA("P-256")
+	if err != nil {
+		return err
+	}
+	priv, err := NewPrivateKeyECDSA("P-256", X, Y, D)
+	if err != nil {
+		return err
+	}
+	pub, err = NewPublicKeyECDSA("P-256", X, Y)
+	if err != nil {
+		return err
+	}
+	if r, s, err = SignECDSA(priv, digest); err != nil {
+		return err
+	}
+	if !VerifyECDSA(pub, digest, r, s) {
+		return errors.New("cng: ECDSA pairwise consistency check failed")
+	}
+	return nil
+}
+
+var (
+	katRSAN = []byte{
+		0xbe, 0xda, 0x5a, 0xeb, 0x11, 0x5f, 0xec, 0x31, 0x4a, 0x1b, 0xff, 0xa4, 0xd9, 0xc7, 0x46, 0xbc,
+		0xc0, 0x30, 0xe4, 0xdd, 0xbc, 0x67, 0x25, 0x07, 0x90, 0x65, 0xce, 0x4f, 0x90, 0x2e, 0x32, 0x2a,
+		0x21, 0x97, 0x1d, 0x80, 0x0b, 0x2a, 0x72, 0x3b, 0x0d, 0xa2, 0x0c, 0xdf, 0xff, 0x52, 0xfb, 0x08,
+		0xe4, 0x23, 0x78, 0x04, 0x9d, 0xb6, 0x37, 0xda, 0xc1, 0x66, 0x26, 0xd6, 0x4e, 0x6f, 0x1b, 0x55,
+		0x26, 0x54, 0x76, 0xe5, 0x95, 0x90, 0x75, 0xb9, 0x18, 0xa4, 0x61, 0x22, 0x39, 0x26, 0x03, 0x2c,
+		0xb8, 0xfd, 0x90, 0xcc, 0xf2, 0x0f, 0x6b, 0x52, 0x63, 0x82, 0x74, 0x9e, 0xdb, 0xf3, 0x6c, 0x2f,
+		0x47, 0x98, 0xaa, 0x31, 0x83, 0x3c, 0x1a, 0x6a, 0xd1, 0xda, 0x25, 0xfb, 0x3e, 0xf2, 0xe4, 0x1b,
+		0xb7, 0xdb, 0xaa, 0x3e, 0x25, 0xfd, 0x2b, 0x4a, 0x37, 0x0c, 0xc4, 0xab, 0x38, 0x21, 0x7d, 0x14,
+		0xfb, 0x8d, 0xef, 0x17, 0x28, 0xdd, 0xca, 0xe2, 0xb6, 0x52, 0x3e, 0xb5, 0x04, 0x10, 0xfc, 0x29,
+		0x66, 0xec, 0xf0, 0x34, 0xe9, 0x6d, 0xc3, 0x60, 0x28, 0x9b, 0xd8, 0x15, 0x0f, 0x51, 0xfc, 0xa0,
+		0x50, 0x5f, 0x77, 0x4e, 0xc6, 0x55, 0xf6, 0x78, 0xa5, 0x18, 0x46, 0x66, 0x13, 0x0d, 0xa2, 0x3e,
+		0xfa, 0x37, 0x6e, 0xe9, 0xa2, 0x6f, 0x56, 0xba, 0x85, 0xc8, 0x0e, 0xd6, 0xf9, 0x0d, 0xd3, 0x3a,
+		0x46, 0x0c, 0xb3, 0xb7, 0x7a, 0xe2, 0xb7, 0xdf, 0x12, 0x36, 0xe5, 0x68, 0x90, 0x52, 0xa9, 0xff,
+		0x37, 0x97, 0xa1, 0xd4, 0x8b, 0x15, 0x64, 0xc7, 0xe9, 0x60, 0x94, 0x32, 0xb2, 0x20, 0x55, 0x2c,
+		0xdc, 0xd9, 0x98, 0x80, 0xb9, 0x23, 0x0f, 0x17, 0x85, 0xf3, 0x2c, 0xf5, 0xca, 0xc1, 0xf6, 0x3c,
+		0x8b, 0x61, 0xc5, 0xa9, 0xcd, 0x39, 0x3c, 0x3e, 0x77, 0x14, 0x4f, 0xec, 0x19, 0x8b, 0xe9, 0x69,
+	}
+	katRSAE = []byte{0x01, 0x00, 0x01}
+	katRSAD = []byte{
+		0x08, 0xf2, 0xd2, 0x5b, 0x7e, 0x38, 0xe4, 0xa9, 0x5a, 0x4e, 0x5e, 0x1a, 0x06, 0x7a, 0x06, 0x52,
+		0x38, 0x4d, 0x5d, 0x40, 0x70, 0xf7, 0xd7, 0x1a, 0x77, 0xdb, 0x46, 0x25, 0x4b, 0x34, 0x81, 0xf6,
+		0xa1, 0x6a, 0x3a, 0xa0, 0x5e, 0x7a, 0xfd, 0x83, 0x26, 0x51, 0xa8, 0x4f, 0xe2, 0x62, 0xab, 0x7c,
+		0x3b, 0x12, 0x2c, 0xab, 0x99, 0x67, 0x74, 0xa3, 0xfa, 0xf8, 0x70, 0x04, 0x11, 0x5b, 0xba, 0x9e,
+		0x2f, 0x4a, 0x10, 0xe4, 0x6a, 0x88, 0x57, 0x9f, 0x62, 0x4d, 0x3c, 0x1e, 0xaf, 0x80, 0xf2, 0xef,
+		0x7d, 0xb6, 0xff, 0x3b, 0x3f, 0x72, 0xed, 0xf7, 0x1d, 0x77, 0xa8, 0x41, 0xd5, 0xe7, 0x4a, 0x75,
+		0xc7, 0x0c, 0xc7, 0x42, 0x18, 0xb8, 0xa5, 0xe1, 0x1c, 0xa3, 0x76, 0xaf, 0x6f, 0xea, 0xa7, 0x3b,
+		0xb8, 0xcf, 0x01, 0xb8, 0x4b, 0x43, 0x5d, 0x17, 0x16, 0xe6, 0x05, 0x87, 0x4f, 0xa8, 0xf0, 0xfa,
+		0x88, 0xf5, 0x39, 0xc6, 0xb1, 0x77, 0xbc, 0x90, 0x85, 0xe9, 0x13, 0x27, 0x3c, 0xd8, 0xae, 0x29,
+		0xd4, 0xb8, 0x0a, 0x94, 0x1d, 0x46, 0xd2, 0xe0, 0xf1, 0x4b, 0x3f, 0x6f, 0x62, 0x16, 0x50, 0x9b,
+		0x81, 0x6f, 0x65, 0x7a, 0xfc, 0x84, 0xb9, 0x14, 0x0d, 0x1c, 0xb4, 0xb2, 0x3b, 0xc7, 0xf3, 0xbf,
+		0xb5, 0xaa, 0xe3, 0x84, 0x79, 0x23, 0x11, 0xac, 0x7c, 0x92, 0xda, 0xca, 0x2f, 0x6f, 0x12, 0x7e,
+		0xb0, 0xe8, 0xf9, 0xfa, 0xc5, 0x64, 0x8f, 0xce, 0x6c, 0x49, 0xe3, 0xc7, 0x92, 0x9c, 0x77, 0xe4,
+		0xad, 0x4e, 0x8a, 0x9d, 0x63, 0xd6, 0x36, 0xd0, 0x64, 0x1c, 0x8d, 0x16, 0x8a, 0xc4, 0x52, 0x79,
+		0x02, 0x2d, 0xe1, 0xe2, 0xa2, 0x32, 0xaa, 0x9e, 0xce, 0x38, 0xc1, 0xcf, 0xba, 0x75, 0x6a, 0x2b,
+		0x3b, 0xe8, 0x9a, 0x4c, 0x7f, 0x03, 0xe3, 0xca, 0x0f, 0xf6, 0x12, 0xe0, 0xbe, 0xa5, 0x02, 0x47,
+	}
+	katRSAP = []byte{
+		0xe8, 0xf7, 0x7d, 0xc6, 0xf8, 0xf1, 0xc3, 0xc5, 0xe6, 0xcd, 0x48, 0x57, 0x6d, 0x53, 0x3a, 0xd3,
+		0x18, 0x59, 0xca, 0x81, 0x43, 0xdc, 0xe3, 0x1b, 0x93, 0x64, 0xeb, 0x87, 0x46, 0x52, 0xc3, 0x6c,
+		0x20, 0xf5, 0xb8, 0x42, 0xdf, 0xe5, 0xf3, 0x22, 0x39, 0x21, 0x96, 0x1b, 0xc8, 0xf6, 0xb0, 0xe0,
+		0x93, 0xee, 0x8a, 0xd8, 0x35, 0x0b, 0xc6, 0xd2, 0x84, 0x9f, 0xaa, 0xb1, 0xb0, 0x26, 0x28, 0xd9,
+		0xdd, 0xa5, 0xd1, 0x59, 0x08, 0x25, 0x25, 0x7e, 0x34, 0xb7, 0x45, 0xea, 0xbf, 0xf0, 0x58, 0x6f,
+		0x32, 0xde, 0x24, 0x15, 0x5e, 0xc9, 0x8d, 0x9d, 0x31, 0x1e, 0x2c, 0xe1, 0x44, 0xb4, 0xb1, 0x7b,
+		0x0c, 0x60, 0x5b, 0x7a, 0x9e, 0x39, 0x19, 0x89, 0xa8, 0x5f, 0x36, 0x0a, 0x66, 0x5f, 0xc1, 0xcd,
+		0x2f, 0x52, 0xdd, 0xf8, 0x47, 0xb2, 0x58, 0x2c, 0x8e, 0x4b, 0x28, 0x82, 0xac, 0xe6, 0xc5, 0x4f,
+	}
+	katRSAQ = []byte{
+		0xd1, 0xb8, 0xf1, 0x09, 0x0c, 0x1a, 0xad, 0xd9, 0xea, 0x08, 0x24, 0xc9, 0x08, 0x2c, 0xf5, 0xd9,
+		0x87, 0xf8, 0xff, 0x64, 0xc3, 0x77, 0x3b, 0x2d, 0xeb, 0xfe, 0x9d, 0x5b, 0x28, 0x7b, 0x7a, 0xa5,
+		0x3f, 0x8a, 0xb7, 0x72, 0x7b, 0xc8, 0x49, 0x5d, 0x7c, 0xbe, 0x42, 0xf6, 0xbb, 0x0b, 0x23, 0x53,
+		0xec, 0x59, 0xed, 0x29, 0x13, 0x1e, 0x43, 0x6c, 0x20, 0xed, 0x20, 0x82, 0x04, 0xce, 0x03, 0x02,
+		0xdb, 0x97, 0xc1, 0x54, 0x59, 0xb8, 0x35, 0x9c, 0xf7, 0x4c, 0x7e, 0x08, 0x0c, 0xab, 0x7a, 0x9b,
+		0xd8, 0x86, 0x1f, 0x6e, 0x4b, 0xd5, 0xa6, 0xd2, 0xf1, 0xb8, 0x89, 0x14, 0x34, 0xc4, 0x3f, 0x8a,
+		0x9a, 0x9f, 0x4e, 0x56, 0x57, 0x1f, 0x60, 0x63, 0x7c, 0xc7, 0x92, 0xa0, 0xe3, 0xcb, 0xa3, 0x48,
+		0x0b, 0x5b, 0x8c, 0x2b, 0x19, 0xf0, 0xcb, 0x8d, 0x85, 0x86, 0x08, 0x64, 0xc8, 0xee, 0xa7, 0xc7,
+	}
+	katRSADp = []byte{
+		0x16, 0x2b, 0xbf, 0x87, 0x99, 0x17, 0x81, 0x17, 0x2b, 0x70, 0xeb, 0xea, 0x8e, 0x17, 0xf6, 0xa8,
+		0x32, 0x50, 0x11, 0x4a, 0x9e, 0x07, 0xbe, 0x81, 0x58, 0x54, 0xa9, 0x69, 0x95, 0x52, 0xb9, 0x10,
+		0x68, 0x4e, 0x9a, 0x3b, 0x9b, 0x0d, 0x4a, 0x47, 0x3e, 0x82, 0xa8, 0xc3, 0x7a, 0x2b, 0xa8, 0x07,
+		0xa7, 0x6c, 0x73, 0x40, 0x42, 0x44, 0x1d, 0xa6, 0xd9, 0x42, 0x4c, 0xbf, 0x5e, 0x51, 0x33, 0x60,
+		0x10, 0x8d, 0x00, 0x50, 0x65, 0xcb, 0x0b, 0x37, 0x68, 0x92, 0xec, 0x8f, 0x7b, 0xb6, 0xc6, 0xe3,
+		0xc6, 0x46, 0x87, 0xce, 0x94, 0xd7, 0xbf, 0xa7, 0x6f, 0x0e, 0x3d, 0x1d, 0x2f, 0x29, 0xb7, 0x1e,
+		0x4b, 0xbe, 0x3b, 0xd6, 0x7e, 0x8a, 0x56, 0x98, 0xd8, 0x32, 0x3f, 0x75, 0x5b, 0xea, 0xcf, 0x09,
+		0xd4, 0xf0, 0x20, 0xf9, 0xc8, 0xf0, 0xfb, 0x1d, 0x3a, 0x69, 0x82, 0xb0, 0x74, 0xfd, 0xfd, 0x57,
+	}
+	katRSADq = []byte{
+		0x57, 0x88, 0x02, 0x0a, 0x0d, 0xa4, 0x40, 0xf5, 0x57, 0xa9, 0x68, 0x79, 0x03, 0x51, 0x88, 0x96,
+		0xef, 0x1a, 0x6c, 0xb5, 0xc8, 0xa3, 0x45, 0xee, 0xe7, 0xb5, 0x90, 0x45, 0x90, 0xc2, 0xe0, 0xb0,
+		0x81, 0xb1, 0xca, 0xd9, 0x1c, 0x72, 0xbe, 0xb4, 0x27, 0x31, 0x5d, 0xcf, 0x8e, 0xc7, 0x9a, 0x4a,
+		0x17, 0xb6, 0x7c, 0xb6, 0x8d, 0x05, 0x2f, 0x8c, 0xbc, 0xbd, 0x5b, 0xb9, 0x08, 0x7a, 0x57, 0x65,
+		0x64, 0xf3, 0x94, 0xbc, 0x38, 0xea, 0x1a, 0x6e, 0x56, 0x04, 0xf6, 0x01, 0x34, 0x10, 0x8a, 0x0e,
+		0xeb, 0xb3, 0x27, 0x01, 0x28, 0xe3, 0x96, 0x4a, 0x2a, 0x9b, 0x23, 0x93, 0x6c, 0x66, 0x90, 0x42,
+		0xb0, 0x40, 0x57, 0x38, 0xd0, 0x0a, 0x07, 0x77, 0xde, 0x87, 0x89, 0x5a, 0x5f, 0x59, 0x09, 0xe0,
+		0x32, 0xe5, 0x55, 0xbe, 0x53, 0x88, 0x5d, 0xdc, 0xc6, 0x62, 0xe7, 0xb3, 0xf0, 0xed, 0x32, 0xd7,
+	}
+	katRSAQinv = []byte{
+		0x03, 0xd3, 0x21, 0x18, 0x88, 0xbb, 0x96, 0x52, 0x60, 0x3e, 0x4c, 0x4f, 0x82, 0x95, 0xfa, 0x7c,
+		0x73, 0x93, 0x74, 0x74, 0x64, 0x6c, 0xba, 0x31, 0xd7, 0x6b, 0xb8, 0x60, 0xe3, 0x74, 0xc5, 0x93,
+		0xac, 0x82, 0x37, 0xde, 0x36, 0x1a, 0xf9, 0x53, 0xe0, 0xcf, 0xd8, 0x34, 0xff, 0x7f, 0x0f, 0xa9,
+		0xc5, 0xd6, 0x80, 0x83, 0x58, 0xbd, 0x72, 0x46, 0xda, 0x6f, 0x8b, 0x00, 0x93, 0xd0, 0x4a, 0x16,
+		0xa8, 0x46, 0x9a, 0x58, 0x6e, 0xd2, 0x7e, 0x92, 0xdd, 0x7e, 0xcf, 0xf4, 0x72, 0x2c, 0x3e, 0x2c,
+		0x33, 0x78, 0x29, 0x12, 0x03, 0x8d, 0x13, 0x66, 0xd4, 0xb5, 0x72, 0x9e, 0x2e, 0xbd, 0x11, 0x6b,
+		0xf1, 0x02, 0xc7, 0x01, 0x2d, 0x5c, 0xca, 0x7f, 0x67, 0x08, 0xb0, 0xfd, 0x59, 0xaf, 0xb7, 0x9a,
+		0x03, 0x12, 0xf9, 0xb0, 0x3f, 0x66, 0x16, 0x2d, 0x13, 0xac, 0xe6, 0x1a, 0x85, 0x47, 0xc6, 0xbc,
+	}
+	katRSASignature = []byte{
+		0x45, 0x3f, 0xa2, 0x2e, 0x50, 0x43, 0x75, 0x96, 0xf4, 0xd5, 0xc0, 0x30, 0x85, 0x0e, 0xc7, 0xcb,
+		0xd5, 0x35, 0x24, 0xfc, 0xdb, 0xa9, 0x1e, 0x05, 0x70, 0x65, 0x24, 0x8f, 0xde, 0x45, 0x77, 0x66,
+		0xef, 0x2d, 0x75, 0x6c, 0x10, 0x85, 0x55, 0x41, 0x2a, 0x48, 0x20, 0x2c, 0x87, 0x29, 0x51, 0x10,
+		0x1f, 0x92, 0x10, 0xd3, 0xb5, 0x5d, 0x3a, 0xbe, 0x77, 0xf5, 0x85, 0xe5, 0x04, 0x10, 0x92, 0x67,
+		0xc7, 0xf8, 0x9d, 0xfb, 0x1a, 0x92, 0xb8, 0xd8, 0x7d, 0x75, 0xbf, 0xf2, 0xee, 0xcc, 0x81, 0xe3,
+		0xec, 0xec, 0xf2, 0xff, 0x84, 0x3e, 0x4c, 0x07, 0xd9, 0x79, 0xcb, 0xb1, 0xe6, 0x79, 0x7d, 0x18,
+		0x6c, 0x10, 0x72, 0xe9, 0x1b, 0xa2, 0xed, 0xaf, 0x0f, 0x44, 0x4b, 0x38, 0x97, 0x35, 0x72, 0x4e,
+		0x2f, 0x57, 0x06, 0xc5, 0xec, 0xb4, 0xba, 0xf3, 0x06, 0xa2, 0x04, 0x5f, 0x07, 0x10, 0x39, 0xfb,
+		0xba, 0xb5, 0x81, 0x11, 0xb7, 0xa1, 0x9e, 0xbc, 0x88, 0x2a, 0x46, 0x17, 0x9e, 0x4b, 0x14, 0x8a,
+		0xd9, 0xe9, 0xde, 0x7a, 0xa4, 0x14, 0x09, 0xed, 0x54, 0x1a, 0x41, 0xe5, 0xa8, 0x4d, 0xa8, 0x7b,
+		0x1e, 0xff, 0x5c, 0xe0, 0x40, 0xfd, 0x3a, 0x14, 0x88, 0x03, 0x36, 0x89, 0x4b, 0xbc, 0x18, 0x4f,
+		0x3c, 0x40, 0xef, 0x9b, 0xfa, 0xb1, 0xe0, 0x18, 0x81, 0xcf, 0x05, 0xf8, 0xf2, 0x7f, 0x39, 0x78,
+		0x18, 0x2b, 0xf5, 0x43, 0x74, 0x9b, 0x0d, 0x78, 0xa7, 0xa4, 0xda, 0xde, 0x9f, 0xb3, 0x1f, 0xdc,
+		0xa8, 0xf7, 0x25, 0x5d, 0x89, 0x2c, 0x29, 0x44, 0x32, 0x57, 0x6d, 0x80, 0x3e, 0xb4, 0xef, 0xa4,
+		0x05, 0x0b, 0xbc, 0x10, 0x16, 0x14, 0x73, 0xa5, 0xa6, 0x16, 0x20, 0xa9, 0x54, 0x65, 0x3d, 0x02,
+		0x34, 0x59, 0x75, 0x45, 0x43, 0x4d, 0x72, 0x61, 0x84, 0x22, 0xf0, 0x20, 0xcb, 0x0d, 0x20, 0x73,
+	}
+)
+
+func katRSA() error {
+	N, E := katRSAN, katRSAE
+	priv, err := NewPrivateKeyRSA(N, E, katRSAD,
+		katRSAP, katRSAQ,
+		katRSADp, katRSADq, katRSAQinv)
+	if err != nil {
+		return err
+	}
+	pub, err := NewPublicKeyRSA(N, E)
+	if err != nil {
+		return err
+	}
+	// RSA PKCS #1 v1.5 signatures are deterministic.
+	digest := katDigest
+	want := katRSASignature
+	sig, err := SignRSAPKCS1v15(priv, crypto.SHA256, digest)
+	if err != nil {
+		return err
+	}
+	if string(sig) != string(want) {
+		return errKATMismatch
+	}
+	return VerifyRSAPKCS1v15(pub, crypto.SHA256, digest, sig)
+}
@@ -0,0 +1,25 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestKnownAnswerTests(t *testing.T) {
+	results := cng.KnownAnswerTests()
+	if len(results) == 0 {
+		t.Fatal("no known-answer tests were run")
+	}
+	for _, r := range results {
+		if !r.Passed() {
+			t.Errorf("%s: %v", r.Algorithm, r.Err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// keyFingerprintSize is the size of the HMAC-SHA256 fingerprints
+// that KeyHandleCache uses in place of the keys themselves.
+const keyFingerprintSize = 32
+
+// KeyHandleCache caches AES-GCM AEADs by key, so that constructing an
+// AEAD again for a recently used key reuses its imported CNG key handle
+// instead of importing the key again. It holds at most a fixed number of
+// keys, evicting the least recently used one when full.
+//
+// The keys are not retained: entries are indexed by an HMAC-SHA256 of
+// the key under a random secret generated for each cache, and an evicted
+// entry's fingerprint is zeroed. The key handle itself is destroyed once
+// the evicted AEAD is no longer referenced, so AEADs returned earlier
+// remain usable.
+//
+// A KeyHandleCache is safe for concurrent use by multiple goroutines,
+// and so are the AEADs it returns.
+type KeyHandleCache struct {
+	mu      sync.Mutex
+	max     int
+	mac     *hashX // HMAC-SHA256 keyed with the cache secret
+	entries map[[keyFingerprintSize]byte]*keyCacheEntry
+	clock   uint64 // incremented on each use of an entry
+}
+
+type keyCacheEntry struct {
+	id      [keyFingerprintSize]byte
+	aead    *aesGCM
+	lastUse uint64
+}
+
+// NewKeyHandleCache returns an empty KeyHandleCache holding at most
+// maxEntries keys.
+func NewKeyHandleCache(maxEntries int) (*KeyHandleCache, error) {
+	if maxEntries <= 0 {
+		return nil, errors.New("cng: key handle cache size must be positive")
+	}
+	secret := make([]byte, keyFingerprintSize)
+	defer Wipe(secret)
+	// Always use CNG, as the fingerprints must not be predictable.
+	if _, err := io.ReadFull(RandReader, secret); err != nil {
+		return nil, err
+	}
+	mac, err := newHMACByID(bcrypt.SHA256_ALGORITHM, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyHandleCache{
+		max:     maxEntries,
+		mac:     mac,
+		entries: make(map[[keyFingerprintSize]byte]*keyCacheEntry),
+	}, nil
+}
+
+// NewGCM returns an AES-GCM AEAD with the standard nonce and tag sizes
+// for key, reusing the cached AEAD if key is already in the cache.
+func (c *KeyHandleCache) NewGCM(key []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("crypto/aes: invalid key size " + itoa(len(key)))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mac == nil {
+		return nil, ErrClosed
+	}
+	var id [keyFingerprintSize]byte
+	c.mac.Reset()
+	c.mac.Write(key)
+	c.mac.Sum(id[:0])
+	c.clock++
+	if e, ok := c.entries[id]; ok {
+		e.lastUse = c.clock
+		return e.aead, nil
+	}
+	g, err := newGCM(key, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.entries) >= c.max {
+		c.evict(c.leastRecentlyUsed())
+	}
+	c.entries[id] = &keyCacheEntry{id: id, aead: g, lastUse: c.clock}
+	return g, nil
+}
+
+// Len returns the number of keys in the cache.
+func (c *KeyHandleCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Close evicts all the keys and releases the cache secret.
+// NewGCM fails with ErrClosed afterwards.
+func (c *KeyHandleCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mac == nil {
+		return ErrClosed
+	}
+	for _, e := range c.entries {
+		c.evict(e)
+	}
+	c.mac.Close()
+	c.mac = nil
+	return nil
+}
+
+// leastRecentlyUsed returns the entry that was used the longest ago.
+// Caches are small, so a linear scan is cheaper than maintaining a list.
+func (c *KeyHandleCache) leastRecentlyUsed() *keyCacheEntry {
+	var lru *keyCacheEntry
+	for _, e := range c.entries {
+		if lru == nil || e.lastUse < lru.lastUse {
+			lru = e
+		}
+	}
+	return lru
+}
+
+func (c *KeyHandleCache) evict(e *keyCacheEntry) {
+	delete(c.entries, e.id)
+	Wipe(e.id[:])
+	e.aead = nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestKeyHandleCache(t *testing.T) {
+	c, err := cng.NewKeyHandleCache(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	key1 := bytes.Repeat([]byte{1}, 16)
+	key2 := bytes.Repeat([]byte{2}, 24)
+	key3 := bytes.Repeat([]byte{3}, 32)
+
+	a1, err := c.NewGCM(key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.NewGCM(key1); again != a1 {
+		t.Error("same key did not reuse the cached AEAD")
+	}
+	a2, err := c.NewGCM(key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a2 == a1 {
+		t.Error("different keys share an AEAD")
+	}
+
+	// key2 is now the most recently used, so key3 evicts key1.
+	if _, err := c.NewGCM(key3); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+	if again, _ := c.NewGCM(key2); again != a2 {
+		t.Error("key2 was evicted instead of key1")
+	}
+	fresh, err := c.NewGCM(key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh == a1 {
+		t.Error("key1 was not evicted")
+	}
+
+	// The evicted AEAD keeps working and matches a fresh one for its key.
+	nonce := make([]byte, a1.NonceSize())
+	sealed := a1.Seal(nil, nonce, []byte("plaintext"), nil)
+	if got, err := fresh.Open(nil, nonce, sealed, nil); err != nil || string(got) != "plaintext" {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+
+	if _, err := c.NewGCM(make([]byte, 15)); err == nil {
+		t.Error("expected error for invalid key size")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.NewGCM(key1); err != cng.ErrClosed {
+		t.Errorf("NewGCM after Close: err = %v, want %v", err, cng.ErrClosed)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() after Close = %d, want 0", n)
+	}
+}
+
+func TestKeyHandleCacheSize(t *testing.T) {
+	if _, err := cng.NewKeyHandleCache(0); err == nil {
+		t.Error("expected error for empty cache")
+	}
+}
+
+// BenchmarkNewGCMRepeatedKey compares importing the same key for every
+// AEAD with reusing the handle through a KeyHandleCache.
+func BenchmarkNewGCMRepeatedKey(b *testing.B) {
+	key := bytes.Repeat([]byte{1}, 32)
+	b.Run("Import", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cng.NewGCMFromKey(key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Cache", func(b *testing.B) {
+		c, err := cng.NewKeyHandleCache(16)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer c.Close()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.NewGCM(key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
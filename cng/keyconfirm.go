@@ -0,0 +1,46 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/subtle"
+)
+
+// KeyConfirmationMACData assembles the MacData of SP 800-56A Rev. 3,
+// Section 5.9.1: messageString || idP || idR || ephemP || ephemR || text,
+// where messageString is one of "KC_1_U", "KC_1_V", "KC_2_U" or "KC_2_V"
+// and P and R are the provider and recipient of the confirmation.
+func KeyConfirmationMACData(messageString string, idP, idR, ephemP, ephemR, text []byte) []byte {
+	data := make([]byte, 0, len(messageString)+len(idP)+len(idR)+len(ephemP)+len(ephemR)+len(text))
+	data = append(data, messageString...)
+	data = append(data, idP...)
+	data = append(data, idR...)
+	data = append(data, ephemP...)
+	data = append(data, ephemR...)
+	return append(data, text...)
+}
+
+// ComputeKeyConfirmation returns the SP 800-56A key confirmation tag,
+// the HMAC of transcript keyed with macKey, where macKey is the MAC key
+// derived from the shared secret and transcript is the MacData, for example
+// as returned by KeyConfirmationMACData. hashID is the CNG hash algorithm,
+// such as "SHA256". It returns nil if hashID is not supported.
+func ComputeKeyConfirmation(macKey, transcript []byte, hashID string) []byte {
+	h, err := newHMACByID(hashID, macKey)
+	if err != nil {
+		return nil
+	}
+	h.Write(transcript)
+	return h.Sum(nil)
+}
+
+// VerifyKeyConfirmation reports whether tag is the key confirmation tag
+// of transcript under macKey. The tags are compared in constant time.
+func VerifyKeyConfirmation(macKey, transcript []byte, hashID string, tag []byte) bool {
+	want := ComputeKeyConfirmation(macKey, transcript, hashID)
+	return want != nil && subtle.ConstantTimeCompare(want, tag) == 1
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestKeyConfirmation(t *testing.T) {
+	alice, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicePub, err := alice.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPub, err := bob.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdf := cng.KDFSpec{Name: cng.KDFHash, Hash: crypto.SHA256, Prepend: []byte("mac key")}
+	_, aliceKey, err := cng.ECDHWithKDF(alice, bobPub, kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bobKey, err := cng.ECDHWithKDF(bob, alicePub, kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice (U) provides the confirmation to Bob (V), who recomputes it.
+	idU, idV := []byte("alice"), []byte("bob")
+	aliceData := cng.KeyConfirmationMACData("KC_1_U", idU, idV, alicePub.Bytes(), bobPub.Bytes(), nil)
+	bobData := cng.KeyConfirmationMACData("KC_1_U", idU, idV, alicePub.Bytes(), bobPub.Bytes(), nil)
+	tag := cng.ComputeKeyConfirmation(aliceKey, aliceData, "SHA256")
+	if len(tag) != 32 {
+		t.Fatalf("tag length = %d, want 32", len(tag))
+	}
+	if want := cng.ComputeKeyConfirmation(bobKey, bobData, "SHA256"); !bytes.Equal(tag, want) {
+		t.Errorf("parties computed different tags:\n%x\n%x", tag, want)
+	}
+	if !cng.VerifyKeyConfirmation(bobKey, bobData, "SHA256", tag) {
+		t.Error("VerifyKeyConfirmation rejected a valid tag")
+	}
+
+	// The tag is bound to the message string, the identities and the key.
+	swapped := cng.KeyConfirmationMACData("KC_1_V", idV, idU, bobPub.Bytes(), alicePub.Bytes(), nil)
+	if cng.VerifyKeyConfirmation(bobKey, swapped, "SHA256", tag) {
+		t.Error("VerifyKeyConfirmation accepted a tag for the other direction")
+	}
+	if cng.VerifyKeyConfirmation([]byte("wrong key"), bobData, "SHA256", tag) {
+		t.Error("VerifyKeyConfirmation accepted a tag under the wrong key")
+	}
+	if cng.VerifyKeyConfirmation(bobKey, bobData, "SHA256", tag[:16]) {
+		t.Error("VerifyKeyConfirmation accepted a truncated tag")
+	}
+	if cng.ComputeKeyConfirmation(bobKey, bobData, "NOTAHASH") != nil {
+		t.Error("expected nil tag for an unsupported hash")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/subtle"
+	"runtime"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// PublicKeyEqual reports whether a and b are the same public key.
+// a and b must be *PublicKeyECDH, *PublicKeyECDSA or *PublicKeyRSA;
+// keys of different types, or of any other type, are never equal.
+//
+// The keys are compared through their canonical encodings, which
+// include the curve or modulus size, in constant time.
+func PublicKeyEqual(a, b interface{}) bool {
+	ea, ok := publicKeyEncoding(a)
+	if !ok {
+		return false
+	}
+	eb, ok := publicKeyEncoding(b)
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare(ea, eb) == 1
+}
+
+// publicKeyEncoding returns the canonical encoding of k, prefixed
+// with a byte identifying the key type.
+func publicKeyEncoding(k interface{}) ([]byte, bool) {
+	var (
+		kind  byte
+		hkey  bcrypt.KEY_HANDLE
+		magic string
+	)
+	switch k := k.(type) {
+	case *PublicKeyECDH:
+		if k == nil {
+			return nil, false
+		}
+		return append([]byte{1}, k.Bytes()...), true
+	case *PublicKeyECDSA:
+		if k == nil {
+			return nil, false
+		}
+		kind, hkey, magic = 2, k.hkey, bcrypt.ECCPUBLIC_BLOB
+		defer runtime.KeepAlive(k)
+	case *PublicKeyRSA:
+		if k == nil {
+			return nil, false
+		}
+		kind, hkey, magic = 3, k.hkey, bcrypt.RSAPUBLIC_KEY_BLOB
+		defer runtime.KeepAlive(k)
+	default:
+		return nil, false
+	}
+	blob, err := exportKey(hkey, magic)
+	if err != nil {
+		return nil, false
+	}
+	return append([]byte{kind}, blob...), true
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestPublicKeyEqualECDH(t *testing.T) {
+	priv1, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv2, _, err := cng.GenerateKeyECDH("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub1, err := priv1.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, err := priv2.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub1Copy, err := cng.NewPublicKeyECDH("P-256", pub1.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.PublicKeyEqual(pub1, pub1Copy) {
+		t.Error("equal keys reported as different")
+	}
+	if cng.PublicKeyEqual(pub1, pub2) {
+		t.Error("different keys reported as equal")
+	}
+}
+
+func TestPublicKeyEqualECDSA(t *testing.T) {
+	x1, y1, _, err := cng.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x2, y2, _, err := cng.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := cng.NewPublicKeyECDSA("P-256", x1, y1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aCopy, err := cng.NewPublicKeyECDSA("P-256", x1, y1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := cng.NewPublicKeyECDSA("P-256", x2, y2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.PublicKeyEqual(a, aCopy) {
+		t.Error("equal keys reported as different")
+	}
+	if cng.PublicKeyEqual(a, b) {
+		t.Error("different keys reported as equal")
+	}
+}
+
+func TestPublicKeyEqualRSA(t *testing.T) {
+	_, a := newRSAKey(t, 2048)
+	_, b := newRSAKey(t, 2048)
+	if !cng.PublicKeyEqual(a, a) {
+		t.Error("equal keys reported as different")
+	}
+	if cng.PublicKeyEqual(a, b) {
+		t.Error("different keys reported as equal")
+	}
+}
+
+func TestPublicKeyEqualMixedTypes(t *testing.T) {
+	_, rsaPub := newRSAKey(t, 2048)
+	x, y, _, err := cng.GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaPub, err := cng.NewPublicKeyECDSA("P-256", x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cng.PublicKeyEqual(rsaPub, ecdsaPub) {
+		t.Error("keys of different types reported as equal")
+	}
+	if cng.PublicKeyEqual(rsaPub, nil) || cng.PublicKeyEqual(nil, nil) {
+		t.Error("nil keys reported as equal")
+	}
+	if cng.PublicKeyEqual((*cng.PublicKeyRSA)(nil), (*cng.PublicKeyRSA)(nil)) {
+		t.Error("typed nil keys reported as equal")
+	}
+}
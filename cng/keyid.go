@@ -0,0 +1,38 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import "github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+
+// keyIDLabel is the HMAC key used by KeyID, separating key IDs
+// from any other use of HMAC over the same key material.
+const keyIDLabel = "go-crypto-winnative key id"
+
+// keyIDSize is the number of HMAC bytes kept in a key ID.
+const keyIDSize = 16
+
+// KeyID returns a stable identifier for key, suitable for cache keys
+// and logs. It is the hex encoding of the first 128 bits of
+// HMAC-SHA256(keyIDLabel, key), so it doesn't reveal the key.
+//
+// KeyID is only meant for high-entropy keys: the ID of a password
+// or other guessable secret allows checking guesses offline.
+func KeyID(key []byte) string {
+	h, err := newHMACByID(bcrypt.SHA256_ALGORITHM, []byte(keyIDLabel))
+	if err != nil {
+		panic(err)
+	}
+	defer h.Close()
+	h.Write(key)
+	const digits = "0123456789abcdef"
+	var id [2 * keyIDSize]byte
+	for i, b := range h.Sum(nil)[:keyIDSize] {
+		id[2*i] = digits[b>>4]
+		id[2*i+1] = digits[b&0x0f]
+	}
+	return string(id[:])
+}
@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestKeyID(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	id := cng.KeyID(key)
+	if len(id) != 32 {
+		t.Errorf("len(KeyID) = %d, want 32", len(id))
+	}
+	if again := cng.KeyID(key); again != id {
+		t.Errorf("KeyID is not stable: %s, %s", id, again)
+	}
+	mac := hmac.New(sha256.New, []byte("go-crypto-winnative key id"))
+	mac.Write(key)
+	if want := hex.EncodeToString(mac.Sum(nil)[:16]); id != want {
+		t.Errorf("KeyID = %s, want %s", id, want)
+	}
+
+	other := append([]byte(nil), key...)
+	other[len(other)-1] ^= 1
+	if cng.KeyID(other) == id {
+		t.Error("different keys have the same ID")
+	}
+	if cng.KeyID(key[:16]) == id {
+		t.Error("truncated key has the same ID")
+	}
+	if cng.KeyID(nil) == cng.KeyID([]byte{0}) {
+		t.Error("empty key has the same ID as a zero byte")
+	}
+}
@@ -38,6 +38,21 @@ func exportECCKey(hkey bcrypt.KEY_HANDLE, private bool) (bcrypt.ECCKEY_BLOB, []b
 	return hdr, blob[sizeOfECCBlobHeader:], nil
 }
 
+// generateKeyPair generates and finalizes a key pair of the given size.
+func generateKeyPair(h bcrypt.ALG_HANDLE, bits uint32) (bcrypt.KEY_HANDLE, error) {
+	defer releaseOp(acquireOp())
+	var hkey bcrypt.KEY_HANDLE
+	if err := bcrypt.GenerateKeyPair(h, &hkey, bits, 0); err != nil {
+		return 0, err
+	}
+	// The key cannot be used until BCryptFinalizeKeyPair has been called.
+	if err := bcrypt.FinalizeKeyPair(hkey, 0); err != nil {
+		bcrypt.DestroyKey(hkey)
+		return 0, err
+	}
+	return hkey, nil
+}
+
 // exportRSAKey exports hkey into a bcrypt.RSAKEY_BLOB header and data.
 func exportRSAKey(hkey bcrypt.KEY_HANDLE, private bool) (bcrypt.RSAKEY_BLOB, []byte, error) {
 	var magic string
@@ -73,20 +88,46 @@ func exportKeyData(hkey bcrypt.KEY_HANDLE) (bcrypt.KEY_DATA_BLOB_HEADER, []byte,
 	return hdr, blob[sizeOfKeyDataBlobHeader : sizeOfKeyDataBlobHeader+hdr.Length], nil
 }
 
+// importKeyData imports key as a BCRYPT_KEY_DATA_BLOB using the algorithm h.
+func importKeyData(h bcrypt.ALG_HANDLE, key []byte) (bcrypt.KEY_HANDLE, error) {
+	blob := make([]byte, sizeOfKeyDataBlobHeader+uint32(len(key)))
+	defer Wipe(blob)
+	hdr := (*bcrypt.KEY_DATA_BLOB_HEADER)(unsafe.Pointer(&blob[0]))
+	hdr.Magic = bcrypt.KEY_DATA_BLOB_MAGIC
+	hdr.Version = bcrypt.KEY_DATA_BLOB_VERSION1
+	hdr.Length = uint32(len(key))
+	copy(blob[sizeOfKeyDataBlobHeader:], key)
+	var hkey bcrypt.KEY_HANDLE
+	err := bcrypt.ImportKey(h, 0, utf16PtrFromString(bcrypt.KEY_DATA_BLOB), &hkey, nil, blob, 0)
+	if err != nil {
+		return 0, err
+	}
+	return hkey, nil
+}
+
 // exportKey exports hkey to a memory blob.
 func exportKey(hkey bcrypt.KEY_HANDLE, magic string) ([]byte, error) {
+	return exportKeyWith(hkey, 0, magic)
+}
+
+// exportKeyWith exports hkey to a memory blob encrypted with hExportKey,
+// or unencrypted if hExportKey is zero.
+func exportKeyWith(hkey, hExportKey bcrypt.KEY_HANDLE, magic string) ([]byte, error) {
+	if hkey == 0 {
+		return nil, ErrClosed
+	}
 	psBlobType := utf16PtrFromString(magic)
 	var size uint32
-	err := bcrypt.ExportKey(hkey, 0, psBlobType, nil, &size, 0)
+	err := bcrypt.ExportKey(hkey, hExportKey, psBlobType, nil, &size, 0)
 	if err != nil {
 		return nil, err
 	}
 	blob := make([]byte, size)
-	err = bcrypt.ExportKey(hkey, 0, psBlobType, blob, &size, 0)
+	err = bcrypt.ExportKey(hkey, hExportKey, psBlobType, blob, &size, 0)
 	if err != nil {
 		return nil, err
 	}
-	return blob, err
+	return blob[:size], err
 }
 
 // importECCKey imports a public/private key pair from the given parameters.
@@ -101,6 +142,8 @@ func importECCKey(h bcrypt.ALG_HANDLE, id string, bits uint32, X, Y, D BigInt) (
 		kind = bcrypt.ECCPUBLIC_BLOB
 	} else {
 		kind = bcrypt.ECCPRIVATE_BLOB
+		// The blob contains a copy of the private key.
+		defer Wipe(blob)
 	}
 	var hkey bcrypt.KEY_HANDLE
 	err = bcrypt.ImportKeyPair(h, 0, utf16PtrFromString(kind), &hkey, blob, 0)
@@ -114,7 +157,7 @@ func importECCKey(h bcrypt.ALG_HANDLE, id string, bits uint32, X, Y, D BigInt) (
 func encodeECCKey(id string, bits uint32, X, Y, D BigInt) ([]byte, error) {
 	var hdr bcrypt.ECCKEY_BLOB
 	hdr.KeySize = (bits + 7) / 8
-	if len(X) > int(hdr.KeySize) || len(Y) > int(hdr.KeySize) || len(D) > int(hdr.KeySize) {
+	if len(X.normalize()) > int(hdr.KeySize) || len(Y.normalize()) > int(hdr.KeySize) || len(D.normalize()) > int(hdr.KeySize) {
 		return nil, errors.New("cng: invalid parameters")
 	}
 	switch id {
@@ -166,12 +209,14 @@ func encodeBigInt(data []byte, ints []sizedBigInt) error {
 		if v.b == nil {
 			return nil
 		}
-		// b might be shorter than size if the original big number contained leading zeros.
-		leadingZeros := int(v.size) - len(v.b)
+		// b might be shorter than size if the original big number contained leading zeros,
+		// or longer if it was encoded with a wider fixed width.
+		b := v.b.normalize()
+		leadingZeros := int(v.size) - len(b)
 		if leadingZeros < 0 {
 			return errors.New("cng: invalid parameters")
 		}
-		copy(data[leadingZeros:], v.b)
+		copy(data[leadingZeros:], b)
 		data = data[v.size:]
 	}
 	return nil
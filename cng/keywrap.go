@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+	"runtime"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// WrapAESKey wraps the AES key using the AES key-encryption key kek,
+// as specified in RFC 3394.
+func WrapAESKey(kek, key []byte) ([]byte, error) {
+	kekh, err := newCipherHandle(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_ECB, kek)
+	if err != nil {
+		return nil, err
+	}
+	defer bcrypt.DestroyKey(kekh)
+	kh, err := newCipherHandle(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_ECB, key)
+	if err != nil {
+		return nil, err
+	}
+	defer bcrypt.DestroyKey(kh)
+	return exportKeyWith(kh, kekh, bcrypt.AES_WRAP_KEY_BLOB)
+}
+
+// wrappedAESCipher is an AES block cipher whose key was imported wrapped.
+// Unlike aesCipher it does not hold the plaintext key, so it can't be
+// used to derive the other chaining modes.
+type wrappedAESCipher struct {
+	c aesCipher
+}
+
+// ImportWrappedAESKey unwraps the RFC 3394 wrapped AES key using the AES
+// key-encryption key kek and returns it as a cipher.Block. The key is
+// unwrapped by CNG, so the plaintext key never appears in Go memory,
+// and this package provides no way to export it.
+//
+// The returned cipher.Block only supports single-block operations:
+// it can't be passed to the GCM and CBC constructors of this package.
+func ImportWrappedAESKey(kek, wrapped []byte) (cipher.Block, error) {
+	switch len(wrapped) {
+	case 16 + 8, 24 + 8, 32 + 8:
+	default:
+		return nil, errors.New("cng: invalid wrapped AES key length")
+	}
+	kekh, err := newCipherHandle(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_ECB, kek)
+	if err != nil {
+		return nil, err
+	}
+	defer bcrypt.DestroyKey(kekh)
+	h, err := loadCipher(bcrypt.AES_ALGORITHM, bcrypt.CHAIN_MODE_ECB)
+	if err != nil {
+		return nil, err
+	}
+	var kh bcrypt.KEY_HANDLE
+	err = bcrypt.ImportKey(h.handle, kekh, utf16PtrFromString(bcrypt.AES_WRAP_KEY_BLOB), &kh, nil, wrapped, 0)
+	if err != nil {
+		return nil, err
+	}
+	w := &wrappedAESCipher{aesCipher{kh: kh}}
+	runtime.SetFinalizer(w, (*wrappedAESCipher).finalize)
+	return w, nil
+}
+
+func (w *wrappedAESCipher) finalize() {
+	bcrypt.DestroyKey(w.c.kh)
+}
+
+func (w *wrappedAESCipher) BlockSize() int { return aesBlockSize }
+
+func (w *wrappedAESCipher) Encrypt(dst, src []byte) {
+	w.c.Encrypt(dst, src)
+	runtime.KeepAlive(w)
+}
+
+func (w *wrappedAESCipher) Decrypt(dst, src []byte) {
+	w.c.Decrypt(dst, src)
+	runtime.KeepAlive(w)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// TestWrapAESKey uses the test vector from RFC 3394, Section 4.1.
+func TestWrapAESKey(t *testing.T) {
+	kek := hexDecode(t, "000102030405060708090a0b0c0d0e0f")
+	key := hexDecode(t, "00112233445566778899aabbccddeeff")
+	want := hexDecode(t, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5")
+	wrapped, err := cng.WrapAESKey(kek, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wrapped, want) {
+		t.Errorf("WrapAESKey() = %x, want %x", wrapped, want)
+	}
+}
+
+func TestImportWrappedAESKey(t *testing.T) {
+	kek := hexDecode(t, "000102030405060708090a0b0c0d0e0f")
+	key := hexDecode(t, "00112233445566778899aabbccddeeff")
+	wrapped, err := cng.WrapAESKey(kek, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := cng.ImportWrappedAESKey(kek, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := cng.NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := []byte("0123456789abcdef")
+	got := make([]byte, 16)
+	want := make([]byte, 16)
+	block.Encrypt(got, src)
+	ref.Encrypt(want, src)
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encrypt() = %x, want %x", got, want)
+	}
+	block.Decrypt(got, got)
+	if !bytes.Equal(got, src) {
+		t.Errorf("Decrypt() = %x, want %x", got, src)
+	}
+
+	wrongKEK := hexDecode(t, "0f0e0d0c0b0a09080706050403020100")
+	if _, err := cng.ImportWrappedAESKey(wrongKEK, wrapped); err == nil {
+		t.Error("expected error unwrapping with the wrong KEK")
+	}
+	if _, err := cng.ImportWrappedAESKey(kek, wrapped[1:]); err == nil {
+		t.Error("expected error for truncated wrapped key")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"sync/atomic"
+)
+
+type opLimiter struct {
+	sem chan struct{}
+}
+
+var opLimiterValue atomic.Value // opLimiter
+
+// An Option configures the package. Options are applied with Configure.
+type Option func()
+
+// Configure applies opts in order. It is meant to be called at program
+// start, before the operations it affects run.
+func Configure(opts ...Option) {
+	for _, opt := range opts {
+		opt()
+	}
+}
+
+// WithMaxConcurrency returns an Option that bounds to n the number of
+// asymmetric CNG operations, that is key pair generation, signing,
+// verification and ECDH secret agreement, in flight at the same time.
+// Further operations block until a running one finishes. A value of
+// n <= 0 removes the limit, which is the default.
+//
+// Operations that are already running when the limit changes are
+// accounted against the limit they started with.
+func WithMaxConcurrency(n int) Option {
+	return func() {
+		var l opLimiter
+		if n > 0 {
+			l.sem = make(chan struct{}, n)
+		}
+		opLimiterValue.Store(l)
+	}
+}
+
+// acquireOp blocks until an operation slot is available and returns
+// the semaphore to pass to releaseOp, which is nil if there is no limit.
+func acquireOp() chan struct{} {
+	l, _ := opLimiterValue.Load().(opLimiter)
+	if l.sem != nil {
+		l.sem <- struct{}{}
+	}
+	return l.sem
+}
+
+func releaseOp(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMaxConcurrency(t *testing.T) {
+	const limit = 3
+	Configure(WithMaxConcurrency(limit))
+	defer Configure(WithMaxConcurrency(0))
+
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem := acquireOp()
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			releaseOp(sem)
+		}()
+	}
+	wg.Wait()
+	if maxInFlight > limit {
+		t.Errorf("%d operations ran concurrently, want at most %d", maxInFlight, limit)
+	}
+
+	// The limited operations still work.
+	x, y, d, err := GenerateKeyECDSA("P-256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := NewPrivateKeyECDSA("P-256", x, y, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := SHA256([]byte("testing"))
+	var signers sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		signers.Add(1)
+		go func() {
+			defer signers.Done()
+			if _, _, err := SignECDSA(priv, hashed[:]); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	signers.Wait()
+
+	Configure(WithMaxConcurrency(0))
+	if sem := acquireOp(); sem != nil {
+		t.Error("acquireOp returned a semaphore with no limit set")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"io"
+)
+
+// SealWithRandomNonce encrypts and authenticates plaintext with aead using
+// a fresh random nonce, and appends the nonce followed by the sealed
+// message to dst. The nonce is read from the source set by SetRandReader.
+//
+// Random nonces must only be used while the number of messages sealed
+// with the same key keeps the probability of a nonce collision negligible.
+// For AES-GCM, NIST SP 800-38D limits it to 2^32 messages.
+func SealWithRandomNonce(aead cipher.AEAD, dst, plaintext, additionalData []byte) ([]byte, error) {
+	ret, nonce := sliceForAppend(dst, aead.NonceSize())
+	if _, err := io.ReadFull(randomReader(), nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(ret, nonce, plaintext, additionalData), nil
+}
+
+// OpenWithRandomNonce decrypts a message sealed by SealWithRandomNonce,
+// which starts with the nonce, and appends the plaintext to dst.
+func OpenWithRandomNonce(aead cipher.AEAD, dst, sealed, additionalData []byte) ([]byte, error) {
+	n := aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errOpen
+	}
+	return aead.Open(dst, sealed[:n], sealed[n:], additionalData)
+}
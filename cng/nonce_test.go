@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealWithRandomNonce(t *testing.T) {
+	ci, err := NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ci.(*aesCipher).NewGCM(gcmStandardNonceSize, gcmTagSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, ad := []byte("Hello, world!"), []byte("ad")
+
+	// With a fixed reader the output is reproducible.
+	SetRandReader(bytes.NewReader(bytes.Repeat([]byte{0x42}, 2*gcmStandardNonceSize)))
+	defer SetRandReader(nil)
+	out1, err := SealWithRandomNonce(g, nil, plaintext, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out2, err := SealWithRandomNonce(g, nil, plaintext, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out1, out2) {
+		t.Errorf("outputs differ with a fixed reader:\n%x\n%x", out1, out2)
+	}
+	nonce := bytes.Repeat([]byte{0x42}, gcmStandardNonceSize)
+	if want := append(nonce, g.Seal(nil, nonce, plaintext, ad)...); !bytes.Equal(out1, want) {
+		t.Errorf("SealWithRandomNonce = %x, want %x", out1, want)
+	}
+	// The reader is exhausted.
+	if _, err := SealWithRandomNonce(g, nil, plaintext, ad); err == nil {
+		t.Error("expected an error from an exhausted reader")
+	}
+
+	// The default reader gives fresh nonces.
+	SetRandReader(nil)
+	prefix := []byte("prefix")
+	out3, err := SealWithRandomNonce(g, prefix, plaintext, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out3, prefix) {
+		t.Fatalf("dst prefix not preserved: %x", out3)
+	}
+	out3 = out3[len(prefix):]
+	if bytes.Equal(out3, out1) {
+		t.Error("default reader reused the fixed nonce")
+	}
+	got, err := OpenWithRandomNonce(g, nil, out3, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("OpenWithRandomNonce = %q, want %q", got, plaintext)
+	}
+	if _, err := OpenWithRandomNonce(g, nil, out3[:gcmStandardNonceSize-1], ad); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/subtle"
+)
+
+// fipsMode reports whether the system is in FIPS mode.
+// Tests replace it to exercise the FIPS-only code paths.
+var fipsMode = FIPS
+
+// nullAEAD is a cipher.AEAD that neither encrypts nor authenticates.
+type nullAEAD struct {
+	nonceSize, tagSize int
+}
+
+// NewNullAEAD returns a cipher.AEAD that does no cryptography, FOR TESTING
+// ONLY: it lets pipelines exercise their framing and streaming code without
+// the cost of a real AEAD. Seal appends the plaintext followed by a tag of
+// tagSize zero bytes, and Open checks and strips that tag. The nonce and
+// the additional data are only checked for length.
+//
+// NewNullAEAD returns an error when the system is in FIPS mode,
+// or when that can't be determined.
+func NewNullAEAD(nonceSize, tagSize int) (cipher.AEAD, error) {
+	if enabled, err := fipsMode(); err != nil || enabled {
+		return nil, errors.New("cng: the null AEAD is not allowed in FIPS mode")
+	}
+	if nonceSize < 0 || tagSize < 0 {
+		return nil, errors.New("cng: invalid null AEAD nonce or tag size")
+	}
+	return &nullAEAD{nonceSize, tagSize}, nil
+}
+
+func (g *nullAEAD) NonceSize() int { return g.nonceSize }
+
+func (g *nullAEAD) Overhead() int { return g.tagSize }
+
+func (g *nullAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != g.nonceSize {
+		panic("cipher: incorrect nonce length given to null AEAD")
+	}
+	ret, out := sliceForAppend(dst, len(plaintext)+g.tagSize)
+	if subtle.InexactOverlap(out, plaintext) {
+		panic("cipher: invalid buffer overlap")
+	}
+	copy(out, plaintext)
+	tag := out[len(plaintext):]
+	for i := range tag {
+		tag[i] = 0
+	}
+	return ret
+}
+
+func (g *nullAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != g.nonceSize {
+		panic("cipher: incorrect nonce length given to null AEAD")
+	}
+	if len(ciphertext) < g.tagSize {
+		return nil, errOpen
+	}
+	n := len(ciphertext) - g.tagSize
+	for _, b := range ciphertext[n:] {
+		if b != 0 {
+			return nil, errOpen
+		}
+	}
+	ret, out := sliceForAppend(dst, n)
+	if subtle.InexactOverlap(out, ciphertext[:n]) {
+		panic("cipher: invalid buffer overlap")
+	}
+	copy(out, ciphertext[:n])
+	return ret, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNullAEAD(t *testing.T) {
+	if enabled, err := FIPS(); err != nil || enabled {
+		t.Skip("skipping: the system is in FIPS mode")
+	}
+	g, err := NewNullAEAD(12, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.NonceSize() != 12 || g.Overhead() != 16 {
+		t.Errorf("NonceSize() = %d, Overhead() = %d, want 12, 16", g.NonceSize(), g.Overhead())
+	}
+	nonce := make([]byte, 12)
+	plaintext := []byte("framing test payload")
+	sealed := g.Seal([]byte("hdr"), nonce, plaintext, []byte("ad"))
+	want := append(append([]byte("hdr"), plaintext...), make([]byte, 16)...)
+	if !bytes.Equal(sealed, want) {
+		t.Errorf("Seal() = %x, want %x", sealed, want)
+	}
+	got, err := g.Open(nil, nonce, sealed[3:], []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Open() = %q, want %q", got, plaintext)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := g.Open(nil, nonce, sealed[3:], nil); err != errOpen {
+		t.Errorf("Open() with a modified tag: got %v, want %v", err, errOpen)
+	}
+	if _, err := g.Open(nil, nonce, make([]byte, 15), nil); err != errOpen {
+		t.Errorf("Open() with a short input: got %v, want %v", err, errOpen)
+	}
+	assertPanic(t, func() { g.Seal(nil, nonce[:11], plaintext, nil) })
+}
+
+func TestNullAEADFIPS(t *testing.T) {
+	defer func(f func() (bool, error)) { fipsMode = f }(fipsMode)
+	fipsMode = func() (bool, error) { return true, nil }
+	if _, err := NewNullAEAD(12, 16); err == nil {
+		t.Error("NewNullAEAD succeeded in FIPS mode")
+	}
+	// The null AEAD is refused if the mode can't be determined.
+	fipsMode = func() (bool, error) { return false, errors.New("unknown") }
+	if _, err := NewNullAEAD(12, 16); err == nil {
+		t.Error("NewNullAEAD succeeded with an unknown FIPS mode")
+	}
+	fipsMode = func() (bool, error) { return false, nil }
+	if _, err := NewNullAEAD(-1, 16); err == nil {
+		t.Error("NewNullAEAD accepted a negative nonce size")
+	}
+}
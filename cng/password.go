@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/subtle"
+	"io"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// Parameters used by MakePassword. The iteration count follows the
+// OWASP recommendation for PBKDF2-HMAC-SHA256.
+const (
+	passwordHashID     = bcrypt.SHA256_ALGORITHM
+	passwordIterations = 600000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+)
+
+// PBKDF2Hash is a password hash that records the PBKDF2 parameters
+// it was derived with, so it can be verified even after the defaults
+// used by MakePassword change.
+type PBKDF2Hash struct {
+	HashID     string // CNG hash ID of the HMAC, such as "SHA256"
+	Iterations int
+	Salt       []byte
+	Key        []byte // the derived key, len(Key) bytes long
+}
+
+// MakePassword hashes password with PBKDF2-HMAC-SHA256 and a fresh
+// random salt, for storage and later checking with VerifyPassword.
+func MakePassword(password []byte) (PBKDF2Hash, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := io.ReadFull(randomReader(), salt); err != nil {
+		return PBKDF2Hash{}, err
+	}
+	key, err := pbkdf2(password, salt, passwordIterations, passwordKeySize, passwordHashID)
+	if err != nil {
+		return PBKDF2Hash{}, err
+	}
+	return PBKDF2Hash{
+		HashID:     passwordHashID,
+		Iterations: passwordIterations,
+		Salt:       salt,
+		Key:        key,
+	}, nil
+}
+
+// VerifyPassword reports whether password matches stored, by deriving
+// the key again with the stored parameters and comparing it in
+// constant time. It returns false if the parameters are invalid.
+func VerifyPassword(password []byte, stored PBKDF2Hash) bool {
+	if stored.Iterations <= 0 || len(stored.Key) == 0 {
+		return false
+	}
+	key, err := pbkdf2(password, stored.Salt, stored.Iterations, len(stored.Key), stored.HashID)
+	if err != nil {
+		return false
+	}
+	defer Wipe(key)
+	return subtle.ConstantTimeCompare(key, stored.Key) == 1
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	password := []byte("correct horse battery staple")
+	stored, err := cng.MakePassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.HashID != "SHA256" || stored.Iterations <= 0 || len(stored.Salt) == 0 || len(stored.Key) == 0 {
+		t.Fatalf("incomplete password hash: %+v", stored)
+	}
+	want, err := cng.PBKDF2(password, stored.Salt, stored.Iterations, len(stored.Key), sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored.Key, want) {
+		t.Errorf("Key = %x, want %x", stored.Key, want)
+	}
+	if !cng.VerifyPassword(password, stored) {
+		t.Error("correct password rejected")
+	}
+
+	nearMisses := []string{
+		"correct horse battery stapl",
+		"correct horse battery staple ",
+		"correct horse battery stapla",
+		"Correct horse battery staple",
+		"",
+	}
+	for _, p := range nearMisses {
+		if cng.VerifyPassword([]byte(p), stored) {
+			t.Errorf("password %q accepted", p)
+		}
+	}
+
+	again, err := cng.MakePassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again.Salt, stored.Salt) || bytes.Equal(again.Key, stored.Key) {
+		t.Error("password hashes share a salt")
+	}
+}
+
+func TestVerifyPasswordParameters(t *testing.T) {
+	password := []byte("password")
+	stored := cng.PBKDF2Hash{HashID: "SHA1", Iterations: 1000, Salt: []byte("salt")}
+	var err error
+	stored.Key, err = cng.PBKDF2(password, stored.Salt, stored.Iterations, 20, cng.NewSHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.VerifyPassword(password, stored) {
+		t.Fatal("password with explicit parameters rejected")
+	}
+
+	tampered := stored
+	tampered.Iterations++
+	if cng.VerifyPassword(password, tampered) {
+		t.Error("password accepted with a different iteration count")
+	}
+	tampered = stored
+	tampered.HashID = "SHA256"
+	if cng.VerifyPassword(password, tampered) {
+		t.Error("password accepted with a different hash")
+	}
+	tampered = stored
+	tampered.HashID = "NOTAHASH"
+	if cng.VerifyPassword(password, tampered) {
+		t.Error("password accepted with an unknown hash")
+	}
+	tampered = stored
+	tampered.Iterations = 0
+	if cng.VerifyPassword(password, tampered) {
+		t.Error("password accepted with zero iterations")
+	}
+	tampered = stored
+	tampered.Key = nil
+	if cng.VerifyPassword(password, tampered) {
+		t.Error("password accepted with an empty key")
+	}
+}
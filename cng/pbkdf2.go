@@ -30,6 +30,11 @@ func PBKDF2(password, salt []byte, iter, keyLen int, h func() hash.Hash) ([]byte
 	if hashID == "" {
 		return nil, errors.New("cng: unsupported hash function")
 	}
+	return pbkdf2(password, salt, iter, keyLen, hashID)
+}
+
+// pbkdf2 implements PBKDF2 using the CNG hash identified by hashID.
+func pbkdf2(password, salt []byte, iter, keyLen int, hashID string) ([]byte, error) {
 	alg, err := loadPBKDF2()
 	if err != nil {
 		return nil, err
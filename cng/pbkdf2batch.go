@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// PBKDF2Request is a single PBKDF2 derivation for PBKDF2Batch.
+type PBKDF2Request struct {
+	Password   []byte
+	Salt       []byte
+	Iterations int
+	KeyLen     int
+	HashID     string // CNG hash ID of the HMAC, such as "SHA256"
+}
+
+// PBKDF2Batch derives a key for each request and returns them in order.
+// The requests are derived independently, in parallel across up to
+// GOMAXPROCS goroutines, which all share the cached PBKDF2 provider.
+// CNG derives each key from a key object imported from its password,
+// so there is no per-request handle to reuse.
+//
+// If any derivation fails, the error of the first failed request
+// is returned and the keys derived so far are zeroed.
+func PBKDF2Batch(reqs []PBKDF2Request) ([][]byte, error) {
+	keys := make([][]byte, len(reqs))
+	errs := make([]error, len(reqs))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(reqs) {
+					return
+				}
+				r := &reqs[i]
+				keys[i], errs[i] = pbkdf2(r.Password, r.Salt, r.Iterations, r.KeyLen, r.HashID)
+			}
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			for _, k := range keys {
+				Wipe(k)
+			}
+			return nil, &wrapError{"cng: PBKDF2 request " + itoa(i) + ": " + err.Error(), err}
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func newPBKDF2Requests(n int) []cng.PBKDF2Request {
+	reqs := make([]cng.PBKDF2Request, n)
+	for i := range reqs {
+		reqs[i] = cng.PBKDF2Request{
+			Password:   []byte(fmt.Sprintf("password %d", i)),
+			Salt:       []byte(fmt.Sprintf("salt %d", i)),
+			Iterations: 1000 + i,
+			KeyLen:     16 + i%32,
+			HashID:     "SHA256",
+		}
+	}
+	return reqs
+}
+
+func TestPBKDF2Batch(t *testing.T) {
+	reqs := newPBKDF2Requests(50)
+	reqs[7].HashID = "SHA1"
+	reqs[8].HashID = "SHA512"
+	keys, err := cng.PBKDF2Batch(reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(reqs) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(reqs))
+	}
+	for i, r := range reqs {
+		h := cng.NewSHA256
+		switch r.HashID {
+		case "SHA1":
+			h = cng.NewSHA1
+		case "SHA512":
+			h = cng.NewSHA512
+		}
+		want, err := cng.PBKDF2(r.Password, r.Salt, r.Iterations, r.KeyLen, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(keys[i], want) {
+			t.Errorf("request %d: got %x, want %x", i, keys[i], want)
+		}
+	}
+
+	if keys, err := cng.PBKDF2Batch(nil); err != nil || len(keys) != 0 {
+		t.Errorf("empty batch: %v, %v", keys, err)
+	}
+	reqs[3].HashID = "NOTAHASH"
+	if _, err := cng.PBKDF2Batch(reqs); err == nil {
+		t.Error("expected error for unknown hash")
+	}
+}
+
+func BenchmarkPBKDF2Batch(b *testing.B) {
+	reqs := newPBKDF2Requests(64)
+	b.Run("Serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, r := range reqs {
+				if _, err := cng.PBKDF2(r.Password, r.Salt, r.Iterations, r.KeyLen, cng.NewSHA256); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("Batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := cng.PBKDF2Batch(reqs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,189 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package pemkeys
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	errMalformedDERKey = errors.New("cng: malformed DER key")
+	errUnknownCurve    = errors.New("cng: unknown elliptic curve")
+)
+
+// ecUncompressedPrefix is the encoding byte of an uncompressed point.
+const ecUncompressedPrefix = 0x04
+
+// DER encoded object identifiers used in SubjectPublicKeyInfo.
+var (
+	oidECPublicKey   = []byte{0x06, 0x07, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x02, 0x01}             // 1.2.840.10045.2.1
+	oidRSAEncryption = []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x01, 0x01} // 1.2.840.113549.1.1.1
+	oidP224          = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x21}                         // 1.3.132.0.33
+	oidP256          = []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}       // 1.2.840.10045.3.1.7
+	oidP384          = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x22}                         // 1.3.132.0.34
+	oidP521          = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x23}                         // 1.3.132.0.35
+	oidX25519        = []byte{0x06, 0x03, 0x2b, 0x65, 0x6e}                                     // 1.3.101.110
+)
+
+// derNull is the DER encoded NULL used as the rsaEncryption parameters.
+var derNull = []byte{0x05, 0x00}
+
+// pkixCurve associates a NIST curve name with its
+// coordinate size and DER encoded object identifier.
+type pkixCurve struct {
+	name string
+	size int
+	oid  []byte
+}
+
+var pkixCurves = []pkixCurve{
+	{"P-224", 28, oidP224},
+	{"P-256", 32, oidP256},
+	{"P-384", 48, oidP384},
+	{"P-521", 66, oidP521},
+}
+
+func pkixCurveBySize(size int) (pkixCurve, error) {
+	for _, c := range pkixCurves {
+		if c.size == size {
+			return c, nil
+		}
+	}
+	return pkixCurve{}, errUnknownCurve
+}
+
+func pkixCurveByOID(oid []byte) (pkixCurve, error) {
+	for _, c := range pkixCurves {
+		if bytes.Equal(c.oid, oid) {
+			return c, nil
+		}
+	}
+	return pkixCurve{}, errUnknownCurve
+}
+
+// derElement returns the DER element with the given tag
+// whose contents are the concatenation of contents.
+func derElement(tag byte, contents ...[]byte) []byte {
+	var n int
+	for _, c := range contents {
+		n += len(c)
+	}
+	out := appendDERLength([]byte{tag}, n)
+	for _, c := range contents {
+		out = append(out, c...)
+	}
+	return out
+}
+
+// encodeDERInteger returns the DER encoding of the unsigned
+// big-endian integer b, including the tag and length.
+func encodeDERInteger(b []byte) []byte {
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	var pad int
+	if b[0]&0x80 != 0 {
+		// Prepend a zero byte so the integer is not negative.
+		pad = 1
+	}
+	out := appendDERLength([]byte{0x02}, len(b)+pad)
+	if pad != 0 {
+		out = append(out, 0)
+	}
+	return append(out, b...)
+}
+
+func appendDERLength(b []byte, n int) []byte {
+	switch {
+	case n < 0x80:
+		return append(b, byte(n))
+	case n <= 0xff:
+		return append(b, 0x81, byte(n))
+	default:
+		return append(b, 0x82, byte(n>>8), byte(n))
+	}
+}
+
+// parseDERElement parses a DER element with the given tag from the start of b,
+// returning its contents and the remaining bytes.
+// Only the length encodings of up to 2 bytes are supported,
+// which is enough for keys of up to 16384 bits.
+func parseDERElement(b []byte, tag byte) (contents, rest []byte, ok bool) {
+	if len(b) < 2 || b[0] != tag {
+		return nil, nil, false
+	}
+	n, b := int(b[1]), b[2:]
+	switch {
+	case n < 0x80:
+	case n == 0x81:
+		if len(b) < 1 || b[0] < 0x80 {
+			return nil, nil, false
+		}
+		n, b = int(b[0]), b[1:]
+	case n == 0x82:
+		if len(b) < 2 || b[0] == 0 {
+			return nil, nil, false
+		}
+		n, b = int(b[0])<<8|int(b[1]), b[2:]
+	default:
+		return nil, nil, false
+	}
+	if n > len(b) {
+		return nil, nil, false
+	}
+	return b[:n], b[n:], true
+}
+
+// parseDERUnsigned parses a non-negative DER INTEGER from the start of b,
+// returning its big-endian value and the remaining bytes.
+func parseDERUnsigned(b []byte) ([]byte, []byte, bool) {
+	v, rest, ok := parseDERElement(b, 0x02)
+	if !ok || len(v) == 0 || v[0]&0x80 != 0 {
+		return nil, nil, false
+	}
+	if len(v) > 1 && v[0] == 0 {
+		v = v[1:]
+	}
+	return v, rest, true
+}
+
+// parseDERVersion parses the DER INTEGER from the start of b and reports
+// whether it is one of the small versions allowed.
+func parseDERVersion(b []byte, allowed ...byte) ([]byte, bool) {
+	v, rest, ok := parseDERElement(b, 0x02)
+	if !ok || len(v) != 1 {
+		return nil, false
+	}
+	for _, a := range allowed {
+		if v[0] == a {
+			return rest, true
+		}
+	}
+	return nil, false
+}
+
+// parseAlgorithm splits the contents of the DER AlgorithmIdentifier alg
+// into the algorithm OID and its parameters, both including their tag.
+func parseAlgorithm(alg []byte) (oid, params []byte, ok bool) {
+	_, params, ok = parseDERElement(alg, 0x06)
+	if !ok {
+		return nil, nil, false
+	}
+	return alg[:len(alg)-len(params)], params, true
+}
+
+// parseECPoint splits the uncompressed point of curve c into X and Y.
+func parseECPoint(c pkixCurve, point []byte) (X, Y []byte, err error) {
+	if len(point) != 1+2*c.size || point[0] != ecUncompressedPrefix {
+		return nil, nil, errors.New("cng: invalid " + c.name + " public key encoding")
+	}
+	return point[1 : 1+c.size], point[1+c.size:], nil
+}
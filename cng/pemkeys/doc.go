@@ -0,0 +1,9 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// Package pemkeys encodes and parses cng keys in the PEM formats used by
+// OpenSSL and crypto/x509.
+//
+// It lives outside package cng so that cng keeps the dependencies of
+// the crypto packages it backs, which can't import encoding/pem.
+package pemkeys
@@ -0,0 +1,404 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package pemkeys
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"strconv"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+var errNotECDHKey = errors.New("cng: PEM block does not hold an ECDH key")
+
+// MarshalPEM returns the PEM encoding of key, which must be one of
+// *cng.PublicKeyECDSA, *cng.PrivateKeyECDSA, *cng.PublicKeyECDH,
+// *cng.PrivateKeyECDH, *cng.PublicKeyRSA or *cng.PrivateKeyRSA.
+// The formats are those of OpenSSL and crypto/x509:
+//   - public keys are "PUBLIC KEY" blocks holding a SubjectPublicKeyInfo;
+//   - NIST curve private keys are "EC PRIVATE KEY" blocks holding a SEC 1
+//     ECPrivateKey, which includes the public key;
+//   - RSA and X25519 private keys are "PRIVATE KEY" blocks holding
+//     a PKCS #8 PrivateKeyInfo.
+func MarshalPEM(key interface{}) ([]byte, error) {
+	var typ string
+	var der []byte
+	var err error
+	switch key := key.(type) {
+	case *cng.PublicKeyECDSA:
+		typ = "PUBLIC KEY"
+		der, err = marshalECDSAPublicKey(key)
+	case *cng.PrivateKeyECDSA:
+		typ = "EC PRIVATE KEY"
+		der, err = marshalECDSAPrivateKey(key)
+	case *cng.PublicKeyECDH:
+		typ = "PUBLIC KEY"
+		der, err = key.MarshalPKIX()
+	case *cng.PrivateKeyECDH:
+		typ, der, err = marshalECDHPrivateKey(key)
+	case *cng.PublicKeyRSA:
+		typ = "PUBLIC KEY"
+		der, err = marshalRSAPublicKey(key)
+	case *cng.PrivateKeyRSA:
+		typ = "PRIVATE KEY"
+		der, err = marshalRSAPrivateKey(key)
+	default:
+		return nil, errors.New("cng: unsupported PEM key type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer cng.Wipe(der)
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), nil
+}
+
+// ParsePEM parses the first PEM block of data and imports the key it
+// holds, as encoded by MarshalPEM. "RSA PRIVATE KEY" blocks holding a
+// PKCS #1 RSAPrivateKey are accepted as well.
+//
+// NIST curve keys are returned as *cng.PublicKeyECDSA or
+// *cng.PrivateKeyECDSA, X25519 keys as *cng.PublicKeyECDH or
+// *cng.PrivateKeyECDH and RSA keys as *cng.PublicKeyRSA or
+// *cng.PrivateKeyRSA. Use ParsePEMECDH to import NIST curve keys
+// for ECDH. EC private keys must include the public key.
+func ParsePEM(data []byte) (interface{}, error) {
+	return parsePEM(data, false)
+}
+
+// ParsePEMECDH is like ParsePEM, but imports elliptic curve keys,
+// including the NIST curve ones, as *cng.PublicKeyECDH or
+// *cng.PrivateKeyECDH. It returns an error for RSA keys.
+func ParsePEMECDH(data []byte) (interface{}, error) {
+	return parsePEM(data, true)
+}
+
+func parsePEM(data []byte, ecdh bool) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("cng: no PEM data found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return parsePKIXPublicKey(block.Bytes, ecdh)
+	case "EC PRIVATE KEY":
+		return parseECPrivateKey(block.Bytes, nil, ecdh)
+	case "PRIVATE KEY":
+		return parsePKCS8PrivateKey(block.Bytes, ecdh)
+	case "RSA PRIVATE KEY":
+		if ecdh {
+			return nil, errNotECDHKey
+		}
+		return parsePKCS1PrivateKey(block.Bytes)
+	}
+	return nil, errors.New("cng: unsupported PEM block type " + strconv.Quote(block.Type))
+}
+
+func marshalECDSAPublicKey(key *cng.PublicKeyECDSA) ([]byte, error) {
+	x, y, err := key.Export()
+	if err != nil {
+		return nil, err
+	}
+	c, err := pkixCurveBySize(len(x))
+	if err != nil {
+		return nil, err
+	}
+	return marshalECPublicKey(c, x, y), nil
+}
+
+func marshalECDSAPrivateKey(key *cng.PrivateKeyECDSA) ([]byte, error) {
+	x, y, d, err := key.Export()
+	if err != nil {
+		return nil, err
+	}
+	defer cng.Wipe(d)
+	c, err := pkixCurveBySize(len(x))
+	if err != nil {
+		return nil, err
+	}
+	return marshalECPrivateKey(c, x, y, d), nil
+}
+
+func marshalECDHPrivateKey(key *cng.PrivateKeyECDH) (string, []byte, error) {
+	d, err := key.Bytes()
+	if err != nil {
+		return "", nil, err
+	}
+	defer cng.Wipe(d)
+	pub, err := key.PublicKey()
+	if err != nil {
+		return "", nil, err
+	}
+	point := pub.Bytes()
+	if len(point) == 32 {
+		// X25519 public keys are the raw u-coordinate,
+		// NIST ones are uncompressed points.
+		return "PRIVATE KEY", marshalX25519PrivateKey(d), nil
+	}
+	c, err := pkixCurveBySize(len(d))
+	if err != nil {
+		return "", nil, err
+	}
+	x, y, err := parseECPoint(c, point)
+	if err != nil {
+		return "", nil, err
+	}
+	return "EC PRIVATE KEY", marshalECPrivateKey(c, x, y, d), nil
+}
+
+func marshalECPublicKey(c pkixCurve, x, y []byte) []byte {
+	// SubjectPublicKeyInfo ::= SEQUENCE {
+	//     algorithm         AlgorithmIdentifier,
+	//     subjectPublicKey  BIT STRING }
+	return derElement(0x30,
+		derElement(0x30, oidECPublicKey, c.oid),
+		derElement(0x03, []byte{0, ecUncompressedPrefix}, x, y),
+	)
+}
+
+func marshalECPrivateKey(c pkixCurve, x, y, d []byte) []byte {
+	// ECPrivateKey ::= SEQUENCE {
+	//     version        INTEGER { ecPrivkeyVer1(1) },
+	//     privateKey     OCTET STRING,
+	//     parameters [0] ECParameters OPTIONAL,
+	//     publicKey  [1] BIT STRING OPTIONAL }
+	return derElement(0x30,
+		[]byte{0x02, 0x01, 0x01},
+		derElement(0x04, d),
+		derElement(0xa0, c.oid),
+		derElement(0xa1, derElement(0x03, []byte{0, ecUncompressedPrefix}, x, y)),
+	)
+}
+
+func marshalX25519PrivateKey(d []byte) []byte {
+	// PrivateKeyInfo ::= SEQUENCE {
+	//     version              INTEGER,
+	//     privateKeyAlgorithm  AlgorithmIdentifier,
+	//     privateKey           OCTET STRING }
+	// where privateKey holds the CurvePrivateKey OCTET STRING of RFC 8410.
+	return derElement(0x30,
+		[]byte{0x02, 0x01, 0x00},
+		derElement(0x30, oidX25519),
+		derElement(0x04, derElement(0x04, d)),
+	)
+}
+
+func marshalRSAPublicKey(key *cng.PublicKeyRSA) ([]byte, error) {
+	n, err := key.N()
+	if err != nil {
+		return nil, err
+	}
+	e, err := key.E()
+	if err != nil {
+		return nil, err
+	}
+	// RSAPublicKey ::= SEQUENCE {
+	//     modulus         INTEGER,
+	//     publicExponent  INTEGER }
+	pkcs1 := derElement(0x30, encodeDERInteger(n), encodeDERInteger(e))
+	return derElement(0x30,
+		derElement(0x30, oidRSAEncryption, derNull),
+		derElement(0x03, []byte{0}, pkcs1),
+	), nil
+}
+
+func marshalRSAPrivateKey(key *cng.PrivateKeyRSA) ([]byte, error) {
+	// RSAPrivateKey ::= SEQUENCE {
+	//     version, modulus, publicExponent, privateExponent,
+	//     prime1, prime2, exponent1, exponent2, coefficient }
+	components := [...]func() (cng.BigInt, error){
+		key.N, key.E, key.D, key.PrimeP, key.PrimeQ, key.Dp, key.Dq, key.Qinv,
+	}
+	var ints [len(components)][]byte
+	defer func() {
+		for _, b := range ints {
+			cng.Wipe(b)
+		}
+	}()
+	for i, component := range components {
+		v, err := component()
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = encodeDERInteger(v)
+		cng.Wipe(v)
+	}
+	pkcs1 := derElement(0x30, []byte{0x02, 0x01, 0x00}, ints[0], ints[1], ints[2], ints[3], ints[4], ints[5], ints[6], ints[7])
+	defer cng.Wipe(pkcs1)
+	return derElement(0x30,
+		[]byte{0x02, 0x01, 0x00},
+		derElement(0x30, oidRSAEncryption, derNull),
+		derElement(0x04, pkcs1),
+	), nil
+}
+
+func parsePKIXPublicKey(der []byte, ecdh bool) (interface{}, error) {
+	spki, rest, ok := parseDERElement(der, 0x30)
+	if !ok || len(rest) != 0 {
+		return nil, errMalformedDERKey
+	}
+	alg, spki, ok := parseDERElement(spki, 0x30)
+	if !ok {
+		return nil, errMalformedDERKey
+	}
+	bits, spki, ok := parseDERElement(spki, 0x03)
+	if !ok || len(spki) != 0 || len(bits) == 0 || bits[0] != 0 {
+		return nil, errMalformedDERKey
+	}
+	key := bits[1:]
+	oid, params, ok := parseAlgorithm(alg)
+	if !ok {
+		return nil, errMalformedDERKey
+	}
+	switch {
+	case bytes.Equal(oid, oidRSAEncryption):
+		if ecdh {
+			return nil, errNotECDHKey
+		}
+		pkcs1, rest, ok := parseDERElement(key, 0x30)
+		if !ok || len(rest) != 0 {
+			return nil, errMalformedDERKey
+		}
+		n, pkcs1, ok := parseDERUnsigned(pkcs1)
+		if !ok {
+			return nil, errMalformedDERKey
+		}
+		e, pkcs1, ok := parseDERUnsigned(pkcs1)
+		if !ok || len(pkcs1) != 0 {
+			return nil, errMalformedDERKey
+		}
+		return cng.NewPublicKeyRSA(n, e)
+	case bytes.Equal(oid, oidECPublicKey):
+		c, err := pkixCurveByOID(params)
+		if err != nil {
+			return nil, err
+		}
+		if ecdh {
+			return cng.NewPublicKeyECDH(c.name, key)
+		}
+		x, y, err := parseECPoint(c, key)
+		if err != nil {
+			return nil, err
+		}
+		return cng.NewPublicKeyECDSA(c.name, x, y)
+	case bytes.Equal(oid, oidX25519):
+		return cng.NewPublicKeyECDH("X25519", key)
+	}
+	return nil, errors.New("cng: unsupported public key algorithm")
+}
+
+// parseECPrivateKey parses a SEC 1 ECPrivateKey. curveOID is the curve
+// from the enclosing PKCS #8 structure, if any.
+func parseECPrivateKey(der, curveOID []byte, ecdh bool) (interface{}, error) {
+	seq, rest, ok := parseDERElement(der, 0x30)
+	if !ok || len(rest) != 0 {
+		return nil, errMalformedDERKey
+	}
+	seq, ok = parseDERVersion(seq, 1)
+	if !ok {
+		return nil, errors.New("cng: unsupported EC private key version")
+	}
+	d, seq, ok := parseDERElement(seq, 0x04)
+	if !ok {
+		return nil, errMalformedDERKey
+	}
+	if params, rest, ok := parseDERElement(seq, 0xa0); ok {
+		curveOID, seq = params, rest
+	}
+	c, err := pkixCurveByOID(curveOID)
+	if err != nil {
+		return nil, err
+	}
+	if len(d) == 0 || len(d) > c.size {
+		return nil, errMalformedDERKey
+	}
+	// Some encoders trim the leading zeros of the private key.
+	padded := make([]byte, c.size)
+	copy(padded[c.size-len(d):], d)
+	defer cng.Wipe(padded)
+	if ecdh {
+		return cng.NewPrivateKeyECDH(c.name, padded)
+	}
+	pub, _, ok := parseDERElement(seq, 0xa1)
+	if !ok {
+		return nil, errors.New("cng: EC private key without public key is not supported")
+	}
+	bits, rest, ok := parseDERElement(pub, 0x03)
+	if !ok || len(rest) != 0 || len(bits) == 0 || bits[0] != 0 {
+		return nil, errMalformedDERKey
+	}
+	x, y, err := parseECPoint(c, bits[1:])
+	if err != nil {
+		return nil, err
+	}
+	return cng.NewPrivateKeyECDSA(c.name, x, y, padded)
+}
+
+func parsePKCS8PrivateKey(der []byte, ecdh bool) (interface{}, error) {
+	seq, rest, ok := parseDERElement(der, 0x30)
+	if !ok || len(rest) != 0 {
+		return nil, errMalformedDERKey
+	}
+	// Version 1 is the OneAsymmetricKey of RFC 5958,
+	// whose optional public key is ignored.
+	seq, ok = parseDERVersion(seq, 0, 1)
+	if !ok {
+		return nil, errors.New("cng: unsupported PKCS #8 version")
+	}
+	alg, seq, ok := parseDERElement(seq, 0x30)
+	if !ok {
+		return nil, errMalformedDERKey
+	}
+	key, _, ok := parseDERElement(seq, 0x04)
+	if !ok {
+		return nil, errMalformedDERKey
+	}
+	oid, params, ok := parseAlgorithm(alg)
+	if !ok {
+		return nil, errMalformedDERKey
+	}
+	switch {
+	case bytes.Equal(oid, oidRSAEncryption):
+		if ecdh {
+			return nil, errNotECDHKey
+		}
+		return parsePKCS1PrivateKey(key)
+	case bytes.Equal(oid, oidECPublicKey):
+		return parseECPrivateKey(key, params, ecdh)
+	case bytes.Equal(oid, oidX25519):
+		d, rest, ok := parseDERElement(key, 0x04)
+		if !ok || len(rest) != 0 {
+			return nil, errMalformedDERKey
+		}
+		return cng.NewPrivateKeyECDH("X25519", d)
+	}
+	return nil, errors.New("cng: unsupported private key algorithm")
+}
+
+func parsePKCS1PrivateKey(der []byte) (interface{}, error) {
+	seq, rest, ok := parseDERElement(der, 0x30)
+	if !ok || len(rest) != 0 {
+		return nil, errMalformedDERKey
+	}
+	// Version 1 is for multi-prime keys, which CNG doesn't support.
+	seq, ok = parseDERVersion(seq, 0)
+	if !ok {
+		return nil, errors.New("cng: unsupported RSA private key version")
+	}
+	// N, E, D, P, Q, Dp, Dq and Qinv.
+	var v [8]cng.BigInt
+	for i := range v {
+		if v[i], seq, ok = parseDERUnsigned(seq); !ok {
+			return nil, errMalformedDERKey
+		}
+	}
+	if len(seq) != 0 {
+		return nil, errMalformedDERKey
+	}
+	return cng.NewPrivateKeyRSA(v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7])
+}
@@ -0,0 +1,51 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+)
+
+// DER encoded object identifiers used in SubjectPublicKeyInfo.
+var (
+	oidECPublicKey = []byte{0x06, 0x07, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x02, 0x01}       // 1.2.840.10045.2.1
+	oidP256        = []byte{0x06, 0x08, 0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07} // 1.2.840.10045.3.1.7
+	oidP384        = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x22}                   // 1.3.132.0.34
+	oidP521        = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x23}                   // 1.3.132.0.35
+	oidX25519      = []byte{0x06, 0x03, 0x2b, 0x65, 0x6e}                               // 1.3.101.110
+)
+
+// MarshalPKIX returns the public key encoded as a DER SubjectPublicKeyInfo,
+// as specified in RFC 5480 for the NIST curves and RFC 8410 for X25519,
+// which is the format produced by x509.MarshalPKIXPublicKey.
+func (k *PublicKeyECDH) MarshalPKIX() ([]byte, error) {
+	// The curve is implied by the length of the encoded key.
+	var algorithm []byte
+	switch len(k.bytes) {
+	case 1 + 2*32:
+		algorithm = append(append([]byte(nil), oidECPublicKey...), oidP256...)
+	case 1 + 2*48:
+		algorithm = append(append([]byte(nil), oidECPublicKey...), oidP384...)
+	case 1 + 2*66:
+		algorithm = append(append([]byte(nil), oidECPublicKey...), oidP521...)
+	case 32:
+		algorithm = oidX25519
+	default:
+		return nil, errors.New("cng: unsupported ECDH public key")
+	}
+	// SubjectPublicKeyInfo ::= SEQUENCE {
+	//     algorithm         AlgorithmIdentifier,
+	//     subjectPublicKey  BIT STRING }
+	algID := appendDERLength([]byte{0x30}, len(algorithm))
+	algID = append(algID, algorithm...)
+	bitString := appendDERLength([]byte{0x03}, 1+len(k.bytes))
+	bitString = append(bitString, 0) // No unused bits.
+	bitString = append(bitString, k.bytes...)
+	out := appendDERLength([]byte{0x30}, len(algID)+len(bitString))
+	out = append(out, algID...)
+	return append(out, bitString...), nil
+}
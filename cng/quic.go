@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+// maxQUICPacketNumber is the largest packet number allowed by RFC 9000, Section 12.3.
+const maxQUICPacketNumber = 1<<62 - 1
+
+// GCMQUIC is an AES-GCM AEAD for QUIC packet protection, which forms
+// each nonce by XORing the packet number into the IV as specified in
+// RFC 9001, Section 5.3.
+type GCMQUIC struct {
+	g  *aesGCM
+	iv [gcmStandardNonceSize]byte
+}
+
+// NewGCMQUIC returns a GCMQUIC using the AES key in c and the
+// 12-byte packet protection IV.
+func NewGCMQUIC(c cipher.Block, iv []byte) (*GCMQUIC, error) {
+	ac, ok := c.(*aesCipher)
+	if !ok {
+		return nil, errors.New("cng: QUIC packet protection requires an AES cipher created by NewAESCipher")
+	}
+	if len(iv) != gcmStandardNonceSize {
+		return nil, errors.New("cng: QUIC IV must be 12 bytes")
+	}
+	g, err := newGCM(ac.key, false)
+	if err != nil {
+		return nil, err
+	}
+	q := &GCMQUIC{g: g}
+	copy(q.iv[:], iv)
+	return q, nil
+}
+
+// Overhead returns the length of the authentication tag appended to each payload.
+func (q *GCMQUIC) Overhead() int { return gcmTagSize }
+
+func (q *GCMQUIC) nonce(pn uint64) [gcmStandardNonceSize]byte {
+	if pn > maxQUICPacketNumber {
+		panic("cng: QUIC packet number out of range")
+	}
+	nonce := q.iv
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], pn)
+	xorBytes(nonce[len(nonce)-8:], nonce[len(nonce)-8:], b[:])
+	return nonce
+}
+
+// SealPacket encrypts and authenticates payload using the nonce derived
+// from the packet number pn, authenticating the unprotected header as
+// additional data. It returns the ciphertext followed by the tag.
+func (q *GCMQUIC) SealPacket(pn uint64, header, payload []byte) []byte {
+	nonce := q.nonce(pn)
+	return q.g.Seal(nil, nonce[:], payload, header)
+}
+
+// OpenPacket decrypts and authenticates ciphertext using the nonce derived
+// from the packet number pn and the unprotected header.
+func (q *GCMQUIC) OpenPacket(pn uint64, header, ciphertext []byte) ([]byte, error) {
+	nonce := q.nonce(pn)
+	return q.g.Open(nil, nonce[:], ciphertext, header)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// TestGCMQUIC uses the server Initial packet from RFC 9001, Appendix A.3.
+func TestGCMQUIC(t *testing.T) {
+	key := hexDecode(t, "cf3a5331653c364c88f0f379b6067e37")
+	iv := hexDecode(t, "0ac1493ca1905853b0bba03e")
+	header := hexDecode(t, "c1000000010008f067a5502a4262b50040750001")
+	payload := hexDecode(t, "02000000000600405a020000560303eefce7f7b37ba1d1632e96677825ddf73988cf"+
+		"c79825df566dc5430b9a045a1200130100002e00330024001d00209d3c940d89690b84d08a60993c144eca684d1081287c834d53"+
+		"11bcf32bb9da1a002b00020304")
+	want := hexDecode(t, "5a482cd0991cd25b0aac406a5816b6394100f37a1c69797554780bb38cc5a99f5ede4cf73c3ec2493a1839b3dbcba3f6"+
+		"ea46c5b7684df3548e7ddeb9c3bf9c73cc3f3bded74b562bfb19fb84022f8ef4cdd93795d77d06edbb7aaf2f58891850abbdca3d20"+
+		"398c276456cbc42158407dd074ee")
+	const pn = 1
+
+	block, err := cng.NewAESCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := cng.NewGCMQUIC(block, iv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := q.SealPacket(pn, header, payload)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("SealPacket:\ngot  %x\nwant %x", got, want)
+	}
+	pt, err := q.OpenPacket(pn, header, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pt, payload) {
+		t.Errorf("OpenPacket:\ngot  %x\nwant %x", pt, payload)
+	}
+	if _, err := q.OpenPacket(pn+1, header, got); err == nil {
+		t.Error("OpenPacket succeeded with the wrong packet number")
+	}
+	if _, err := cng.NewGCMQUIC(block, iv[:8]); err == nil {
+		t.Error("expected error for short IV")
+	}
+}
+
+func TestGCMQUICForeignBlock(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cng.NewGCMQUIC(block, make([]byte, 12)); err == nil {
+		t.Error("NewGCMQUIC accepted a cipher.Block not created by NewAESCipher")
+	}
+}
@@ -7,6 +7,9 @@
 package cng
 
 import (
+	"io"
+	"sync/atomic"
+
 	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
 )
 
@@ -26,3 +29,28 @@ func (randReader) Read(b []byte) (int, error) {
 }
 
 const RandReader = randReader(0)
+
+type randSource struct {
+	r io.Reader
+}
+
+var randSourceValue atomic.Value // randSource
+
+// SetRandReader sets the source of randomness used by the functions of
+// this package that need it, such as OAEP encryption, SignStream and
+// SealWithRandomNonce. A nil r restores the default, RandReader.
+//
+// It is meant for reproducible tests of higher-level constructions:
+// production code must not replace the CNG random number generator.
+// RandReader itself always reads from CNG.
+func SetRandReader(r io.Reader) {
+	randSourceValue.Store(randSource{r})
+}
+
+// randomReader returns the reader set by SetRandReader, or RandReader.
+func randomReader() io.Reader {
+	if s, _ := randSourceValue.Load().(randSource); s.r != nil {
+		return s.r
+	}
+	return RandReader
+}
@@ -8,6 +8,7 @@ package cng
 
 import (
 	"io"
+	"strconv"
 	"testing"
 )
 
@@ -27,7 +28,12 @@ func TestRandBig(t *testing.T) {
 		// This test can take ~20s to complete.
 		t.Skip("skipping test in short mode.")
 	}
-	b := make([]byte, 1<<32+60)
+	if strconv.IntSize < 64 {
+		t.Skip("skipping: requires a 64-bit platform")
+	}
+	// Use a variable size so this file still compiles on 32-bit platforms.
+	size := int64(1<<32 + 60)
+	b := make([]byte, size)
 	n, err := io.ReadFull(RandReader, b)
 	if err != nil {
 		t.Fatal(err)
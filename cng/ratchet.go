@@ -0,0 +1,90 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// ratchetInfo is the HKDF-Expand info used for every ratchet step.
+const ratchetInfo = "go-crypto-winnative ratchet"
+
+// Ratchet is a symmetric key ratchet, like the chain keys of the Signal
+// Double Ratchet. Each step expands the current chain key with HKDF into
+// the next chain key and a message key, then erases the old chain key,
+// so compromising the ratchet state doesn't reveal earlier message keys.
+//
+// A Ratchet is safe for concurrent use by multiple goroutines.
+type Ratchet struct {
+	hashID string
+	size   int
+
+	mu       sync.Mutex
+	chainKey []byte
+}
+
+// NewRatchet returns a Ratchet whose initial chain key is extracted
+// from rootKey with HKDF, using the hash identified by hashID,
+// such as "SHA256", and a zero salt. Ratchets created with the same
+// rootKey and hashID produce the same sequence of message keys.
+func NewRatchet(rootKey []byte, hashID string) (*Ratchet, error) {
+	if len(rootKey) == 0 {
+		return nil, errors.New("cng: empty ratchet root key")
+	}
+	alg, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	chainKey, err := extractHKDF(hashID, rootKey, make([]byte, alg.size))
+	if err != nil {
+		return nil, err
+	}
+	return &Ratchet{hashID: hashID, size: int(alg.size), chainKey: chainKey}, nil
+}
+
+// Next advances the ratchet and returns the next message key,
+// which is as long as the hash output.
+func (r *Ratchet) Next() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.chainKey == nil {
+		return nil, ErrClosed
+	}
+	kh, err := newHKDFKey(r.hashID, r.chainKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer bcrypt.DestroyKey(kh)
+	out := make([]byte, 2*r.size)
+	n, err := hkdfDerive(kh, []byte(ratchetInfo), out)
+	if err != nil {
+		Wipe(out)
+		return nil, err
+	}
+	if n != len(out) {
+		Wipe(out)
+		return nil, errors.New("hkdf: short derivation")
+	}
+	Wipe(r.chainKey)
+	r.chainKey = out[:r.size:r.size]
+	return out[r.size:], nil
+}
+
+// Close erases the chain key. Next fails with ErrClosed afterwards.
+func (r *Ratchet) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.chainKey == nil {
+		return ErrClosed
+	}
+	Wipe(r.chainKey)
+	r.chainKey = nil
+	return nil
+}
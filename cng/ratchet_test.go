@@ -0,0 +1,107 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// refRatchetStep computes one SHA-256 ratchet step with crypto/hmac,
+// returning the next chain key and the message key.
+func refRatchetStep(chainKey []byte) (next, msgKey []byte) {
+	const info = "go-crypto-winnative ratchet"
+	mac := hmac.New(sha256.New, chainKey)
+	mac.Write([]byte(info))
+	mac.Write([]byte{1})
+	next = mac.Sum(nil)
+	mac.Reset()
+	mac.Write(next)
+	mac.Write([]byte(info))
+	mac.Write([]byte{2})
+	return next, mac.Sum(nil)
+}
+
+func TestRatchet(t *testing.T) {
+	root := []byte("ratchet root key")
+	r1, err := cng.NewRatchet(root, "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r1.Close()
+	r2, err := cng.NewRatchet(root, "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+
+	mac := hmac.New(sha256.New, make([]byte, sha256.Size))
+	mac.Write(root)
+	chainKey := mac.Sum(nil)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 8; i++ {
+		k1, err := r1.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		k2, err := r2.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(k1, k2) {
+			t.Fatalf("step %d: ratchets with the same root diverged", i)
+		}
+		var want []byte
+		chainKey, want = refRatchetStep(chainKey)
+		if !bytes.Equal(k1, want) {
+			t.Fatalf("step %d: got %x, want %x", i, k1, want)
+		}
+		if seen[string(k1)] {
+			t.Fatalf("step %d: message key repeated", i)
+		}
+		seen[string(k1)] = true
+	}
+}
+
+func TestRatchetRoot(t *testing.T) {
+	r1, err := cng.NewRatchet([]byte("root one"), "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := cng.NewRatchet([]byte("root two"), "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k1, err := r1.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := r2.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(k1, k2) {
+		t.Error("different roots produced the same message key")
+	}
+	if err := r1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r1.Next(); err != cng.ErrClosed {
+		t.Errorf("Next after Close: err = %v, want %v", err, cng.ErrClosed)
+	}
+	if _, err := cng.NewRatchet(nil, "SHA256"); err == nil {
+		t.Error("expected error for empty root key")
+	}
+	if _, err := cng.NewRatchet([]byte("root"), "NOTAHASH"); err == nil {
+		t.Error("expected error for unknown hash")
+	}
+}
@@ -8,8 +8,11 @@ package cng
 
 import (
 	"crypto"
+	"crypto/subtle"
+	"encoding/binary"
 	"errors"
 	"hash"
+	"io"
 	"runtime"
 	"unsafe"
 
@@ -46,17 +49,14 @@ func GenerateKeyRSA(bits int) (N, E, D, P, Q, Dp, Dq, Qinv BigInt, err error) {
 	if !keyIsAllowed(h.allowedKeyLengths, uint32(bits)) {
 		return bad(errors.New("crypto/rsa: invalid key size"))
 	}
-	var hkey bcrypt.KEY_HANDLE
-	err = bcrypt.GenerateKeyPair(h.handle, &hkey, uint32(bits), 0)
-	if err != nil {
-		return bad(err)
+	if hook := auditHook(); hook != nil {
+		hook(AuditEvent{Op: AuditGenerateKey, Algorithm: "RSA", KeyBits: bits})
 	}
-	defer bcrypt.DestroyKey(hkey)
-	// The key cannot be used until BcryptFinalizeKeyPair has been called.
-	err = bcrypt.FinalizeKeyPair(hkey, 0)
+	hkey, err := generateKeyPair(h.handle, uint32(bits))
 	if err != nil {
 		return bad(err)
 	}
+	defer bcrypt.DestroyKey(hkey)
 
 	hdr, data, err := exportRSAKey(hkey, true)
 	if err != nil {
@@ -91,7 +91,7 @@ func NewPublicKeyRSA(N, E BigInt) (*PublicKeyRSA, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !keyIsAllowed(h.allowedKeyLengths, uint32(len(N)*8)) {
+	if !keyIsAllowed(h.allowedKeyLengths, uint32(len(N.normalize())*8)) {
 		return nil, errors.New("crypto/rsa: invalid key size")
 	}
 	hkey, err := importRSAKey(h.handle, N, E, nil, nil, nil, nil, nil, nil)
@@ -107,6 +107,36 @@ func (k *PublicKeyRSA) finalize() {
 	bcrypt.DestroyKey(k.hkey)
 }
 
+// Close destroys the key handle. It returns ErrClosed if k is already closed.
+// Close must not run concurrently with any other use of k, including
+// another Close: all the goroutines sharing k must be done with it first.
+func (k *PublicKeyRSA) Close() error {
+	if k.hkey == 0 {
+		return ErrClosed
+	}
+	runtime.SetFinalizer(k, nil)
+	bcrypt.DestroyKey(k.hkey)
+	k.hkey = 0
+	return nil
+}
+
+// N returns the modulus of k.
+func (k *PublicKeyRSA) N() (BigInt, error) {
+	defer runtime.KeepAlive(k)
+	return rsaComponent(k.hkey, rsaComponentN)
+}
+
+// E returns the public exponent of k.
+func (k *PublicKeyRSA) E() (BigInt, error) {
+	defer runtime.KeepAlive(k)
+	return rsaComponent(k.hkey, rsaComponentE)
+}
+
+// size returns the modulus size in bytes.
+func (k *PublicKeyRSA) size() int {
+	return int(k.bits+7) / 8
+}
+
 type PrivateKeyRSA struct {
 	hkey bcrypt.KEY_HANDLE
 	bits uint32
@@ -116,12 +146,28 @@ func (k *PrivateKeyRSA) finalize() {
 	bcrypt.DestroyKey(k.hkey)
 }
 
+// Close destroys the key handle, and with it the private key material,
+// which is only held by CNG. It returns ErrClosed if k is already closed.
+// Long-lived programs creating many keys should call Close instead of
+// waiting for the finalizer.
+// Close must not run concurrently with any other use of k, including
+// another Close: all the goroutines sharing k must be done with it first.
+func (k *PrivateKeyRSA) Close() error {
+	if k.hkey == 0 {
+		return ErrClosed
+	}
+	runtime.SetFinalizer(k, nil)
+	bcrypt.DestroyKey(k.hkey)
+	k.hkey = 0
+	return nil
+}
+
 func NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv BigInt) (*PrivateKeyRSA, error) {
 	h, err := loadRsa()
 	if err != nil {
 		return nil, err
 	}
-	if !keyIsAllowed(h.allowedKeyLengths, uint32(len(N)*8)) {
+	if !keyIsAllowed(h.allowedKeyLengths, uint32(len(N.normalize())*8)) {
 		return nil, errors.New("crypto/rsa: invalid key size")
 	}
 	hkey, err := importRSAKey(h.handle, N, E, D, P, Q, Dp, Dq, Qinv)
@@ -133,6 +179,82 @@ func NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv BigInt) (*PrivateKeyRSA, error
 	return k, nil
 }
 
+// Indices of the components of a BCRYPT_RSAFULLPRIVATE_BLOB, in blob order.
+const (
+	rsaComponentE = iota
+	rsaComponentN
+	rsaComponentP
+	rsaComponentQ
+	rsaComponentDp
+	rsaComponentDq
+	rsaComponentQinv
+	rsaComponentD
+)
+
+// N returns the modulus of k.
+func (k *PrivateKeyRSA) N() (BigInt, error) { return k.component(rsaComponentN) }
+
+// E returns the public exponent of k.
+func (k *PrivateKeyRSA) E() (BigInt, error) { return k.component(rsaComponentE) }
+
+// D returns the private exponent of k. The result is sensitive and should
+// be wiped, for example with Wipe, when no longer needed.
+func (k *PrivateKeyRSA) D() (BigInt, error) { return k.component(rsaComponentD) }
+
+// PrimeP returns the first prime factor of the modulus of k.
+// The result is sensitive, see D.
+func (k *PrivateKeyRSA) PrimeP() (BigInt, error) { return k.component(rsaComponentP) }
+
+// PrimeQ returns the second prime factor of the modulus of k.
+// The result is sensitive, see D.
+func (k *PrivateKeyRSA) PrimeQ() (BigInt, error) { return k.component(rsaComponentQ) }
+
+// Dp returns the CRT exponent D mod (P-1). The result is sensitive, see D.
+func (k *PrivateKeyRSA) Dp() (BigInt, error) { return k.component(rsaComponentDp) }
+
+// Dq returns the CRT exponent D mod (Q-1). The result is sensitive, see D.
+func (k *PrivateKeyRSA) Dq() (BigInt, error) { return k.component(rsaComponentDq) }
+
+// Qinv returns the CRT coefficient Q^-1 mod P. The result is sensitive, see D.
+func (k *PrivateKeyRSA) Qinv() (BigInt, error) { return k.component(rsaComponentQinv) }
+
+func (k *PrivateKeyRSA) component(i int) (BigInt, error) {
+	defer runtime.KeepAlive(k)
+	return rsaComponent(k.hkey, i)
+}
+
+// rsaComponent exports hkey and returns a normalized copy of the i-th blob component.
+// Only the public blob is exported for the public components.
+func rsaComponent(hkey bcrypt.KEY_HANDLE, i int) (BigInt, error) {
+	private := i > rsaComponentN
+	hdr, data, err := exportRSAKey(hkey, private)
+	if err != nil {
+		return nil, err
+	}
+	if private {
+		defer Wipe(data)
+	}
+	sizes := [...]uint32{
+		hdr.PublicExpSize, hdr.ModulusSize,
+		hdr.Prime1Size, hdr.Prime2Size,
+		hdr.Prime1Size, hdr.Prime2Size, hdr.Prime1Size,
+		hdr.ModulusSize,
+	}
+	var off uint64
+	for _, size := range sizes[:i] {
+		off += uint64(size)
+	}
+	end := off + uint64(sizes[i])
+	if end > uint64(len(data)) {
+		return nil, errors.New("crypto/rsa: exported key is corrupted")
+	}
+	b := data[off:end]
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	return append(BigInt{}, b...), nil
+}
+
 func importRSAKey(h bcrypt.ALG_HANDLE, N, E, D, P, Q, Dp, Dq, Qinv BigInt) (bcrypt.KEY_HANDLE, error) {
 	blob, err := encodeRSAKey(N, E, D, P, Q, Dp, Dq, Qinv)
 	if err != nil {
@@ -143,6 +265,8 @@ func importRSAKey(h bcrypt.ALG_HANDLE, N, E, D, P, Q, Dp, Dq, Qinv BigInt) (bcry
 		kind = bcrypt.RSAPUBLIC_KEY_BLOB
 	} else {
 		kind = bcrypt.RSAFULLPRIVATE_BLOB
+		// The blob contains a copy of the private key.
+		defer Wipe(blob)
 	}
 	var hkey bcrypt.KEY_HANDLE
 	err = bcrypt.ImportKeyPair(h, 0, utf16PtrFromString(kind), &hkey, blob, 0)
@@ -153,6 +277,9 @@ func importRSAKey(h bcrypt.ALG_HANDLE, N, E, D, P, Q, Dp, Dq, Qinv BigInt) (bcry
 }
 
 func encodeRSAKey(N, E, D, P, Q, Dp, Dq, Qinv BigInt) ([]byte, error) {
+	// The blob sizes are taken from N, E, P and Q, which must not
+	// carry leading zeros. The other values are padded to fit.
+	N, E, P, Q = N.normalize(), E.normalize(), P.normalize(), Q.normalize()
 	hdr := bcrypt.RSAKEY_BLOB{
 		BitLength:     uint32(len(N) * 8),
 		PublicExpSize: uint32(len(E)),
@@ -194,9 +321,55 @@ func DecryptRSAOAEP(h hash.Hash, priv *PrivateKeyRSA, ciphertext, label []byte)
 
 func EncryptRSAOAEP(h hash.Hash, pub *PublicKeyRSA, msg, label []byte) ([]byte, error) {
 	defer runtime.KeepAlive(pub)
+	if err := checkRSAMessageLen(msg, pub.size()-2*h.Size()-2); err != nil {
+		return nil, err
+	}
 	return rsaOAEP(h, pub.hkey, msg, label, true)
 }
 
+// DecryptRSAOAEPWithMGF is like DecryptRSAOAEP but uses mgfHash
+// as the MGF1 hash instead of h, as allowed by RFC 8017.
+func DecryptRSAOAEPWithMGF(h, mgfHash hash.Hash, priv *PrivateKeyRSA, ciphertext, label []byte) ([]byte, error) {
+	if hashToID(h) == hashToID(mgfHash) {
+		return DecryptRSAOAEP(h, priv, ciphertext, label)
+	}
+	if hashToID(h) == "" || hashToID(mgfHash) == "" {
+		return nil, errors.New("crypto/rsa: unsupported hash function")
+	}
+	// CNG only supports a single OAEP hash, so unpad manually
+	// on top of the raw RSA primitive.
+	k := int(priv.bits+7) / 8
+	if len(ciphertext) > k || k < 2*h.Size()+2 {
+		return nil, errRSADecryption
+	}
+	out, err := DecryptRSANoPadding(priv, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	em := make([]byte, k)
+	copy(em[k-len(out):], out)
+	defer Wipe(em)
+	return unpadOAEP(h, mgfHash, em, label)
+}
+
+// EncryptRSAOAEPWithMGF is like EncryptRSAOAEP but uses mgfHash
+// as the MGF1 hash instead of h, as allowed by RFC 8017.
+func EncryptRSAOAEPWithMGF(h, mgfHash hash.Hash, pub *PublicKeyRSA, msg, label []byte) ([]byte, error) {
+	if hashToID(h) == hashToID(mgfHash) {
+		return EncryptRSAOAEP(h, pub, msg, label)
+	}
+	if hashToID(h) == "" || hashToID(mgfHash) == "" {
+		return nil, errors.New("crypto/rsa: unsupported hash function")
+	}
+	// CNG only supports a single OAEP hash, so pad manually
+	// on top of the raw RSA primitive.
+	em, err := padOAEP(h, mgfHash, pub.size(), msg, label)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptRSANoPadding(pub, em)
+}
+
 func DecryptRSAPKCS1(priv *PrivateKeyRSA, ciphertext []byte) ([]byte, error) {
 	defer runtime.KeepAlive(priv)
 	return rsaCrypt(priv.hkey, nil, ciphertext, bcrypt.PAD_PKCS1, false)
@@ -204,6 +377,9 @@ func DecryptRSAPKCS1(priv *PrivateKeyRSA, ciphertext []byte) ([]byte, error) {
 
 func EncryptRSAPKCS1(pub *PublicKeyRSA, msg []byte) ([]byte, error) {
 	defer runtime.KeepAlive(pub)
+	if err := checkRSAMessageLen(msg, pub.size()-11); err != nil {
+		return nil, err
+	}
 	return rsaCrypt(pub.hkey, nil, msg, bcrypt.PAD_PKCS1, true)
 }
 
@@ -220,6 +396,9 @@ func EncryptRSANoPadding(pub *PublicKeyRSA, msg []byte) ([]byte, error) {
 
 func SignRSAPSS(priv *PrivateKeyRSA, h crypto.Hash, hashed []byte, saltLen int) ([]byte, error) {
 	defer runtime.KeepAlive(priv)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditSign, Algorithm: "RSA-PSS", KeyBits: int(priv.bits)}, perfCounter())
+	}
 	info, err := newPSS_PADDING_INFO(h, priv.bits, saltLen, true)
 	if err != nil {
 		return nil, err
@@ -229,6 +408,9 @@ func SignRSAPSS(priv *PrivateKeyRSA, h crypto.Hash, hashed []byte, saltLen int)
 
 func VerifyRSAPSS(pub *PublicKeyRSA, h crypto.Hash, hashed, sig []byte, saltLen int) error {
 	defer runtime.KeepAlive(pub)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditVerify, Algorithm: "RSA-PSS", KeyBits: int(pub.bits)}, perfCounter())
+	}
 	info, err := newPSS_PADDING_INFO(h, pub.bits, saltLen, false)
 	if err != nil {
 		return err
@@ -238,6 +420,9 @@ func VerifyRSAPSS(pub *PublicKeyRSA, h crypto.Hash, hashed, sig []byte, saltLen
 
 func SignRSAPKCS1v15(priv *PrivateKeyRSA, h crypto.Hash, hashed []byte) ([]byte, error) {
 	defer runtime.KeepAlive(priv)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditSign, Algorithm: "RSA-PKCS1v15", KeyBits: int(priv.bits)}, perfCounter())
+	}
 	info, err := newPKCS1_PADDING_INFO(h)
 	if err != nil {
 		return nil, err
@@ -247,6 +432,9 @@ func SignRSAPKCS1v15(priv *PrivateKeyRSA, h crypto.Hash, hashed []byte) ([]byte,
 
 func VerifyRSAPKCS1v15(pub *PublicKeyRSA, h crypto.Hash, hashed, sig []byte) error {
 	defer runtime.KeepAlive(pub)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditVerify, Algorithm: "RSA-PKCS1v15", KeyBits: int(pub.bits)}, perfCounter())
+	}
 	info, err := newPKCS1_PADDING_INFO(h)
 	if err != nil {
 		return err
@@ -255,6 +443,9 @@ func VerifyRSAPKCS1v15(pub *PublicKeyRSA, h crypto.Hash, hashed, sig []byte) err
 }
 
 func rsaCrypt(pkey bcrypt.KEY_HANDLE, info unsafe.Pointer, in []byte, flags bcrypt.PadMode, encrypt bool) ([]byte, error) {
+	if pkey == 0 {
+		return nil, ErrClosed
+	}
 	var size uint32
 	var err error
 	if encrypt {
@@ -293,6 +484,10 @@ func rsaOAEP(h hash.Hash, pkey bcrypt.KEY_HANDLE, in, label []byte, encrypt bool
 }
 
 func keySign(pkey bcrypt.KEY_HANDLE, info unsafe.Pointer, hashed []byte, flags bcrypt.PadMode) ([]byte, error) {
+	if pkey == 0 {
+		return nil, ErrClosed
+	}
+	defer releaseOp(acquireOp())
 	var size uint32
 	err := bcrypt.SignHash(pkey, info, hashed, nil, &size, flags)
 	if err != nil {
@@ -307,6 +502,10 @@ func keySign(pkey bcrypt.KEY_HANDLE, info unsafe.Pointer, hashed []byte, flags b
 }
 
 func keyVerify(pkey bcrypt.KEY_HANDLE, info unsafe.Pointer, hashed, sig []byte, flags bcrypt.PadMode) error {
+	if pkey == 0 {
+		return ErrClosed
+	}
+	defer releaseOp(acquireOp())
 	return bcrypt.VerifySignature(pkey, info, hashed, sig, flags)
 }
 
@@ -372,3 +571,126 @@ func cryptoHashToID(ch crypto.Hash) string {
 	}
 	return ""
 }
+
+// idToCryptoHash returns the crypto.Hash identified by the CNG hash id,
+// or 0 if there is none.
+func idToCryptoHash(id string) crypto.Hash {
+	switch id {
+	case bcrypt.MD4_ALGORITHM:
+		return crypto.MD4
+	case bcrypt.MD5_ALGORITHM:
+		return crypto.MD5
+	case bcrypt.SHA1_ALGORITHM:
+		return crypto.SHA1
+	case bcrypt.SHA256_ALGORITHM:
+		return crypto.SHA256
+	case bcrypt.SHA384_ALGORITHM:
+		return crypto.SHA384
+	case bcrypt.SHA512_ALGORITHM:
+		return crypto.SHA512
+	case bcrypt.SHA3_256_ALGORITHM:
+		return crypto.SHA3_256
+	case bcrypt.SHA3_384_ALGORITHM:
+		return crypto.SHA3_384
+	case bcrypt.SHA3_512_ALGORITHM:
+		return crypto.SHA3_512
+	}
+	return 0
+}
+
+var errRSADecryption = errors.New("crypto/rsa: decryption error")
+
+// checkRSAMessageLen returns an error if msg is longer than max bytes,
+// the maximum message length for the key size and padding scheme.
+func checkRSAMessageLen(msg []byte, max int) error {
+	if max < 0 {
+		return errors.New("crypto/rsa: key size too small for padding scheme")
+	}
+	if len(msg) > max {
+		return errors.New("crypto/rsa: message too long for RSA key size: " + itoa(len(msg)) + " bytes, maximum is " + itoa(max))
+	}
+	return nil
+}
+
+// padOAEP returns the k-byte EME-OAEP encoding of msg,
+// as specified in RFC 8017, Section 7.1.1.
+func padOAEP(h, mgfHash hash.Hash, k int, msg, label []byte) ([]byte, error) {
+	hLen := h.Size()
+	if err := checkRSAMessageLen(msg, k-2*hLen-2); err != nil {
+		return nil, err
+	}
+	h.Reset()
+	h.Write(label)
+	lHash := h.Sum(nil)
+	h.Reset()
+
+	em := make([]byte, k)
+	seed := em[1 : 1+hLen]
+	db := em[1+hLen:]
+	copy(db[:hLen], lHash)
+	db[len(db)-len(msg)-1] = 1
+	copy(db[len(db)-len(msg):], msg)
+	if _, err := io.ReadFull(randomReader(), seed); err != nil {
+		return nil, err
+	}
+	mgf1XOR(db, mgfHash, seed)
+	mgf1XOR(seed, mgfHash, db)
+	return em, nil
+}
+
+// unpadOAEP decodes the k-byte EME-OAEP encoded message em,
+// as specified in RFC 8017, Section 7.1.2.
+// It runs in constant time with respect to the padding contents.
+func unpadOAEP(h, mgfHash hash.Hash, em, label []byte) ([]byte, error) {
+	hLen := h.Size()
+	h.Reset()
+	h.Write(label)
+	lHash := h.Sum(nil)
+	h.Reset()
+
+	firstByteIsZero := subtle.ConstantTimeByteEq(em[0], 0)
+	seed := em[1 : hLen+1]
+	db := em[hLen+1:]
+	mgf1XOR(seed, mgfHash, db)
+	mgf1XOR(db, mgfHash, seed)
+	lHash2Good := subtle.ConstantTimeCompare(lHash, db[:hLen])
+
+	// The remainder of db must be zero or more 0x00, followed by 0x01,
+	// followed by the message.
+	var lookingForIndex, index, invalid int
+	lookingForIndex = 1
+	rest := db[hLen:]
+	for i := 0; i < len(rest); i++ {
+		equals0 := subtle.ConstantTimeByteEq(rest[i], 0)
+		equals1 := subtle.ConstantTimeByteEq(rest[i], 1)
+		index = subtle.ConstantTimeSelect(lookingForIndex&equals1, i, index)
+		lookingForIndex = subtle.ConstantTimeSelect(equals1, 0, lookingForIndex)
+		invalid = subtle.ConstantTimeSelect(lookingForIndex&^equals0, 1, invalid)
+	}
+	if firstByteIsZero&lHash2Good&^invalid&^lookingForIndex != 1 {
+		return nil, errRSADecryption
+	}
+	msg := make([]byte, len(rest)-index-1)
+	copy(msg, rest[index+1:])
+	return msg, nil
+}
+
+// mgf1XOR XORs the bytes in out with a mask generated using the MGF1 function
+// specified in RFC 8017, Appendix B.2.1.
+func mgf1XOR(out []byte, h hash.Hash, seed []byte) {
+	var counter [4]byte
+	var digest []byte
+	done := 0
+	for done < len(out) {
+		h.Reset()
+		h.Write(seed)
+		h.Write(counter[:])
+		digest = h.Sum(digest[:0])
+		for i := 0; i < len(digest) && done < len(out); i++ {
+			out[done] ^= digest[i]
+			done++
+		}
+		binary.BigEndian.PutUint32(counter[:], binary.BigEndian.Uint32(counter[:])+1)
+	}
+	h.Reset()
+}
@@ -0,0 +1,43 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows && go1.20
+// +build windows,go1.20
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+	"github.com/microsoft/go-crypto-winnative/cng/bbig"
+)
+
+func TestEncryptRSAOAEPWithMGF_Interop(t *testing.T) {
+	privGo, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyRSA(bbig.Enc(privGo.N), bbig.Enc(big.NewInt(int64(privGo.E))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hi!")
+	label := []byte("ho!")
+	enc, err := cng.EncryptRSAOAEPWithMGF(cng.NewSHA256(), cng.NewSHA1(), pub, msg, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := privGo.Decrypt(nil, enc, &rsa.OAEPOptions{Hash: crypto.SHA256, MGFHash: crypto.SHA1, Label: label})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, msg) {
+		t.Errorf("got:%x want:%x", dec, msg)
+	}
+}
@@ -10,9 +10,12 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/sha256"
 	"math/big"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/microsoft/go-crypto-winnative/cng"
@@ -116,6 +119,95 @@ func TestEncryptDecryptOAEP_WrongLabel(t *testing.T) {
 	}
 }
 
+func TestEncryptDecryptOAEPWithMGF(t *testing.T) {
+	msg := []byte("hi!")
+	label := []byte("ho!")
+	priv, pub := newRSAKey(t, 2048)
+	enc, err := cng.EncryptRSAOAEPWithMGF(cng.NewSHA256(), cng.NewSHA1(), pub, msg, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := cng.DecryptRSAOAEPWithMGF(cng.NewSHA256(), cng.NewSHA1(), priv, enc, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, msg) {
+		t.Errorf("got:%x want:%x", dec, msg)
+	}
+	if _, err := cng.DecryptRSAOAEPWithMGF(cng.NewSHA256(), cng.NewSHA1(), priv, enc, []byte("wrong!")); err == nil {
+		t.Error("error expected with wrong label")
+	}
+	if _, err := cng.DecryptRSAOAEP(cng.NewSHA256(), priv, enc, label); err == nil {
+		t.Error("error expected with wrong MGF hash")
+	}
+	if _, err := cng.EncryptRSAOAEPWithMGF(cng.NewSHA256(), cng.NewSHA1(), pub, make([]byte, 256-2*32-1), label); err == nil {
+		t.Error("error expected with message too long")
+	}
+}
+
+func TestEncryptDecryptOAEPWithMGF_SameHash(t *testing.T) {
+	msg := []byte("hi!")
+	priv, pub := newRSAKey(t, 2048)
+	enc, err := cng.EncryptRSAOAEPWithMGF(cng.NewSHA256(), cng.NewSHA256(), pub, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := cng.DecryptRSAOAEP(cng.NewSHA256(), priv, enc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, msg) {
+		t.Errorf("got:%x want:%x", dec, msg)
+	}
+}
+
+func TestEncryptRSAMessageTooLong(t *testing.T) {
+	priv, pub := newRSAKey(t, 2048)
+	const k = 2048 / 8
+	tests := []struct {
+		name    string
+		max     int
+		encrypt func(msg []byte) ([]byte, error)
+		decrypt func(ciphertext []byte) ([]byte, error)
+	}{
+		{
+			"OAEP", k - 2*sha256.Size - 2,
+			func(msg []byte) ([]byte, error) { return cng.EncryptRSAOAEP(cng.NewSHA256(), pub, msg, nil) },
+			func(ciphertext []byte) ([]byte, error) {
+				return cng.DecryptRSAOAEP(cng.NewSHA256(), priv, ciphertext, nil)
+			},
+		},
+		{
+			"PKCS1v15", k - 11,
+			func(msg []byte) ([]byte, error) { return cng.EncryptRSAPKCS1(pub, msg) },
+			func(ciphertext []byte) ([]byte, error) { return cng.DecryptRSAPKCS1(priv, ciphertext) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := bytes.Repeat([]byte{'a'}, tt.max)
+			enc, err := tt.encrypt(msg)
+			if err != nil {
+				t.Fatalf("max length message: %v", err)
+			}
+			dec, err := tt.decrypt(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(dec, msg) {
+				t.Errorf("got:%x want:%x", dec, msg)
+			}
+			_, err = tt.encrypt(append(msg, 'a'))
+			if err == nil {
+				t.Fatal("expected error for too long message")
+			}
+			if want := "maximum is " + strconv.Itoa(tt.max); !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %q", err, want)
+			}
+		})
+	}
+}
+
 func TestEncryptDecryptNoPadding(t *testing.T) {
 	const bits = 2048
 	var msg [bits / 8]byte
@@ -214,6 +306,139 @@ func TestSignVerifyRSAPSS(t *testing.T) {
 	}
 }
 
+// TestSignRSAPSSWithSalt checks a signature over SHA-256("abc") with a
+// fixed salt, computed independently with crypto/rsa by feeding the salt
+// as its random source.
+func TestSignRSAPSSWithSalt(t *testing.T) {
+	dec := func(s string) cng.BigInt { return cng.BigInt(hexDecode(t, s)) }
+	N := dec("b034654bedec17464873b411644a3d3f5621bce216af5c85f56bab64a771c211" +
+		"3f5cf1fcf6908c1428188bd6da336dc20732689f0d2ec37dbb19184b4327abbd" +
+		"6bf91fd46129d007ecd9df1183f47c70ebe705fb54390a22812ef5a5b252a55e" +
+		"caedd23d4027ded207aa98ccc0dc6240906df456c1008ef6159c2077860f04c1")
+	E := dec("010001")
+	priv, err := cng.NewPrivateKeyRSA(N, E,
+		dec("08d9171a491352caac33b3f96265f38d96bdf12910d38d4224640f0250683243"+
+			"0e0e61dccb4c69dde8d9204bb7f2abc5c692f0eef94930c3a9d0cbe19ad260a6"+
+			"5e895575f3cad98950021b14ed35de141094df0245802fa3a5f52e955c819b8b"+
+			"8400d997974875ba0f50735f383ece80e9cd5fe61764d501ccd4538299e66eb3"),
+		dec("cc85da972e909068ddfe09bc3e4b3d66ca26ba3624b3f08112056da79495762d"+
+			"92605bbe3d11941e04b1f821c79d578d5b08a1f7fd5cf93ba0496a6f5a3ef9e7"),
+		dec("dc8de5f66d7e54b29871e837d2427c75d3eb50930a90a819ce9fc4ec21c1618b"+
+			"4a5e864acb1af162027af56041353e83bb93c1335b99b8936d96b4577c3cc717"),
+		dec("af0367df71005222fabc15769953e7321b90724e3cb0220140fb962f0e0dabf8"+
+			"2946fd9ad8453f9b44674aaf1552c605f73d76e47051ec10a303cfe983af5a47"),
+		dec("10fcfd11962d7ce8a4c56155ab6463a1cfc8f853db24794e43941adef55aa7e0"+
+			"cac1ad2665ae95992f07b98ec8770971ac291d608f55afd9eb3f087514a4b9d1"),
+		dec("bfed6831e281bbf10a7bbdb881da14cbd06ab931a12a1b398137f5b00c10ee58"+
+			"cbb8d775ada9b2918349efb83fbbd7db91b89f62a8914e7d2bac44395cbd0544"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyRSA(N, E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := hexDecode(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+	salt := hexDecode(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	want := hexDecode(t, "59a651ac431d23d817f6d8e76533a3e5cb9da85f81af8a61b8fd20bda4b28d7b"+
+		"8a4cf7d845c3544f6a9944cffa667d31a20ff9d0dc6958ede033e4eb3d79ef3e"+
+		"424e9ccd7860b0c26d5e527fab125bcdf3bd1c6161d2ed8066f295090b90889b"+
+		"a2a56270954a4d9d4704682315b2fe8c077ea583429f9d0f4aaec60e7a73d76b")
+	sig, err := cng.SignRSAPSSWithSalt(priv, hashed, salt, "SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig, want) {
+		t.Errorf("got %x, want %x", sig, want)
+	}
+	if err := cng.VerifyRSAPSS(pub, crypto.SHA256, hashed, sig, len(salt)); err != nil {
+		t.Errorf("VerifyRSAPSS: %v", err)
+	}
+
+	if _, err := cng.SignRSAPSSWithSalt(priv, hashed[:31], salt, "SHA256"); err == nil {
+		t.Error("expected error for a digest of the wrong size")
+	}
+	if _, err := cng.SignRSAPSSWithSalt(priv, hashed, make([]byte, 95), "SHA256"); err == nil {
+		t.Error("expected error for a salt too long for the key")
+	}
+	if _, err := cng.SignRSAPSSWithSalt(priv, hashed, salt, "NOTAHASH"); err == nil {
+		t.Error("expected error for unknown hash")
+	}
+}
+
+// TestSignRSAPSSWithSaltVector checks RSASSA-PSS Signature Example 10.1
+// from the PKCS #1 v2.1 test vectors (pss-vect.txt), which specify the
+// salt, against a 2048-bit key with SHA-1.
+func TestSignRSAPSSWithSaltVector(t *testing.T) {
+	dec := func(s string) cng.BigInt { return cng.BigInt(hexDecode(t, s)) }
+	N := dec("a5dd867ac4cb02f90b9457d48c14a770ef991c56c39c0ec65fd11afa8937cea5" +
+		"7b9be7ac73b45c0017615b82d622e318753b6027c0fd157be12f8090fee2a7ad" +
+		"cd0eef759f88ba4997c7a42d58c9aa12cb99ae001fe521c13bb5431445a8d5ae" +
+		"4f5e4c7e948ac227d3604071f20e577e905fbeb15dfaf06d1de5ae6253d63a6a" +
+		"2120b31a5da5dabc9550600e20f27d3739e2627925fea3cc509f21dff04e6eea" +
+		"4549c540d6809ff9307eede91fff58733d8385a237d6d3705a33e39190099207" +
+		"0df7adf1357cf7e3700ce3667de83f17b8df1778db381dce09cb4ad058a51100" +
+		"1a738198ee27cf55a13b754539906582ec8b174bd58d5d1f3d767c613721ae05")
+	E := dec("010001")
+	D := dec("2d2ff567b3fe74e06191b7fded6de112290c670692430d5969184047da234c96" +
+		"93deed1673ed429539c969d372c04d6b47e0f5b8cee0843e5c22835dbd3b05a0" +
+		"997984ae6058b11bc4907cbf67ed84fa9ae252dfb0d0cd49e618e35dfdfe59bc" +
+		"a3ddd66c33cebbc77ad441aa695e13e324b518f01c60f5a85c994ad179f2a6b5" +
+		"fbe93402b11767be01bf073444d6ba1dd2bca5bd074d4a5fae3531ad1303d84b" +
+		"30d897318cbbba04e03c2e66de6d91f82f96ea1d4bb54a5aae102d594657f5c9" +
+		"789553512b296dea29d8023196357e3e3a6e958f39e3c2344038ea604b31edc6" +
+		"f0f7ff6e7181a57c92826a268f86768e96f878562fc71d85d69e448612f7048f")
+	P := dec("cfd50283feeeb97f6f08d73cbc7b3836f82bbcd499479f5e6f76fdfcb8b38c4f" +
+		"71dc9e88bd6a6f76371afd65d2af1862b32afb34a95f71b8b132043ffebe3a95" +
+		"2baf7592448148c03f9c69b1d68e4ce5cf32c86baf46fed301ca1ab403069b32" +
+		"f456b91f71898ab081cd8c4252ef5271915c9794b8f295851da7510f99cb73eb")
+	Q := dec("cc4e90d2a1b3a065d3b2d1f5a8fce31b544475664eab561d2971b99fb7bef844" +
+		"e8ec1f360b8c2ac8359692971ea6a38f723fcc211f5dbcb177a0fdac5164a1d4" +
+		"ff7fbb4e829986353cb983659a148cdd420c7d31ba3822ea90a32be46c030e8c" +
+		"17e1fa0ad37859e06b0aa6fa3b216d9cbe6c0e22339769c0a615913e5da719cf")
+	Dp := dec("1c2d1fc32f6bc4004fd85dfde0fbbf9a4c38f9c7c4e41dea1aa88234a201cd92" +
+		"f3b7da526583a98ad85bb360fb983b711e23449d561d1778d7a515486bcbf47b" +
+		"46c9e9e1a3a1f77000efbeb09a8afe47e5b857cda99cb16d7fff9b712e3bd60c" +
+		"a96d9c7973d616d46934a9c050281c004399ceff1db7dda78766a8a9b9cb0873")
+	Dq := dec("cb3b3c04caa58c60be7d9b2debb3e39643f4f57397be08236a1e9eafaa706536" +
+		"e71c3acfe01cc651f23c9e05858fee13bb6a8afc47df4edc9a4ba30bcecb73d0" +
+		"157852327ee789015c2e8dee7b9f05a0f31ac94eb6173164740c5c95147cd5f3" +
+		"b5ae2cb4a83787f01d8ab31f27c2d0eea2dd8a11ab906aba207c43c6ee125331")
+	Qinv := dec("12f6b2cf1374a736fad05616050f96ab4b61d1177c7f9d525a29f3d180e77667" +
+		"e99d99abf0525d0758660f3752655b0f25b8df8431d9a8ff77c16c12a0a5122a" +
+		"9f0bf7cfd5a266a35c159f991208b90316ff444f3e0b6bd0e93b8a7a2448e957" +
+		"e3dda6cfcf2266b106013ac46808d3b3887b3b00344baac9530b4ce708fc32b6")
+	priv, err := cng.NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyRSA(N, E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := hexDecode(t, "883177e5126b9be2d9a9680327d5370c6f26861f5820c43da67a3ad609")
+	salt := hexDecode(t, "04e215ee6ff934b9da70d7730c8734abfcecde89")
+	want := hexDecode(t, "82c2b160093b8aa3c0f7522b19f87354066c77847abf2a9fce542d0e84e920c5"+
+		"afb49ffdfdace16560ee94a1369601148ebad7a0e151cf16331791a5727d05f2"+
+		"1e74e7eb811440206935d744765a15e79f015cb66c532c87a6a05961c8bfad74"+
+		"1a9a6657022894393e7223739796c02a77455d0f555b0ec01ddf259b6207fd0f"+
+		"d57614cef1a5573baaff4ec00069951659b85f24300a25160ca8522dc6e6727e"+
+		"57d019d7e63629b8fe5e89e25cc15beb3a647577559299280b9b28f79b040900"+
+		"0be25bbd96408ba3b43cc486184dd1c8e62553fa1af4040f60663de7f5e49c04"+
+		"388e257f1ce89c95dab48a315d9b66b1b7628233876ff2385230d070d07e1666")
+	hashed := sha1.Sum(msg)
+	sig, err := cng.SignRSAPSSWithSalt(priv, hashed[:], salt, "SHA1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig, want) {
+		t.Errorf("got %x, want %x", sig, want)
+	}
+	if err := cng.VerifyRSAPSS(pub, crypto.SHA1, hashed[:], sig, len(salt)); err != nil {
+		t.Errorf("VerifyRSAPSS: %v", err)
+	}
+}
+
 func fromBase36(base36 string) *big.Int {
 	i, ok := new(big.Int).SetString(base36, 36)
 	if !ok {
@@ -270,3 +495,157 @@ func TestSignWithPSSSaltLengthAuto(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPrivateKeyRSAComponents(t *testing.T) {
+	N, E, D, P, Q, Dp, Dq, Qinv, err := cng.GenerateKeyRSA(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := cng.NewPrivateKeyRSA(N, E, D, P, Q, Dp, Dq, Qinv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(name string, f func() (cng.BigInt, error), want cng.BigInt) *big.Int {
+		t.Helper()
+		b, err := f()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(b) > 0 && b[0] == 0 {
+			t.Errorf("%s is not normalized: %x", name, b)
+		}
+		got := bbig.Dec(b)
+		if got.Cmp(bbig.Dec(want)) != 0 {
+			t.Errorf("%s = %x, want %x", name, got, bbig.Dec(want))
+		}
+		return got
+	}
+	n := get("N", priv.N, N)
+	e := get("E", priv.E, E)
+	d := get("D", priv.D, D)
+	p := get("PrimeP", priv.PrimeP, P)
+	q := get("PrimeQ", priv.PrimeQ, Q)
+	dp := get("Dp", priv.Dp, Dp)
+	dq := get("Dq", priv.Dq, Dq)
+	qinv := get("Qinv", priv.Qinv, Qinv)
+
+	one := big.NewInt(1)
+	pm1 := new(big.Int).Sub(p, one)
+	qm1 := new(big.Int).Sub(q, one)
+	if new(big.Int).Mul(p, q).Cmp(n) != 0 {
+		t.Error("P*Q != N")
+	}
+	if new(big.Int).Mod(d, pm1).Cmp(dp) != 0 {
+		t.Error("Dp != D mod (P-1)")
+	}
+	if new(big.Int).Mod(d, qm1).Cmp(dq) != 0 {
+		t.Error("Dq != D mod (Q-1)")
+	}
+	if new(big.Int).Mod(new(big.Int).Mul(qinv, q), p).Cmp(one) != 0 {
+		t.Error("Qinv*Q != 1 mod P")
+	}
+	if new(big.Int).Mod(new(big.Int).Mul(e, dp), pm1).Cmp(one) != 0 {
+		t.Error("E*Dp != 1 mod (P-1)")
+	}
+	if new(big.Int).Mod(new(big.Int).Mul(e, dq), qm1).Cmp(one) != 0 {
+		t.Error("E*Dq != 1 mod (Q-1)")
+	}
+}
+
+func TestRSAKeyClose(t *testing.T) {
+	priv, pub := newRSAKey(t, 2048)
+	hashed := make([]byte, 32)
+	sig, err := cng.SignRSAPKCS1v15(priv, crypto.SHA256, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.Close(); err != cng.ErrClosed {
+		t.Errorf("second Close = %v, want ErrClosed", err)
+	}
+	if _, err := cng.SignRSAPKCS1v15(priv, crypto.SHA256, hashed); err != cng.ErrClosed {
+		t.Errorf("SignRSAPKCS1v15 after Close = %v, want ErrClosed", err)
+	}
+	if _, err := cng.DecryptRSAPKCS1(priv, sig); err != cng.ErrClosed {
+		t.Errorf("DecryptRSAPKCS1 after Close = %v, want ErrClosed", err)
+	}
+	if _, err := priv.D(); err != cng.ErrClosed {
+		t.Errorf("D after Close = %v, want ErrClosed", err)
+	}
+	if err := cng.VerifyRSAPKCS1v15(pub, crypto.SHA256, hashed, sig); err != nil {
+		t.Errorf("Verify failed after closing the private key: %v", err)
+	}
+	if err := pub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cng.VerifyRSAPKCS1v15(pub, crypto.SHA256, hashed, sig); err != cng.ErrClosed {
+		t.Errorf("VerifyRSAPKCS1v15 after Close = %v, want ErrClosed", err)
+	}
+}
+
+// TestRSAKeyCloseAfterConcurrentUse exercises the Close contract: k may
+// be shared by goroutines, and closed once they are all done with it.
+// Run with -race to check that this usage is free of data races.
+func TestRSAKeyCloseAfterConcurrentUse(t *testing.T) {
+	priv, pub := newRSAKey(t, 2048)
+	hashed := make([]byte, 32)
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sig, err := cng.SignRSAPKCS1v15(priv, crypto.SHA256, hashed)
+			if err == nil {
+				err = cng.VerifyRSAPKCS1v15(pub, crypto.SHA256, hashed, sig)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if err := priv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRSALeadingZeros(t *testing.T) {
+	N, E, D, P, Q, Dp, Dq, Qinv, err := cng.GenerateKeyRSA(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wide := func(b cng.BigInt) cng.BigInt { return append(cng.BigInt{0, 0, 0}, b...) }
+	priv, err := cng.NewPrivateKeyRSA(wide(N), wide(E), wide(D), wide(P), wide(Q), wide(Dp), wide(Dq), wide(Qinv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyRSA(wide(N), wide(E))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := make([]byte, 32)
+	sig, err := cng.SignRSAPKCS1v15(priv, crypto.SHA256, hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sig) != 256 {
+		t.Errorf("signature length = %d, want 256", len(sig))
+	}
+	stdPub := &rsa.PublicKey{N: bbig.Dec(N), E: int(bbig.Dec(E).Int64())}
+	if err := rsa.VerifyPKCS1v15(stdPub, crypto.SHA256, hashed, sig); err != nil {
+		t.Error(err)
+	}
+	if err := cng.VerifyRSAPKCS1v15(pub, crypto.SHA256, hashed, sig); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// SignRSAPSSWithSalt signs hashed, the digest computed with the hash
+// identified by hashID, such as "SHA256", with RSASSA-PSS using the given
+// salt instead of a random one, and MGF1 with the same hash.
+//
+// It is meant to reproduce test vectors, which specify the salt: a
+// signature with a fixed salt is deterministic, which PSS doesn't need
+// but which defeats the purpose of its randomization. Use SignRSAPSS
+// otherwise.
+//
+// BCRYPT_PSS_PADDING_INFO only takes a salt length, so the EMSA-PSS
+// encoding of RFC 8017, Section 9.1.1, is done here and signed with the
+// raw RSA private key operation.
+func SignRSAPSSWithSalt(priv *PrivateKeyRSA, hashed, salt []byte, hashID string) ([]byte, error) {
+	defer runtime.KeepAlive(priv)
+	if hook := auditHook(); hook != nil {
+		defer auditDone(hook, AuditEvent{Op: AuditSign, Algorithm: "RSA-PSS", KeyBits: int(priv.bits)}, perfCounter())
+	}
+	if _, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG); err != nil {
+		return nil, err
+	}
+	h := newHashX(hashID, bcrypt.ALG_NONE_FLAG, nil)
+	defer h.Close()
+	em, err := emsaPSSEncode(h, hashed, salt, int(priv.bits)-1)
+	if err != nil {
+		return nil, err
+	}
+	k := int(priv.bits+7) / 8
+	if len(em) < k {
+		// The encoded message is one byte shorter than the
+		// modulus when its bit length is a multiple of 8 plus 1.
+		em = append(make([]byte, k-len(em)), em...)
+	}
+	sig, err := rsaCrypt(priv.hkey, nil, em, bcrypt.PAD_NONE, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) < k {
+		sig = append(make([]byte, k-len(sig)), sig...)
+	}
+	return sig, nil
+}
+
+// emsaPSSEncode implements EMSA-PSS-ENCODE from RFC 8017, Section 9.1.1,
+// for a message representative of at most emBits bits.
+func emsaPSSEncode(h *hashX, mHash, salt []byte, emBits int) ([]byte, error) {
+	hLen := h.Size()
+	sLen := len(salt)
+	emLen := (emBits + 7) / 8
+	if len(mHash) != hLen {
+		return nil, errors.New("crypto/rsa: input must be hashed with given hash")
+	}
+	if emLen < hLen+sLen+2 {
+		return nil, errors.New("crypto/rsa: key size too small for PSS signature")
+	}
+
+	em := make([]byte, emLen)
+	psLen := emLen - sLen - hLen - 2
+	db := em[:psLen+1+sLen]
+	hash := em[psLen+1+sLen : emLen-1]
+
+	// H = Hash(0x00 x 8 || mHash || salt)
+	var prefix [8]byte
+	h.Reset()
+	h.Write(prefix[:])
+	h.Write(mHash)
+	h.Write(salt)
+	hash = h.Sum(hash[:0])
+
+	// DB = PS || 0x01 || salt, where PS is all zeros.
+	db[psLen] = 0x01
+	copy(db[psLen+1:], salt)
+	mgf1XOR(db, h, hash)
+
+	// Clear the leftmost 8 * emLen - emBits bits of the masked DB.
+	db[0] &= 0xff >> (8*emLen - emBits)
+	em[emLen-1] = 0xbc
+	return em, nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// signatureContextTag is the fixed domain-separation tag prepended
+// to every message signed using a context string.
+const signatureContextTag = "go-crypto-winnative signature context"
+
+// maxSignatureContextLen is the maximum length of a context string,
+// which is the same limit Ed25519ctx imposes in RFC 8032.
+const maxSignatureContextLen = 255
+
+// hashWithContext hashes msg using h after prepending the domain-separation
+// tag and the length-prefixed context, so that signatures produced
+// with different contexts can't be reused across protocols.
+func hashWithContext(h crypto.Hash, context, msg []byte) ([]byte, error) {
+	if len(context) > maxSignatureContextLen {
+		return nil, errors.New("cng: signature context too long")
+	}
+	id := cryptoHashToID(h)
+	if id == "" {
+		return nil, errors.New("cng: unsupported hash function")
+	}
+	hx := newHashX(id, bcrypt.ALG_NONE_FLAG, nil)
+	hx.WriteString(signatureContextTag)
+	hx.WriteByte(byte(len(context)))
+	hx.Write(context)
+	hx.Write(msg)
+	return hx.Sum(nil), nil
+}
+
+// SignECDSAWithContext hashes msg using h, binding the hash to context,
+// and signs it using the private key, priv.
+//
+// Signatures generated with a given context can only be verified
+// by VerifyECDSAWithContext using the same context.
+// Ed25519ctx is not supported because CNG does not implement Ed25519.
+func SignECDSAWithContext(priv *PrivateKeyECDSA, h crypto.Hash, context, msg []byte) (r, s BigInt, err error) {
+	hashed, err := hashWithContext(h, context, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return SignECDSA(priv, hashed)
+}
+
+// VerifyECDSAWithContext verifies the signature in r, s of msg bound to context
+// using the public key, pub.
+func VerifyECDSAWithContext(pub *PublicKeyECDSA, h crypto.Hash, context, msg []byte, r, s BigInt) bool {
+	hashed, err := hashWithContext(h, context, msg)
+	if err != nil {
+		return false
+	}
+	return VerifyECDSA(pub, hashed, r, s)
+}
+
+// SignRSAPSSWithContext is like SignRSAPSS but hashes msg using h,
+// binding the hash to context.
+func SignRSAPSSWithContext(priv *PrivateKeyRSA, h crypto.Hash, context, msg []byte, saltLen int) ([]byte, error) {
+	hashed, err := hashWithContext(h, context, msg)
+	if err != nil {
+		return nil, err
+	}
+	return SignRSAPSS(priv, h, hashed, saltLen)
+}
+
+// VerifyRSAPSSWithContext is like VerifyRSAPSS but hashes msg using h,
+// binding the hash to context.
+func VerifyRSAPSSWithContext(pub *PublicKeyRSA, h crypto.Hash, context, msg, sig []byte, saltLen int) error {
+	hashed, err := hashWithContext(h, context, msg)
+	if err != nil {
+		return err
+	}
+	return VerifyRSAPSS(pub, h, hashed, sig, saltLen)
+}
+
+// SignRSAPKCS1v15WithContext is like SignRSAPKCS1v15 but hashes msg using h,
+// binding the hash to context.
+func SignRSAPKCS1v15WithContext(priv *PrivateKeyRSA, h crypto.Hash, context, msg []byte) ([]byte, error) {
+	hashed, err := hashWithContext(h, context, msg)
+	if err != nil {
+		return nil, err
+	}
+	return SignRSAPKCS1v15(priv, h, hashed)
+}
+
+// VerifyRSAPKCS1v15WithContext is like VerifyRSAPKCS1v15 but hashes msg using h,
+// binding the hash to context.
+func VerifyRSAPKCS1v15WithContext(pub *PublicKeyRSA, h crypto.Hash, context, msg, sig []byte) error {
+	hashed, err := hashWithContext(h, context, msg)
+	if err != nil {
+		return err
+	}
+	return VerifyRSAPKCS1v15(pub, h, hashed, sig)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestECDSAWithContext(t *testing.T) {
+	const name = "P-256"
+	x, y, d, err := cng.GenerateKeyECDSA(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := cng.NewPrivateKeyECDSA(name, x, y, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cng.NewPublicKeyECDSA(name, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("testing")
+	r, s, err := cng.SignECDSAWithContext(priv, crypto.SHA256, []byte("protocol A"), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cng.VerifyECDSAWithContext(pub, crypto.SHA256, []byte("protocol A"), msg, r, s) {
+		t.Error("Verify failed")
+	}
+	if cng.VerifyECDSAWithContext(pub, crypto.SHA256, []byte("protocol B"), msg, r, s) {
+		t.Error("Verify succeeded with a different context")
+	}
+	if cng.VerifyECDSAWithContext(pub, crypto.SHA256, nil, msg, r, s) {
+		t.Error("Verify succeeded with an empty context")
+	}
+	hashed := cng.SHA256(msg)
+	if cng.VerifyECDSA(pub, hashed[:], r, s) {
+		t.Error("Verify succeeded without context")
+	}
+	if _, _, err := cng.SignECDSAWithContext(priv, crypto.SHA256, bytes.Repeat([]byte{'a'}, 256), msg); err == nil {
+		t.Error("expected error for context longer than 255 bytes")
+	}
+}
+
+func TestRSAWithContext(t *testing.T) {
+	priv, pub := newRSAKey(t, 2048)
+	msg := []byte("testing")
+	ctxA, ctxB := []byte("protocol A"), []byte("protocol B")
+
+	sig, err := cng.SignRSAPKCS1v15WithContext(priv, crypto.SHA256, ctxA, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cng.VerifyRSAPKCS1v15WithContext(pub, crypto.SHA256, ctxA, msg, sig); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+	if err := cng.VerifyRSAPKCS1v15WithContext(pub, crypto.SHA256, ctxB, msg, sig); err == nil {
+		t.Error("Verify succeeded with a different context")
+	}
+
+	sig, err = cng.SignRSAPSSWithContext(priv, crypto.SHA256, ctxA, msg, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cng.VerifyRSAPSSWithContext(pub, crypto.SHA256, ctxA, msg, sig, 32); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+	if err := cng.VerifyRSAPSSWithContext(pub, crypto.SHA256, ctxB, msg, sig, 32); err == nil {
+		t.Error("Verify succeeded with a different context")
+	}
+}
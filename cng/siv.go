@@ -0,0 +1,172 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto/subtle"
+	"errors"
+	"hash"
+
+	alias "github.com/microsoft/go-crypto-winnative/internal/subtle"
+)
+
+// sivMaxComponents is the maximum number of additional data components
+// plus the plaintext that S2V can process, as per RFC 5297, Section 2.6.
+const sivMaxComponents = aesBlockSize*8 - 2
+
+// SIV implements AES-SIV, the deterministic authenticated encryption
+// mode specified in RFC 5297.
+//
+// Unlike cipher.AEAD, SIV accepts a vector of additional data components.
+// Nonce-based usage is achieved by passing the nonce as the last component.
+// A SIV is safe for concurrent use by multiple goroutines.
+type SIV struct {
+	macKey []byte
+	block  *aesCipher
+}
+
+// NewSIV returns a new AES-SIV instance. key must be 32, 48 or 64 bytes long,
+// the first half being the CMAC key and the second half the CTR key.
+func NewSIV(key []byte) (*SIV, error) {
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return nil, errors.New("crypto/aes: invalid SIV key size")
+	}
+	n := len(key) / 2
+	// Fail early if AES-CMAC is not available.
+	if _, err := NewCMAC(key[:n]); err != nil {
+		return nil, err
+	}
+	block, err := NewAESCipher(key[n:])
+	if err != nil {
+		return nil, err
+	}
+	s := &SIV{macKey: make([]byte, n), block: block.(*aesCipher)}
+	copy(s.macKey, key[:n])
+	return s, nil
+}
+
+// Overhead returns the difference between the lengths of
+// a plaintext and its ciphertext, that is, the length of the synthetic IV.
+func (s *SIV) Overhead() int {
+	return aesBlockSize
+}
+
+// Seal encrypts and authenticates plaintext, authenticates the
+// additional data components and appends the result to dst,
+// returning the updated slice.
+func (s *SIV) Seal(dst, plaintext []byte, additionalData ...[]byte) []byte {
+	if len(additionalData) >= sivMaxComponents {
+		panic("crypto/cipher: too many additional data components given to SIV")
+	}
+	ret, out := sliceForAppend(dst, aesBlockSize+len(plaintext))
+	if alias.InexactOverlap(out, plaintext) {
+		panic("crypto/cipher: invalid buffer overlap")
+	}
+	var v [aesBlockSize]byte
+	copy(v[:], s.s2v(additionalData, plaintext))
+	// In-place, the ciphertext is shifted by the synthetic IV
+	// relative to the plaintext, so move the plaintext first.
+	copy(out[aesBlockSize:], plaintext)
+	copy(out, v[:])
+	s.ctr(out[aesBlockSize:], &v)
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates the
+// additional data components and, if successful, appends the resulting
+// plaintext to dst, returning the updated slice.
+func (s *SIV) Open(dst, ciphertext []byte, additionalData ...[]byte) ([]byte, error) {
+	if len(ciphertext) < aesBlockSize {
+		return nil, errOpen
+	}
+	if len(additionalData) >= sivMaxComponents {
+		return nil, errOpen
+	}
+	ret, out := sliceForAppend(dst, len(ciphertext)-aesBlockSize)
+	if alias.InexactOverlap(out, ciphertext) {
+		panic("crypto/cipher: invalid buffer overlap")
+	}
+	var v [aesBlockSize]byte
+	copy(v[:], ciphertext)
+	copy(out, ciphertext[aesBlockSize:])
+	s.ctr(out, &v)
+	if subtle.ConstantTimeCompare(s.s2v(additionalData, out), v[:]) != 1 {
+		Wipe(out)
+		return nil, errOpen
+	}
+	return ret, nil
+}
+
+// s2v implements the S2V function described in RFC 5297, Section 2.4,
+// over additionalData followed by plaintext.
+func (s *SIV) s2v(additionalData [][]byte, plaintext []byte) []byte {
+	mac, err := NewCMAC(s.macKey)
+	if err != nil {
+		panic(err)
+	}
+	d := cmacSum(mac, make([]byte, aesBlockSize))
+	for _, ad := range additionalData {
+		sivDouble(d)
+		xorBytes(d, d, cmacSum(mac, ad))
+	}
+	if len(plaintext) >= aesBlockSize {
+		// T = Sn xorend D
+		mac.Write(plaintext[:len(plaintext)-aesBlockSize])
+		t := make([]byte, aesBlockSize)
+		xorBytes(t, plaintext[len(plaintext)-aesBlockSize:], d)
+		mac.Write(t)
+	} else {
+		// T = dbl(D) xor pad(Sn)
+		sivDouble(d)
+		t := make([]byte, aesBlockSize)
+		copy(t, plaintext)
+		t[len(plaintext)] = 0x80
+		xorBytes(d, d, t)
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// ctr encrypts buf in place using AES-CTR with the counter
+// derived from the synthetic IV v, as per RFC 5297, Section 2.5.
+func (s *SIV) ctr(buf []byte, v *[aesBlockSize]byte) {
+	if len(buf) == 0 {
+		return
+	}
+	q := *v
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	stream := s.block.ctrKeyStream(&q, len(buf))
+	xorBytes(buf, buf, stream)
+}
+
+// cmacSum returns the CMAC of p and resets mac.
+func cmacSum(mac hash.Hash, p []byte) []byte {
+	mac.Write(p)
+	sum := mac.Sum(nil)
+	mac.Reset()
+	return sum
+}
+
+// sivDouble multiplies d by x in GF(2^128), as per RFC 5297, Section 2.3.
+func sivDouble(d []byte) {
+	carry := d[0] >> 7
+	for i := 0; i < len(d)-1; i++ {
+		d[i] = d[i]<<1 | d[i+1]>>7
+	}
+	d[len(d)-1] = d[len(d)-1]<<1 ^ 0x87*carry
+}
+
+// xorBytes sets dst[i] = x[i] ^ y[i] for i < len(x).
+// y must be at least as long as x.
+func xorBytes(dst, x, y []byte) {
+	for i := range x {
+		dst[i] = x[i] ^ y[i]
+	}
+}
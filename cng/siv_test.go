@@ -0,0 +1,115 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestSIV(t *testing.T) {
+	// Test vectors from RFC 5297, Appendix A.
+	tests := []struct {
+		name      string
+		key       string
+		ad        []string
+		plaintext string
+		want      string
+	}{
+		{
+			name:      "deterministic",
+			key:       "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+			ad:        []string{"101112131415161718191a1b1c1d1e1f2021222324252627"},
+			plaintext: "112233445566778899aabbccddee",
+			want:      "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c",
+		},
+		{
+			name: "nonce-based",
+			key:  "7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f",
+			ad: []string{
+				"00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100",
+				"102030405060708090a0",
+				"09f911029d74e35bd84156c5635688c0",
+			},
+			plaintext: "7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553",
+			want:      "7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			siv, err := cng.NewSIV(hexDecode(t, tt.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ad [][]byte
+			for _, s := range tt.ad {
+				ad = append(ad, hexDecode(t, s))
+			}
+			plaintext := hexDecode(t, tt.plaintext)
+			ciphertext := siv.Seal(nil, plaintext, ad...)
+			if want := hexDecode(t, tt.want); !bytes.Equal(ciphertext, want) {
+				t.Fatalf("Seal() = %x, want %x", ciphertext, want)
+			}
+			got, err := siv.Open(nil, ciphertext, ad...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("Open() = %x, want %x", got, plaintext)
+			}
+			// In place: the ciphertext is Overhead bytes longer than the plaintext.
+			buf := make([]byte, len(ciphertext))
+			copy(buf, plaintext)
+			sealed := siv.Seal(buf[:0], buf[:len(plaintext)], ad...)
+			if !bytes.Equal(sealed, ciphertext) {
+				t.Fatalf("in-place Seal() = %x, want %x", sealed, ciphertext)
+			}
+			opened, err := siv.Open(sealed[:0], sealed, ad...)
+			if err != nil {
+				t.Fatalf("in-place Open() failed: %v", err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("in-place Open() = %x, want %x", opened, plaintext)
+			}
+			ciphertext[len(ciphertext)-1] ^= 1
+			if _, err := siv.Open(nil, ciphertext, ad...); err == nil {
+				t.Error("Open succeeded with a modified ciphertext")
+			}
+		})
+	}
+}
+
+func TestSIVInexactOverlap(t *testing.T) {
+	siv, err := cng.NewSIV(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("%s did not panic", name)
+			}
+		}()
+		f()
+	}
+	buf := make([]byte, 64)
+	assertPanic("Seal", func() { siv.Seal(buf[1:1], buf[:32]) })
+	ciphertext := siv.Seal(nil, buf[:32])
+	copy(buf, ciphertext)
+	assertPanic("Open", func() { siv.Open(buf[1:1], buf[:len(ciphertext)]) })
+}
+
+func TestSIVInvalidKeySize(t *testing.T) {
+	for _, n := range []int{16, 24, 33, 128} {
+		if _, err := cng.NewSIV(make([]byte, n)); err == nil {
+			t.Errorf("NewSIV(%d bytes) succeeded, want error", n)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+)
+
+// KDFCounterLocation specifies where an SP 800-108 KDF places the
+// iteration counter in the PRF input.
+type KDFCounterLocation int
+
+const (
+	// KDFCounterNone omits the counter from the PRF input.
+	KDFCounterNone KDFCounterLocation = iota
+	// KDFCounterBeforeIter places the counter before the iteration variable.
+	KDFCounterBeforeIter
+	// KDFCounterAfterIter places the counter between the iteration
+	// variable and the fixed input data.
+	KDFCounterAfterIter
+	// KDFCounterAfterFixed places the counter after the fixed input data.
+	KDFCounterAfterFixed
+)
+
+// SP800108Params holds the parameters of the SP 800-108 feedback and
+// double-pipeline KDFs.
+type SP800108Params struct {
+	// HashID is the CNG hash algorithm used with HMAC as the PRF, such as "SHA256".
+	HashID string
+	// FixedInput is the fixed input data, usually Label || 0x00 || Context || [L]2.
+	FixedInput []byte
+	// Counter is the location of the counter. If it is not KDFCounterNone,
+	// CounterBits must be 8, 16, 24 or 32.
+	Counter     KDFCounterLocation
+	CounterBits int
+	// IV is the initial value K(0) of the feedback mode. It may be empty,
+	// and must be empty in double-pipeline mode.
+	IV []byte
+}
+
+// KDFFeedbackHMAC derives length bytes from key using the SP 800-108
+// KDF in feedback mode, where each block is computed as
+// K(i) = HMAC(key, K(i-1) || [i]2 || FixedInput), with the counter
+// placed as given by params.
+//
+// CNG only implements the counter mode of SP 800-108, so the iterations
+// are computed here on top of the CNG HMAC.
+func KDFFeedbackHMAC(key []byte, params SP800108Params, length int) ([]byte, error) {
+	return sp800108(key, &params, length, false)
+}
+
+// KDFDoublePipelineHMAC derives length bytes from key using the SP 800-108
+// KDF in double-pipeline mode, where A(0) = FixedInput, A(i) = HMAC(key, A(i-1))
+// and each block is computed as K(i) = HMAC(key, A(i) || [i]2 || FixedInput),
+// with the counter placed as given by params. params.IV must be empty.
+func KDFDoublePipelineHMAC(key []byte, params SP800108Params, length int) ([]byte, error) {
+	if len(params.IV) != 0 {
+		return nil, errors.New("cng: double-pipeline KDF doesn't take an IV")
+	}
+	return sp800108(key, &params, length, true)
+}
+
+func sp800108(key []byte, params *SP800108Params, length int, pipeline bool) ([]byte, error) {
+	if length <= 0 {
+		return nil, errors.New("cng: invalid KDF output length")
+	}
+	switch params.Counter {
+	case KDFCounterNone:
+		if params.CounterBits != 0 {
+			return nil, errors.New("cng: KDF counter length given without a counter location")
+		}
+	case KDFCounterBeforeIter, KDFCounterAfterIter, KDFCounterAfterFixed:
+		switch params.CounterBits {
+		case 8, 16, 24, 32:
+		default:
+			return nil, errors.New("cng: KDF counter length must be 8, 16, 24 or 32 bits")
+		}
+	default:
+		return nil, errors.New("cng: invalid KDF counter location")
+	}
+	h, err := newHMACByID(params.HashID, key)
+	if err != nil {
+		return nil, err
+	}
+	size := h.Size()
+	n := uint64((length + size - 1) / size)
+	maxIter := uint64(1<<32 - 1)
+	if params.Counter != KDFCounterNone {
+		maxIter = 1<<uint(params.CounterBits) - 1
+	}
+	if n > maxIter {
+		return nil, errors.New("cng: KDF output length too large")
+	}
+
+	prf := func(parts ...[]byte) []byte {
+		h.Reset()
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum(nil)
+	}
+	ctr := make([]byte, params.CounterBits/8)
+	out := make([]byte, 0, int(n)*size)
+	iter := params.IV
+	a := params.FixedInput
+	for i := uint64(1); i <= n; i++ {
+		if pipeline {
+			a = prf(a)
+			iter = a
+		}
+		for j := range ctr {
+			ctr[j] = byte(i >> (8 * uint(len(ctr)-1-j)))
+		}
+		var k []byte
+		switch params.Counter {
+		case KDFCounterNone:
+			k = prf(iter, params.FixedInput)
+		case KDFCounterBeforeIter:
+			k = prf(ctr, iter, params.FixedInput)
+		case KDFCounterAfterIter:
+			k = prf(iter, ctr, params.FixedInput)
+		case KDFCounterAfterFixed:
+			k = prf(iter, params.FixedInput, ctr)
+		}
+		out = append(out, k...)
+		if !pipeline {
+			iter = k
+		}
+	}
+	return out[:length], nil
+}
@@ -0,0 +1,224 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"hash"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// TestKDFFeedbackHMACExpandHKDF checks that feedback mode with an empty IV
+// and an 8-bit counter after the fixed input data is HKDF-Expand,
+// using the test vectors from RFC 5869, Appendix A.1 and A.3.
+func TestKDFFeedbackHMACExpandHKDF(t *testing.T) {
+	tests := []struct {
+		prk, info, want string
+	}{
+		{
+			prk:  "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5",
+			info: "f0f1f2f3f4f5f6f7f8f9",
+			want: "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865",
+		},
+		{
+			prk:  "19ef24a32c717b167f33a91d6f648bdf96596776afdb6377ac434c1c293ccb04",
+			info: "",
+			want: "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8",
+		},
+	}
+	for _, tt := range tests {
+		want := hexDecode(t, tt.want)
+		got, err := cng.KDFFeedbackHMAC(hexDecode(t, tt.prk), cng.SP800108Params{
+			HashID:      "SHA256",
+			FixedInput:  hexDecode(t, tt.info),
+			Counter:     cng.KDFCounterAfterFixed,
+			CounterBits: 8,
+		}, len(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("got  %x\nwant %x", got, want)
+		}
+	}
+}
+
+// sp800108Ref computes the SP 800-108 feedback or double-pipeline KDF
+// directly from the definitions in Section 5.2 and 5.3 of the
+// specification, using crypto/hmac as the PRF.
+func sp800108Ref(h func() hash.Hash, key []byte, p cng.SP800108Params, length int, pipeline bool) []byte {
+	prf := func(parts ...[]byte) []byte {
+		m := hmac.New(h, key)
+		for _, part := range parts {
+			m.Write(part)
+		}
+		return m.Sum(nil)
+	}
+	var out, k []byte
+	a := p.FixedInput
+	k = p.IV
+	for i := uint32(1); len(out) < length; i++ {
+		var ctr [4]byte
+		binary.BigEndian.PutUint32(ctr[:], i)
+		c := ctr[4-p.CounterBits/8:]
+		iter := k
+		if pipeline {
+			a = prf(a)
+			iter = a
+		}
+		switch p.Counter {
+		case cng.KDFCounterNone:
+			k = prf(iter, p.FixedInput)
+		case cng.KDFCounterBeforeIter:
+			k = prf(c, iter, p.FixedInput)
+		case cng.KDFCounterAfterIter:
+			k = prf(iter, c, p.FixedInput)
+		case cng.KDFCounterAfterFixed:
+			k = prf(iter, p.FixedInput, c)
+		}
+		out = append(out, k...)
+	}
+	return out[:length]
+}
+
+func TestSP800108Reference(t *testing.T) {
+	hashes := []struct {
+		id string
+		h  func() hash.Hash
+	}{
+		{"SHA1", sha1.New},
+		{"SHA256", sha256.New},
+		{"SHA512", sha512.New},
+	}
+	locations := []cng.KDFCounterLocation{cng.KDFCounterNone, cng.KDFCounterBeforeIter, cng.KDFCounterAfterIter, cng.KDFCounterAfterFixed}
+	key := hexDecode(t, "dd1d91b7d90b2bd3138533ce92b272fbf8a369316aefe242e659cc0ae238afe0")
+	iv := hexDecode(t, "0102030405060708090a0b0c0d0e0f10")
+	fixed := hexDecode(t, "b50b0c963c6b3034b8cf19cd3f5c4ebe4f4985af0c03e575db62e6fdf1ecfe4f")
+	for _, hh := range hashes {
+		for _, loc := range locations {
+			bits := []int{0}
+			if loc != cng.KDFCounterNone {
+				bits = []int{8, 16, 24, 32}
+			}
+			for _, b := range bits {
+				for _, pipeline := range []bool{false, true} {
+					params := cng.SP800108Params{HashID: hh.id, FixedInput: fixed, Counter: loc, CounterBits: b}
+					if !pipeline {
+						params.IV = iv
+					}
+					const length = 100
+					var got []byte
+					var err error
+					if pipeline {
+						got, err = cng.KDFDoublePipelineHMAC(key, params, length)
+					} else {
+						got, err = cng.KDFFeedbackHMAC(key, params, length)
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					if want := sp800108Ref(hh.h, key, params, length, pipeline); !bytes.Equal(got, want) {
+						t.Errorf("%s counter=%d bits=%d pipeline=%v:\ngot  %x\nwant %x", hh.id, loc, b, pipeline, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// sp800108Tests were computed with sp800108Ref over HMAC-SHA256.
+var sp800108Tests = []struct {
+	name     string
+	pipeline bool
+	counter  cng.KDFCounterLocation
+	bits     int
+	want     string
+}{
+	{"Feedback/BeforeIter", false, cng.KDFCounterBeforeIter, 32, "a976067e8e30ecde8b0022c503cb773dab48df11341808efd1ef7ef503529576a01b81296dafcba9fbb51fe5c067e122d1e525306b14ac8edc798ac751bd3e256d6bfc9cb8d1b753e4465846322d1641"},
+	{"Feedback/AfterIter", false, cng.KDFCounterAfterIter, 32, "af9de0c1a20091094645527248ed4de817a93d4c520f7a586c33fb76056c5afc3b3a12b0fc5b296b2bbe48fb3c4dcfa23c7bffdeabc9dd48e49d38ed6d129e5294a30fafb27d700b25b7ba2e6a7201b4"},
+	{"Feedback/AfterFixed", false, cng.KDFCounterAfterFixed, 32, "ef0982dd58c765d4639a98c227923a55eaa709263d9ac6629dfb97d81fe87069ec0eabb5d4754feda064d0513d215c12ee98196c055d6a49471a5bf6cf31f06df6a1f61e9aca16a48e5dd3b47d91147b"},
+	{"Feedback/NoCounter", false, cng.KDFCounterNone, 0, "10cf91a023f764fba4b4e93bab95d5271490de21e542edc9beb6f68ad489ec2a91396a970a135d23bfc9ff3cc0f78fdab3d58dd65610349d8550249fe5514e619dbea8b60bd3628a210d87bcc5072fec"},
+	{"DoublePipeline/BeforeIter", true, cng.KDFCounterBeforeIter, 16, "089911a025b1e7da362311a881e830c0efda74481d9abb7c9a68ae4be4189494e9efa57ddae9e91006b8cea71162438ee4b84a96acbd05725cbdef57e50dc4327d0bdc65b51b51e5526f9316e8003390"},
+	{"DoublePipeline/AfterIter", true, cng.KDFCounterAfterIter, 16, "121c995fc4f808f0fb5991ce672d18c111a712e735bf60c91e5b24d8166e11a029e55c48c7155ecc402377c04acb848cd0cf9666732ea7ea70ff9878776d3ea9f185b792978bbd03ec33215bcaa47850"},
+	{"DoublePipeline/AfterFixed", true, cng.KDFCounterAfterFixed, 16, "7d69f13f788b04bed879cf36825f0c0f373f70bb56ebf5f76a5ec34def0ebaf00ddae2d89dbbe39b800604b1498a918327209b0784c9407c97592c622d931515969ab3cbcdb796698bd24f6823c5cc9a"},
+	{"DoublePipeline/NoCounter", true, cng.KDFCounterNone, 0, "f88db212d7566536c733dedee7975d334ea8d4f7b2276ebc8da52ccdf9fcb7911ad90b46b60dffa8bf7bca98e814cf74ec9fd242a27e3c917ed579781d1c65131570da67fede0d4f7e54b336445798f8"},
+}
+
+func TestSP800108(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	iv := make([]byte, 16)
+	for i := range iv {
+		iv[i] = byte(0x40 + i)
+	}
+	// Label "label", context "context", L = 640 bits.
+	fixed := []byte("label\x00context\x00\x00\x02\x80")
+	for _, tt := range sp800108Tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			want := hexDecode(t, tt.want)
+			params := cng.SP800108Params{
+				HashID:      "SHA256",
+				FixedInput:  fixed,
+				Counter:     tt.counter,
+				CounterBits: tt.bits,
+			}
+			var got []byte
+			var err error
+			if tt.pipeline {
+				got, err = cng.KDFDoublePipelineHMAC(key, params, len(want))
+			} else {
+				params.IV = iv
+				got, err = cng.KDFFeedbackHMAC(key, params, len(want))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got  %x\nwant %x", got, want)
+			}
+		})
+	}
+}
+
+func TestSP800108InvalidParams(t *testing.T) {
+	key := make([]byte, 32)
+	tests := []struct {
+		name   string
+		params cng.SP800108Params
+		length int
+	}{
+		{"zero length", cng.SP800108Params{HashID: "SHA256"}, 0},
+		{"bad counter bits", cng.SP800108Params{HashID: "SHA256", Counter: cng.KDFCounterAfterFixed, CounterBits: 12}, 32},
+		{"missing counter bits", cng.SP800108Params{HashID: "SHA256", Counter: cng.KDFCounterBeforeIter}, 32},
+		{"bits without location", cng.SP800108Params{HashID: "SHA256", CounterBits: 8}, 32},
+		{"bad location", cng.SP800108Params{HashID: "SHA256", Counter: 42, CounterBits: 8}, 32},
+		{"counter overflow", cng.SP800108Params{HashID: "SHA256", Counter: cng.KDFCounterAfterFixed, CounterBits: 8}, 256 * 32},
+		{"unknown hash", cng.SP800108Params{HashID: "NOTAHASH"}, 32},
+	}
+	for _, tt := range tests {
+		if _, err := cng.KDFFeedbackHMAC(key, tt.params, tt.length); err == nil {
+			t.Errorf("%s: expected error from KDFFeedbackHMAC", tt.name)
+		}
+		if _, err := cng.KDFDoublePipelineHMAC(key, tt.params, tt.length); err == nil {
+			t.Errorf("%s: expected error from KDFDoublePipelineHMAC", tt.name)
+		}
+	}
+	_, err := cng.KDFDoublePipelineHMAC(key, cng.SP800108Params{HashID: "SHA256", IV: []byte{1}}, 32)
+	if err == nil {
+		t.Error("expected error for double-pipeline KDF with an IV")
+	}
+}
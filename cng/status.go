@@ -0,0 +1,27 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Status returns the NTSTATUS code of the failed CNG call in err's chain,
+// for example 0xC000000D (STATUS_INVALID_PARAMETER), and whether there is
+// one. The codes are listed in the Microsoft NTSTATUS documentation.
+//
+// CNG failures are reported as syscall.Errno values holding the raw
+// NTSTATUS, whose Error method formats them as Win32 error codes, so
+// Status is the reliable way to identify them.
+func Status(err error) (uint32, bool) {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return 0, false
+	}
+	return uint32(errno), true
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+const (
+	statusInvalidParameter uint32 = 0xC000000D
+	statusNotFound         uint32 = 0xC0000225
+)
+
+func TestStatus(t *testing.T) {
+	// Invalid flags.
+	var h bcrypt.ALG_HANDLE
+	err := bcrypt.OpenAlgorithmProvider(&h, utf16PtrFromString(bcrypt.SHA256_ALGORITHM), nil, 0x80000000)
+	if err == nil {
+		bcrypt.CloseAlgorithmProvider(h, 0)
+		t.Fatal("expected an error for invalid flags")
+	}
+	if st, ok := Status(err); !ok || st != statusInvalidParameter {
+		t.Errorf("Status(%v) = %#x, %v, want %#x, true", err, st, ok, statusInvalidParameter)
+	}
+	// The status survives wrapping.
+	if st, ok := Status(fmt.Errorf("context: %w", err)); !ok || st != statusInvalidParameter {
+		t.Errorf("Status of wrapped error = %#x, %v, want %#x, true", st, ok, statusInvalidParameter)
+	}
+
+	// Unknown algorithm, deeper in the package.
+	_, err = loadHash("NOTAHASH", 0)
+	if err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+	if st, ok := Status(err); !ok || st != statusNotFound {
+		t.Errorf("Status(%v) = %#x, %v, want %#x, true", err, st, ok, statusNotFound)
+	}
+
+	if _, ok := Status(errors.New("not from CNG")); ok {
+		t.Error("Status reported a code for an error that is not from CNG")
+	}
+	if _, ok := Status(nil); ok {
+		t.Error("Status reported a code for a nil error")
+	}
+}
+
+func TestStatusECDHImport(t *testing.T) {
+	// (1, 1) is not on P-256.
+	point := make([]byte, 65)
+	point[0], point[32], point[64] = 4, 1, 1
+	_, err := NewPublicKeyECDH("P-256", point)
+	if err == nil {
+		t.Fatal("expected an error for a point not on the curve")
+	}
+	if !errors.Is(err, errInvalidPublicKey) {
+		t.Errorf("errors.Is(%v, errInvalidPublicKey) = false", err)
+	}
+	if _, ok := Status(err); !ok {
+		t.Errorf("Status(%v) reports no NTSTATUS", err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"crypto"
+	"errors"
+	"io"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// HashReader hashes all the data read from r using the CNG hash algorithm
+// identified by hashID, such as "SHA256", and returns the digest.
+// The data is hashed incrementally, so r can be arbitrarily large.
+func HashReader(hashID string, r io.Reader) ([]byte, error) {
+	return HashReaderUntil(hashID, r, nil)
+}
+
+// HashReaderUntil is like HashReader but calls stop, if not nil, before
+// each read, and stops hashing as soon as it returns an error, returning
+// that error and destroying the partial hash. To stop when a context is
+// done, use HashReaderContext from package cng/ctxhash.
+//
+// A Read call that blocks is not interrupted; use a reader that honors
+// the same stop condition for that.
+func HashReaderUntil(hashID string, r io.Reader, stop func() error) ([]byte, error) {
+	if _, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG); err != nil {
+		return nil, err
+	}
+	h := newHashX(hashID, bcrypt.ALG_NONE_FLAG, nil)
+	defer h.Close()
+	buf := make([]byte, 32*1024)
+	for {
+		if stop != nil {
+			if err := stop(); err != nil {
+				return nil, err
+			}
+		}
+		n, err := r.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// SignStream hashes all the data read from r using the CNG hash algorithm
+// identified by hashID and signs the resulting digest using priv.
+// The returned signature is detached from the data.
+func SignStream(priv crypto.Signer, hashID string, r io.Reader) ([]byte, error) {
+	h := idToCryptoHash(hashID)
+	if h == 0 {
+		return nil, errors.New("cng: unsupported hash function")
+	}
+	hashed, err := HashReader(hashID, r)
+	if err != nil {
+		return nil, err
+	}
+	return priv.Sign(randomReader(), hashed, h)
+}
+
+// VerifyStream hashes all the data read from r using the CNG hash algorithm
+// identified by hashID and calls verify with the resulting digest,
+// returning its result.
+//
+// verify is typically a closure over one of the Verify functions
+// of this package, for example VerifyRSAPKCS1v15.
+func VerifyStream(hashID string, r io.Reader, verify func(hashed []byte) error) error {
+	hashed, err := HashReader(hashID, r)
+	if err != nil {
+		return err
+	}
+	return verify(hashed)
+}
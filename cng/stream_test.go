@@ -0,0 +1,143 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// patternReader returns a deterministic byte stream of n bytes
+// without holding it in memory.
+type patternReader struct {
+	n, off int
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.off >= r.n {
+		return 0, io.EOF
+	}
+	if len(p) > r.n-r.off {
+		p = p[:r.n-r.off]
+	}
+	for i := range p {
+		p[i] = byte((r.off + i) % 251)
+	}
+	r.off += len(p)
+	return len(p), nil
+}
+
+// rsaSigner adapts a cng.PrivateKeyRSA to the crypto.Signer interface.
+type rsaSigner struct {
+	priv *cng.PrivateKeyRSA
+}
+
+func (s rsaSigner) Public() crypto.PublicKey { return nil }
+
+func (s rsaSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return cng.SignRSAPKCS1v15(s.priv, opts.HashFunc(), digest)
+}
+
+func TestHashReader(t *testing.T) {
+	const size = 1<<20 + 3
+	got, err := cng.HashReader("SHA256", &patternReader{n: size})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.New()
+	io.Copy(want, &patternReader{n: size})
+	if !bytes.Equal(got, want.Sum(nil)) {
+		t.Errorf("got:%x want:%x", got, want.Sum(nil))
+	}
+	if _, err := cng.HashReader("NOTAHASH", &patternReader{n: size}); err == nil {
+		t.Error("expected error for unknown hash")
+	}
+}
+
+// slowReader delays each Read from r and calls onRead
+// with the number of reads made so far.
+type slowReader struct {
+	r      io.Reader
+	delay  time.Duration
+	reads  int
+	onRead func(reads int)
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	r.reads++
+	if r.onRead != nil {
+		r.onRead(r.reads)
+	}
+	return r.r.Read(p)
+}
+
+func TestHashReaderUntil(t *testing.T) {
+	const size = 1<<20 + 3
+	r := &slowReader{r: &patternReader{n: size}, delay: time.Millisecond}
+	got, err := cng.HashReaderUntil("SHA256", r, context.Background().Err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.New()
+	io.Copy(want, &patternReader{n: size})
+	if !bytes.Equal(got, want.Sum(nil)) {
+		t.Errorf("got:%x want:%x", got, want.Sum(nil))
+	}
+}
+
+func TestHashReaderUntilCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const cancelAt = 3
+	r := &slowReader{r: &patternReader{n: 1 << 30}, delay: time.Millisecond, onRead: func(reads int) {
+		if reads == cancelAt {
+			cancel()
+		}
+	}}
+	_, err := cng.HashReaderUntil("SHA256", r, ctx.Err)
+	if err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if r.reads != cancelAt {
+		t.Errorf("read %d chunks, want to stop after %d", r.reads, cancelAt)
+	}
+
+	// A context that is already done stops before the first read.
+	r = &slowReader{r: &patternReader{n: 1 << 30}}
+	if _, err := cng.HashReaderUntil("SHA256", r, ctx.Err); err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	if r.reads != 0 {
+		t.Errorf("read %d chunks from a cancelled context", r.reads)
+	}
+}
+
+func TestSignVerifyStream(t *testing.T) {
+	const size = 64 << 20
+	priv, pub := newRSAKey(t, 2048)
+	sig, err := cng.SignStream(rsaSigner{priv}, "SHA256", &patternReader{n: size})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(hashed []byte) error {
+		return cng.VerifyRSAPKCS1v15(pub, crypto.SHA256, hashed, sig)
+	}
+	if err := cng.VerifyStream("SHA256", &patternReader{n: size}, verify); err != nil {
+		t.Errorf("VerifyStream failed: %v", err)
+	}
+	if err := cng.VerifyStream("SHA256", &patternReader{n: size - 1}, verify); err == nil {
+		t.Error("VerifyStream succeeded on truncated data")
+	}
+}
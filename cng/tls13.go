@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// ExpandLabel implements HKDF-Expand-Label from RFC 8446, Section 7.1,
+// using the hash identified by hashID, such as "SHA256".
+func ExpandLabel(hashID string, secret []byte, label string, context []byte, length int) ([]byte, error) {
+	const labelPrefix = "tls13 "
+	if len(labelPrefix)+len(label) > 255 || len(context) > 255 || length < 0 || length > 0xffff {
+		return nil, errors.New("cng: invalid TLS 1.3 label parameters")
+	}
+	// struct {
+	//     uint16 length = Length;
+	//     opaque label<7..255> = "tls13 " + Label;
+	//     opaque context<0..255> = Context;
+	// } HkdfLabel;
+	info := make([]byte, 0, 2+1+len(labelPrefix)+len(label)+1+len(context))
+	info = append(info, byte(length>>8), byte(length))
+	info = append(info, byte(len(labelPrefix)+len(label)))
+	info = append(info, labelPrefix...)
+	info = append(info, label...)
+	info = append(info, byte(len(context)))
+	info = append(info, context...)
+	out, err := HKDFExpandMulti(hashID, secret, []ExpandRequest{{Info: info, Length: length}})
+	if err != nil {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+// FinishedMAC returns the TLS 1.3 Finished verify_data, as specified
+// in RFC 8446, Section 4.4.4, using the hash identified by hashID:
+//
+//	finished_key = HKDF-Expand-Label(baseKey, "finished", "", Hash.length)
+//	verify_data = HMAC(finished_key, transcriptHash)
+func FinishedMAC(hashID string, baseKey, transcriptHash []byte) ([]byte, error) {
+	alg, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	finishedKey, err := ExpandLabel(hashID, baseKey, "finished", nil, int(alg.size))
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(finishedKey)
+	h, err := newHMACByID(hashID, finishedKey)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(transcriptHash)
+	return h.Sum(nil), nil
+}
+
+// KeySchedule walks the TLS 1.3 key schedule from RFC 8446, Section 7.1,
+// from the early secret through the handshake secret to the master secret.
+// Traffic secrets are obtained with DeriveSecret at each stage.
+type KeySchedule struct {
+	hashID string
+	size   int
+	secret []byte
+	stage  int
+}
+
+const (
+	keyScheduleEarly = iota
+	keyScheduleHandshake
+	keyScheduleMaster
+)
+
+// NewKeySchedule starts a key schedule using the hash identified by hashID,
+// such as "SHA256", computing the early secret from psk.
+// If psk is nil, a string of Hash.length zeros is used, as in a full handshake.
+func NewKeySchedule(hashID string, psk []byte) (*KeySchedule, error) {
+	alg, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	k := &KeySchedule{hashID: hashID, size: int(alg.size)}
+	if psk == nil {
+		psk = make([]byte, k.size)
+	}
+	k.secret, err = extractHKDF(hashID, psk, make([]byte, k.size))
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Secret returns a copy of the secret of the current stage.
+// The schedule wipes its own copy when it advances, so the returned
+// slice stays valid until the caller is done with it.
+func (k *KeySchedule) Secret() []byte {
+	return append([]byte(nil), k.secret...)
+}
+
+// DeriveSecret implements Derive-Secret(Secret, Label, Messages) using
+// the secret of the current stage, where transcriptHash is the hash of Messages.
+func (k *KeySchedule) DeriveSecret(label string, transcriptHash []byte) ([]byte, error) {
+	return ExpandLabel(k.hashID, k.secret, label, transcriptHash, k.size)
+}
+
+// advance replaces the current secret by HKDF-Extract(salt, ikm),
+// where salt is Derive-Secret(current secret, "derived", "").
+func (k *KeySchedule) advance(from int, ikm []byte) error {
+	if k.stage != from {
+		return errors.New("cng: TLS 1.3 key schedule used out of order")
+	}
+	emptyHash := make([]byte, k.size)
+	if err := hashOneShot(k.hashID, nil, emptyHash); err != nil {
+		return err
+	}
+	salt, err := k.DeriveSecret("derived", emptyHash)
+	if err != nil {
+		return err
+	}
+	secret, err := extractHKDF(k.hashID, ikm, salt)
+	if err != nil {
+		return err
+	}
+	Wipe(k.secret)
+	k.secret = secret
+	k.stage++
+	return nil
+}
+
+// Handshake advances from the early secret to the handshake secret,
+// using the (EC)DHE shared secret.
+func (k *KeySchedule) Handshake(sharedSecret []byte) error {
+	return k.advance(keyScheduleEarly, sharedSecret)
+}
+
+// Master advances from the handshake secret to the master secret.
+func (k *KeySchedule) Master() error {
+	return k.advance(keyScheduleHandshake, make([]byte, k.size))
+}
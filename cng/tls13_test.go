@@ -0,0 +1,125 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// Test vectors from RFC 8448, Section 3, server Finished message.
+const (
+	rfc8448ServerHandshakeSecret = "b67b7d690cc16c4e75e54213cb2d37b4e9c912bcded9105d42befd59d391ad38"
+	rfc8448ServerFinishedKey     = "008d3b66f816ea559f96b537e885c31fc068bf492c652f01f288a1d8cdc19fc8"
+	rfc8448ServerTranscriptHash  = "edb7725fa7a3473b031ec8ef65a2485493900138a2b91291407d7951a06110ed"
+	rfc8448ServerVerifyData      = "9b9b141d906337fbd2cbdce71df4deda4ab42c309572cb7fffee5454b78f0718"
+)
+
+func TestExpandLabel(t *testing.T) {
+	got, err := cng.ExpandLabel("SHA256", hexDecode(t, rfc8448ServerHandshakeSecret), "finished", nil, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hexDecode(t, rfc8448ServerFinishedKey); !bytes.Equal(got, want) {
+		t.Errorf("ExpandLabel() = %x, want %x", got, want)
+	}
+	if _, err := cng.ExpandLabel("SHA256", got, string(make([]byte, 250)), nil, 32); err == nil {
+		t.Error("expected error for too long label")
+	}
+}
+
+func TestFinishedMAC(t *testing.T) {
+	got, err := cng.FinishedMAC("SHA256", hexDecode(t, rfc8448ServerHandshakeSecret), hexDecode(t, rfc8448ServerTranscriptHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hexDecode(t, rfc8448ServerVerifyData); !bytes.Equal(got, want) {
+		t.Errorf("FinishedMAC() = %x, want %x", got, want)
+	}
+}
+
+// TestKeySchedule checks each secret of the RFC 8448, Section 3, simple 1-RTT handshake.
+func TestKeySchedule(t *testing.T) {
+	const (
+		earlySecret     = "33ad0a1c607ec03b09e6cd9893680ce210adf300aa1f2660e1b22e10f170f92a"
+		sharedSecret    = "8bd4054fb55b9d63fdfbacf9f04b9f0d35e6d63f537563efd46272900f89492d"
+		handshakeSecret = "1dc826e93606aa6fdc0aadc12f741b01046aa6b99f691ed221a9f0ca043fbeac"
+		masterSecret    = "18df06843d13a08bf2a449844c5f8a478001bc4d4c627984d5a41da8d0402919"
+		helloHash       = "860c06edc07858ee8e78f0e7428c58edd6b43f2ca3e6e95f02ed063cf0e1cad8"
+		serverFinHash   = "9608102a0f1ccc6db6250b7b7e417b1a000eaada3daae4777a7686c9ff83df13"
+		clientFinHash   = "209145a96ee8e2a122ff810047cc952684658d6049e86429426db87c54ad143d"
+	)
+	check := func(name string, got []byte, want string) {
+		t.Helper()
+		if w := hexDecode(t, want); !bytes.Equal(got, w) {
+			t.Errorf("%s = %x, want %x", name, got, w)
+		}
+	}
+	derive := func(ks *cng.KeySchedule, label, transcriptHash, want string) {
+		t.Helper()
+		got, err := ks.DeriveSecret(label, hexDecode(t, transcriptHash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(label, got, want)
+	}
+
+	ks, err := cng.NewKeySchedule("SHA256", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("early secret", ks.Secret(), earlySecret)
+	if err := ks.Master(); err == nil {
+		t.Error("expected error advancing to the master secret from the early secret")
+	}
+
+	if err := ks.Handshake(hexDecode(t, sharedSecret)); err != nil {
+		t.Fatal(err)
+	}
+	check("handshake secret", ks.Secret(), handshakeSecret)
+	derive(ks, "c hs traffic", helloHash, "b3eddb126e067f35a780b3abf45e2d8f3b1a950738f52e9600746a0e27a55a21")
+	derive(ks, "s hs traffic", helloHash, rfc8448ServerHandshakeSecret)
+
+	if err := ks.Master(); err != nil {
+		t.Fatal(err)
+	}
+	check("master secret", ks.Secret(), masterSecret)
+	derive(ks, "c ap traffic", serverFinHash, "9e40646ce79a7f9dc05af8889bce6552875afa0b06df0087f792ebb7c17504a5")
+	derive(ks, "s ap traffic", serverFinHash, "a11af9f05531f856ad47116b45a950328204b4f44bfb6b3a4b4f1f3fcb631643")
+	derive(ks, "exp master", serverFinHash, "fe22f881176eda18eb8f44529e6792c50c9a3f89452f68d8ae311b4309d3cf50")
+	derive(ks, "res master", clientFinHash, "7df235f2031d2a051287d02b0241b0bfdaf86cc856231f2d5aba46c434ec196c")
+
+	if err := ks.Handshake(hexDecode(t, sharedSecret)); err == nil {
+		t.Error("expected error going back to the handshake secret")
+	}
+}
+
+func TestKeyScheduleSecretCopy(t *testing.T) {
+	ks, err := cng.NewKeySchedule("SHA256", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	early := ks.Secret()
+	want := append([]byte(nil), early...)
+	if err := ks.Handshake(make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(early, want) {
+		t.Errorf("early secret changed after Handshake: %x, want %x", early, want)
+	}
+	if bytes.Equal(ks.Secret(), early) {
+		t.Error("handshake secret equals the early secret")
+	}
+	// Modifying the returned slice doesn't affect the schedule.
+	s := ks.Secret()
+	s[0] ^= 0xff
+	if bytes.Equal(ks.Secret(), s) {
+		t.Error("Secret returned the internal slice")
+	}
+}
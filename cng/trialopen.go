@@ -0,0 +1,61 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"errors"
+)
+
+// TrialOpen decrypts and authenticates an AES-GCM ciphertext sealed
+// under one of keys, with the standard nonce and tag sizes, when the
+// key isn't known in advance, for example while rotating keys.
+// It appends the plaintext to dst and returns it with the index of the
+// key that authenticated the message.
+//
+// Every key is tried, even after one succeeds, and an authentication
+// failure returns the same error as Open, so neither the timing nor the
+// error reveals which keys were tried. Invalid key or nonce sizes are
+// reported before any decryption is attempted.
+//
+// GCM doesn't commit to its key: a ciphertext can be crafted to
+// authenticate under several known keys. TrialOpen then returns the
+// first of them, so keys must not be chosen by an attacker.
+func TrialOpen(keys [][]byte, dst, nonce, ciphertext, additionalData []byte) (plaintext []byte, keyIndex int, err error) {
+	if len(keys) == 0 {
+		return nil, -1, errors.New("cng: no keys to try")
+	}
+	for _, k := range keys {
+		switch len(k) {
+		case 16, 24, 32:
+		default:
+			return nil, -1, errors.New("crypto/aes: invalid key size " + itoa(len(k)))
+		}
+	}
+	if len(nonce) != gcmStandardNonceSize {
+		return nil, -1, errors.New("cipher: incorrect nonce length given to GCM")
+	}
+	keyIndex = -1
+	var found []byte
+	for i, k := range keys {
+		g, err := newGCM(k, false)
+		if err != nil {
+			return nil, -1, err
+		}
+		out, err := g.Open(nil, nonce, ciphertext, additionalData)
+		if err == nil && keyIndex < 0 {
+			keyIndex, found = i, out
+		} else if err == nil {
+			Wipe(out)
+		}
+	}
+	if keyIndex < 0 {
+		return nil, -1, errOpen
+	}
+	plaintext = append(dst, found...)
+	Wipe(found)
+	return plaintext, keyIndex, nil
+}
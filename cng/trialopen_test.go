@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+func TestTrialOpen(t *testing.T) {
+	keys := [][]byte{
+		bytes.Repeat([]byte{1}, 16),
+		bytes.Repeat([]byte{2}, 32),
+		bytes.Repeat([]byte{3}, 24),
+	}
+	aead, err := cng.NewGCMFromKey(keys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	plaintext := []byte("rotated key message")
+	ad := []byte("ad")
+	sealed := aead.Seal(nil, nonce, plaintext, ad)
+
+	dst := []byte("prefix")
+	got, index, err := cng.TrialOpen(keys, dst, nonce, sealed, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 1 {
+		t.Errorf("keyIndex = %d, want 1", index)
+	}
+	if want := append([]byte("prefix"), plaintext...); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Failures don't depend on which keys were tried.
+	_, _, errWrongKeys := cng.TrialOpen([][]byte{keys[0], keys[2]}, nil, nonce, sealed, ad)
+	_, _, errWrongAD := cng.TrialOpen(keys, nil, nonce, sealed, []byte("other ad"))
+	sealed[0] ^= 1
+	_, index, errCorrupted := cng.TrialOpen(keys, nil, nonce, sealed, ad)
+	if errWrongKeys == nil || errWrongAD == nil || errCorrupted == nil {
+		t.Fatal("expected authentication errors")
+	}
+	if errWrongKeys != errWrongAD || errWrongAD != errCorrupted {
+		t.Errorf("authentication errors differ: %v, %v, %v", errWrongKeys, errWrongAD, errCorrupted)
+	}
+	if index != -1 {
+		t.Errorf("keyIndex = %d on failure, want -1", index)
+	}
+}
+
+func TestTrialOpenInvalidParameters(t *testing.T) {
+	key := make([]byte, 16)
+	nonce := make([]byte, 12)
+	if _, _, err := cng.TrialOpen(nil, nil, nonce, make([]byte, 16), nil); err == nil {
+		t.Error("expected error for no keys")
+	}
+	if _, _, err := cng.TrialOpen([][]byte{key, key[:15]}, nil, nonce, make([]byte, 16), nil); err == nil {
+		t.Error("expected error for invalid key size")
+	}
+	if _, _, err := cng.TrialOpen([][]byte{key}, nil, nonce[:8], make([]byte, 16), nil); err == nil {
+		t.Error("expected error for invalid nonce size")
+	}
+}
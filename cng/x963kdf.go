@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/microsoft/go-crypto-winnative/internal/bcrypt"
+)
+
+// X963KDF derives keyLen bytes from the shared secret z using the
+// ANSI X9.63 KDF, as used by ECIES (SEC 1, Section 3.6.1): the
+// concatenation of Hash(z || counter || sharedInfo) for a 32-bit
+// big-endian counter starting at 1. hashID is the CNG hash algorithm,
+// such as "SHA256".
+//
+// CNG's BCRYPT_KDF_HASH only computes a single hash block from a secret
+// handle, so the blocks are computed here with the CNG hash instead.
+func X963KDF(hashID string, z, sharedInfo []byte, keyLen int) ([]byte, error) {
+	if keyLen < 0 {
+		return nil, errors.New("cng: invalid X9.63 KDF output length")
+	}
+	alg, err := loadHash(hashID, bcrypt.ALG_NONE_FLAG)
+	if err != nil {
+		return nil, err
+	}
+	size := int(alg.size)
+	if uint64(keyLen) > uint64(size)*(1<<32-1) {
+		return nil, errors.New("cng: X9.63 KDF output length too large")
+	}
+	h := newHashX(hashID, bcrypt.ALG_NONE_FLAG, nil)
+	defer h.Close()
+	out := make([]byte, 0, (keyLen+size-1)/size*size)
+	var counter [4]byte
+	for i := uint32(1); len(out) < keyLen; i++ {
+		binary.BigEndian.PutUint32(counter[:], i)
+		h.Reset()
+		h.Write(z)
+		h.Write(counter[:])
+		h.Write(sharedInfo)
+		out = h.Sum(out)
+	}
+	return out[:keyLen], nil
+}
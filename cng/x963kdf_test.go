@@ -0,0 +1,56 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+//go:build windows
+// +build windows
+
+package cng_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microsoft/go-crypto-winnative/cng"
+)
+
+// x963KDFTests are from the NIST CAVS ANSI X9.63-2001 KDF test vectors.
+var x963KDFTests = []struct {
+	z, sharedInfo, want string
+}{
+	{
+		"96c05619d56c328ab95fe84b18264b08725b85e33fd34f08",
+		"",
+		"443024c3dae66b95e6f5670601558f71",
+	},
+	{
+		"22518b10e70f2a3f243810ae3254139efbee04aa57c7af7d",
+		"75eef81aa3041e33b80971203d2c0c52",
+		"c498af77161cc59f2962b9a713e2b215152d139766ce34a776df11866a69bf2e" +
+			"52a13d9c7c6fc878c50c5ea0bc7b00e0da2447cfd874f6cf92f30d0097111485" +
+			"500c90c3af8b487872d04685d14c8d1dc8d7fa08beb0ce0ababc11f0bd496269" +
+			"142d43525a78e5bc79a17f59676a5706dc54d54d4d1f0bd7e386128ec26afc21",
+	},
+}
+
+func TestX963KDF(t *testing.T) {
+	for i, tt := range x963KDFTests {
+		want := hexDecode(t, tt.want)
+		got, err := cng.X963KDF("SHA256", hexDecode(t, tt.z), hexDecode(t, tt.sharedInfo), len(want))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d:\ngot  %x\nwant %x", i, got, want)
+		}
+	}
+}
+
+func TestX963KDFInvalid(t *testing.T) {
+	z := make([]byte, 32)
+	if _, err := cng.X963KDF("NOTAHASH", z, nil, 16); err == nil {
+		t.Error("expected error for unknown hash")
+	}
+	if _, err := cng.X963KDF("SHA256", z, nil, -1); err == nil {
+		t.Error("expected error for negative length")
+	}
+}
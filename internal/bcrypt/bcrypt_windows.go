@@ -19,6 +19,7 @@ const (
 	SHA3_384_ALGORITHM   = "SHA3-384"
 	SHA3_512_ALGORITHM   = "SHA3-512"
 	AES_ALGORITHM        = "AES"
+	AES_CMAC_ALGORITHM   = "AES-CMAC"
 	RC4_ALGORITHM        = "RC4"
 	RSA_ALGORITHM        = "RSA"
 	MD4_ALGORITHM        = "MD4"
@@ -48,10 +49,12 @@ const (
 	CHAIN_MODE_ECB    = "ChainingModeECB"
 	CHAIN_MODE_CBC    = "ChainingModeCBC"
 	CHAIN_MODE_GCM    = "ChainingModeGCM"
+	CHAIN_MODE_CCM    = "ChainingModeCCM"
 	KEY_LENGTH        = "KeyLength"
 	KEY_LENGTHS       = "KeyLengths"
 	BLOCK_LENGTH      = "BlockLength"
 	ECC_CURVE_NAME    = "ECCCurveName"
+	AUTH_TAG_LENGTH   = "AuthTagLength"
 )
 
 const (
@@ -70,6 +73,9 @@ const (
 
 const (
 	KDF_HASH_ALGORITHM   = 0x0
+	KDF_SECRET_PREPEND   = 0x1
+	KDF_SECRET_APPEND    = 0x2
+	KDF_HMAC_KEY         = 0x3
 	KDF_TLS_PRF_LABEL    = 0x4
 	KDF_TLS_PRF_SEED     = 0x5
 	KDF_TLS_PRF_PROTOCOL = 0x6
@@ -79,6 +85,7 @@ const (
 
 const (
 	KEY_DATA_BLOB          = "KeyDataBlob"
+	AES_WRAP_KEY_BLOB      = "Rfc3565KeyWrapBlob"
 	KEY_DATA_BLOB_MAGIC    = 0x4d42444b
 	KEY_DATA_BLOB_VERSION1 = 1
 )
@@ -106,9 +113,16 @@ const (
 )
 
 const (
+	KDF_HASH       = "HASH"
+	KDF_HMAC       = "HMAC"
 	KDF_RAW_SECRET = "TRUNCATE"
 )
 
+const (
+	AUTH_MODE_CHAIN_CALLS_FLAG = 0x00000001
+	AUTH_MODE_IN_PROGRESS_FLAG = 0x00000002
+)
+
 type PadMode uint32
 
 const (
@@ -124,6 +138,7 @@ type AlgorithmProviderFlags uint32
 const (
 	ALG_NONE_FLAG        AlgorithmProviderFlags = 0x00000000
 	ALG_HANDLE_HMAC_FLAG AlgorithmProviderFlags = 0x00000008
+	HASH_REUSABLE_FLAG   AlgorithmProviderFlags = 0x00000020
 )
 
 type KeyBlobMagicNumber uint32
@@ -154,6 +169,15 @@ type KEY_LENGTHS_STRUCT struct {
 	Increment uint32
 }
 
+// https://docs.microsoft.com/en-us/windows/win32/api/bcrypt/ns-bcrypt-bcrypt_key_lengths_struct
+type AUTH_TAG_LENGTHS_STRUCT = KEY_LENGTHS_STRUCT
+
+// align64Pad is the padding needed to align a uint64 field that follows
+// a pointer-aligned offset as the C compiler does. Go only aligns uint64
+// to 4 bytes on 32-bit platforms, while ULONGLONG is 8-byte aligned
+// in the Windows ABI, including x86 and WoW64 processes.
+const align64Pad = 8 - unsafe.Sizeof(uintptr(0))
+
 // https://docs.microsoft.com/en-us/windows/win32/api/bcrypt/ns-bcrypt-bcrypt_authenticated_cipher_mode_info
 type AUTHENTICATED_CIPHER_MODE_INFO struct {
 	Size           uint32
@@ -167,8 +191,10 @@ type AUTHENTICATED_CIPHER_MODE_INFO struct {
 	MacContext     *byte
 	MacContextSize uint32
 	AADSize        uint32
+	_              [align64Pad]byte
 	DataSize       uint64
 	Flags          uint32
+	_              [align64Pad]byte
 }
 
 func NewAUTHENTICATED_CIPHER_MODE_INFO(nonce, additionalData, tag []byte) *AUTHENTICATED_CIPHER_MODE_INFO {
@@ -261,6 +287,7 @@ func Encrypt(hKey KEY_HANDLE, plaintext []byte, pPaddingInfo unsafe.Pointer, pbI
 //sys   generateSymmetricKey(hAlgorithm ALG_HANDLE, phKey *KEY_HANDLE, pbKeyObject []byte, pbSecret *byte, cbSecret uint32, dwFlags uint32) (s error) = bcrypt.BCryptGenerateSymmetricKey
 //sys   GenerateKeyPair(hAlgorithm ALG_HANDLE, phKey *KEY_HANDLE, dwLength uint32, dwFlags uint32) (s error) = bcrypt.BCryptGenerateKeyPair
 //sys   FinalizeKeyPair(hKey KEY_HANDLE, dwFlags uint32) (s error) = bcrypt.BCryptFinalizeKeyPair
+//sys   ImportKey(hAlgorithm ALG_HANDLE, hImportKey KEY_HANDLE, pszBlobType *uint16, phKey *KEY_HANDLE, pbKeyObject []byte, pbInput []byte, dwFlags uint32) (s error) = bcrypt.BCryptImportKey
 //sys   ImportKeyPair (hAlgorithm ALG_HANDLE, hImportKey KEY_HANDLE, pszBlobType *uint16, phKey *KEY_HANDLE, pbInput []byte, dwFlags uint32) (s error) = bcrypt.BCryptImportKeyPair
 //sys   ExportKey(hKey KEY_HANDLE, hExportKey KEY_HANDLE, pszBlobType *uint16, pbOutput []byte, pcbResult *uint32, dwFlags uint32) (s error) = bcrypt.BCryptExportKey
 //sys   DestroyKey(hKey KEY_HANDLE) (s error) = bcrypt.BCryptDestroyKey
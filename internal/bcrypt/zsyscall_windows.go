@@ -58,6 +58,7 @@ var (
 	procBCryptGetProperty            = modbcrypt.NewProc("BCryptGetProperty")
 	procBCryptHash                   = modbcrypt.NewProc("BCryptHash")
 	procBCryptHashData               = modbcrypt.NewProc("BCryptHashData")
+	procBCryptImportKey              = modbcrypt.NewProc("BCryptImportKey")
 	procBCryptImportKeyPair          = modbcrypt.NewProc("BCryptImportKeyPair")
 	procBCryptKeyDerivation          = modbcrypt.NewProc("BCryptKeyDerivation")
 	procBCryptOpenAlgorithmProvider  = modbcrypt.NewProc("BCryptOpenAlgorithmProvider")
@@ -304,6 +305,22 @@ func HashData(hHash HASH_HANDLE, pbInput []byte, dwFlags uint32) (s error) {
 	return
 }
 
+func ImportKey(hAlgorithm ALG_HANDLE, hImportKey KEY_HANDLE, pszBlobType *uint16, phKey *KEY_HANDLE, pbKeyObject []byte, pbInput []byte, dwFlags uint32) (s error) {
+	var _p0 *byte
+	if len(pbKeyObject) > 0 {
+		_p0 = &pbKeyObject[0]
+	}
+	var _p1 *byte
+	if len(pbInput) > 0 {
+		_p1 = &pbInput[0]
+	}
+	r0, _, _ := syscall.Syscall9(procBCryptImportKey.Addr(), 9, uintptr(hAlgorithm), uintptr(hImportKey), uintptr(unsafe.Pointer(pszBlobType)), uintptr(unsafe.Pointer(phKey)), uintptr(unsafe.Pointer(_p0)), uintptr(len(pbKeyObject)), uintptr(unsafe.Pointer(_p1)), uintptr(len(pbInput)), uintptr(dwFlags))
+	if r0 != 0 {
+		s = syscall.Errno(r0)
+	}
+	return
+}
+
 func ImportKeyPair(hAlgorithm ALG_HANDLE, hImportKey KEY_HANDLE, pszBlobType *uint16, phKey *KEY_HANDLE, pbInput []byte, dwFlags uint32) (s error) {
 	var _p0 *byte
 	if len(pbInput) > 0 {